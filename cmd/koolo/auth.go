@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hectorgimenez/koolo/internal/server"
+)
+
+const authUsage = `Usage:
+  koolo auth hash-password     Reads the password from stdin and prints the value for server.auth.passwordHash
+  koolo auth new-token <name>  Generates an API token and prints the entry for server.auth.tokens`
+
+// runAuth implements "koolo auth", the generated values have to be copied to config/koolo.yaml
+func runAuth(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(authUsage)
+		return errors.New("missing auth command")
+	}
+
+	switch args[0] {
+	case "hash-password":
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			return fmt.Errorf("error reading password: %w", err)
+		}
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return errors.New("password can not be empty")
+		}
+
+		hash, err := server.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("passwordHash: '%s'\n", hash)
+	case "new-token":
+		if len(args) < 2 {
+			fmt.Println(authUsage)
+			return errors.New("missing token name")
+		}
+
+		token, hash, err := server.NewAPIToken()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Token (send it as 'Authorization: Bearer <token>', it can't be recovered later): %s\n", token)
+		fmt.Printf("Add it to server.auth.tokens:\n    - name: %s\n      hash: '%s'\n", args[1], hash)
+	default:
+		fmt.Println(authUsage)
+		return fmt.Errorf("unknown auth command %s", args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+const configUsage = `Usage:
+  koolo config export [-format zip|tar] [-out file] <supervisor>  Exports the character config bundle, without secrets
+  koolo config import [-name supervisor] [-replace-settings] <file>  Creates a character from a bundle, asks for its secrets`
+
+// runConfig implements "koolo config", sharing character setups as a single bundle file
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(configUsage)
+		return errors.New("missing config command")
+	}
+
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("config export", flag.ContinueOnError)
+		format := fs.String("format", string(config.BundleZip), "Bundle format, zip or tar (gzipped)")
+		out := fs.String("out", "", "Output file, defaults to <supervisor>.zip or <supervisor>.tar.gz")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			fmt.Println(configUsage)
+			return errors.New("missing supervisor name")
+		}
+
+		name := fs.Arg(0)
+		if *out == "" {
+			*out = name + ".zip"
+			if config.BundleFormat(*format) == config.BundleTar {
+				*out = name + ".tar.gz"
+			}
+		}
+
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err = config.ExportBundle(name, config.BundleFormat(*format), f); err != nil {
+			os.Remove(*out)
+			return err
+		}
+		fmt.Printf("Exported %s to %s\n", name, *out)
+	case "import":
+		fs := flag.NewFlagSet("config import", flag.ContinueOnError)
+		name := fs.String("name", "", "Supervisor name, defaults to the bundle file name")
+		replaceSettings := fs.Bool("replace-settings", false, "Replace config/Settings.json with the bundle one")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			fmt.Println(configUsage)
+			return errors.New("missing bundle file")
+		}
+
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if *name == "" {
+			*name = filepath.Base(fs.Arg(0))
+			for _, ext := range []string{".zip", ".gz", ".tar"} {
+				*name = strings.TrimSuffix(*name, ext)
+			}
+		}
+
+		// Secrets are never part of a bundle, empty values can be set later from the UI
+		stdin := bufio.NewReader(os.Stdin)
+		password, err := prompt(stdin, "Battle.net password (empty to skip, or a ${env:VARIABLE} reference): ")
+		if err != nil {
+			return err
+		}
+		authToken, err := prompt(stdin, "Auth token (empty to skip, or a ${env:VARIABLE} reference): ")
+		if err != nil {
+			return err
+		}
+
+		opts := config.ImportOptions{
+			Secrets:         config.BundleSecrets{Password: password, AuthToken: authToken},
+			ReplaceSettings: *replaceSettings,
+		}
+		// Stored in the vault when there is one, plain text values are written to the config otherwise
+		if config.DefaultVault.Exists() && (password != "" || authToken != "") {
+			if err = unlockVault(stdin); err != nil {
+				return err
+			}
+			opts.SecretProvider = config.SecretProviderVault
+		}
+
+		manifest, err := config.ImportBundle(*name, f, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %s (%s, exported from %s) as %s\n", fs.Arg(0), manifest.Class, manifest.Character, *name)
+	default:
+		fmt.Println(configUsage)
+		return fmt.Errorf("unknown config command %s", args[0])
+	}
+
+	return nil
+}
+
+func prompt(r *bufio.Reader, label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	value, err := r.ReadString('\n')
+	if err != nil && value == "" && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("error reading input: %w", err)
+	}
+
+	return strings.TrimRight(value, "\r\n"), nil
+}
@@ -2,20 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime/debug"
+	"strconv"
+	"syscall"
 
 	sloggger "github.com/hectorgimenez/koolo/cmd/koolo/log"
 	"github.com/hectorgimenez/koolo/internal/bot"
 	"github.com/hectorgimenez/koolo/internal/config"
 	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+	"github.com/hectorgimenez/koolo/internal/metrics"
 	"github.com/hectorgimenez/koolo/internal/remote/discord"
 	"github.com/hectorgimenez/koolo/internal/remote/droplog"
 	"github.com/hectorgimenez/koolo/internal/remote/telegram"
+	"github.com/hectorgimenez/koolo/internal/remote/webhook"
 	"github.com/hectorgimenez/koolo/internal/server"
 	"github.com/hectorgimenez/koolo/internal/utils"
 	"github.com/hectorgimenez/koolo/internal/utils/winproc"
@@ -23,6 +33,8 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+const defaultPort = 8087
+
 var (
 	buildID   string
 	buildTime string
@@ -43,11 +55,65 @@ func wrapWithRecover(logger *slog.Logger, f func() error) func() error {
 	}
 }
 
+// startWithSecret resolves the token of a bot and starts it, see startWhenUnlocked
+func startWithSecret(ctx context.Context, g *errgroup.Group, logger *slog.Logger, name, token string, start func(token string) error) {
+	startWhenUnlocked(ctx, g, logger, name, func() error {
+		secret, err := config.ResolveSecret(token)
+		if err != nil {
+			return err
+		}
+
+		return start(secret)
+	})
+}
+
+// startWhenUnlocked runs start, which resolves its secrets. A notifier failing to start is skipped without stopping
+// Koolo, and when a secret is stored in the locked vault start runs again once the vault is unlocked from the settings page.
+func startWhenUnlocked(ctx context.Context, g *errgroup.Group, logger *slog.Logger, name string, start func() error) {
+	err := start()
+	if !errors.Is(err, config.ErrVaultLocked) {
+		if err != nil {
+			logger.Error(name+" could not been initialized", slog.Any("error", err))
+		}
+		return
+	}
+
+	logger.Warn(name+" will be initialized once the secrets vault is unlocked", slog.Any("error", err))
+	g.Go(wrapWithRecover(logger, func() error {
+		select {
+		case <-config.DefaultVault.Unlocked():
+			if err := start(); err != nil {
+				logger.Error(name+" could not been initialized", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+		}
+
+		return nil
+	}))
+}
+
+// uiHost returns the host used to open the UI, listening on every interface includes localhost
+func uiHost(address string) string {
+	if address == "" || address == "0.0.0.0" || address == "::" {
+		return "localhost"
+	}
+
+	return address
+}
+
 func main() {
 
 	_ = buildID
 	_ = buildTime
 
+	// Helpers to generate the web UI credentials, they don't need the configuration
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		if err := runAuth(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+		return
+	}
+
 	err := config.Load()
 	if err != nil {
 		utils.ShowDialog("Error loading configuration", err.Error())
@@ -55,12 +121,64 @@ func main() {
 		return
 	}
 
+	// Secret references and the vault, see config/secrets.go
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		if err = runSecrets(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+		return
+	}
+
+	// The vault can be unlocked from the environment, otherwise it's unlocked from the settings page
+	if passphrase := os.Getenv(config.VaultPassphraseEnv); passphrase != "" {
+		if err = config.DefaultVault.Unlock(passphrase); err != nil {
+			log.Fatalf("Error unlocking the secrets vault: %s", err.Error())
+		}
+	}
+
+	// Character config bundles, to share setups between installations
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err = runConfig(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+		return
+	}
+
+	// Pickit rules simulator, evaluates the rules against item dumps
+	if len(os.Args) > 1 && os.Args[1] == "pickit" {
+		if err = runPickit(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+		return
+	}
+
+	// Offline replay of a recording, it doesn't start the bot nor the UI
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err = runReplay(os.Args[2:]); err != nil {
+			log.Fatalf("Error replaying events: %s", err.Error())
+		}
+		return
+	}
+
+	headless := flag.Bool("headless", config.Koolo.Server.Headless, "Run without the Koolo window, the UI is only available from the browser")
+	address := flag.String("address", config.Koolo.Server.Address, "Address the web UI listens on, empty listens on all of them")
+	port := flag.Int("port", config.Koolo.Server.Port, "Port the web UI listens on")
+	flag.Parse()
+	if *port <= 0 {
+		*port = defaultPort
+	}
+
 	logger, err := sloggger.NewLogger(config.Koolo.Debug.Log, config.Koolo.LogSaveDirectory, "")
 	if err != nil {
 		log.Fatalf("Error starting logger: %s", err.Error())
 	}
 	defer sloggger.FlushAndClose()
 
+	// Broken character configs don't stop Koolo, those supervisors can't start until the file is fixed
+	for name, errs := range config.InvalidCharacters() {
+		logger.Error("Character config could not be loaded", slog.String("supervisor", name), slog.Any("error", errs))
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("fatal error detected, Koolo will close with the following error: %v\n Stacktrace: %s", r, debug.Stack())
@@ -70,7 +188,8 @@ func main() {
 		}
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// SIGINT/SIGTERM stop Koolo gracefully, it's the only way to close it in headless mode
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	g, ctx := errgroup.WithContext(ctx)
@@ -86,73 +205,134 @@ func main() {
 	}
 	dropDir := filepath.Join(dropBase, "droplogs")
 	dropWriter := droplog.NewWriter(dropDir, logger)
-	eventListener.Register(dropWriter.Handle)
-	manager := bot.NewSupervisorManager(logger, eventListener)
+	eventListener.Register(dropWriter.Handle, event.WithName("droplog"), event.WithSpill(filepath.Join(dropBase, "event-spill", "droplog")))
+
+	// Every event is recorded when enabled, the recording can be replayed offline with "koolo replay"
+	if config.Koolo.Debug.RecordEvents {
+		recorder, err := event.NewRecorder(filepath.Join(dropBase, "recordings"), event.DefaultRecordingMaxSize, event.DefaultRecordingMaxFiles)
+		if err != nil {
+			logger.Error("Event recorder could not been initialized", slog.Any("error", err))
+		} else {
+			defer recorder.Close()
+			eventListener.Register(recorder.Handle, event.WithName("recorder"), event.WithSpill(filepath.Join(dropBase, "event-spill", "recorder")))
+		}
+	}
+
+	// Persistent run/game history, written by each supervisor stats handler
+	historyStore := history.NewStore(filepath.Join(dropBase, "history"), config.Koolo.HistoryRetentionDays)
+	metricsCollector := metrics.NewCollector(eventListener)
+	// Counters are updated in memory, waiting for them never stalls the dispatcher and no event is lost
+	eventListener.Register(metricsCollector.Handle, event.WithName("metrics"), event.WithOverflow(event.OverflowBlock))
+	manager := bot.NewSupervisorManager(logger, eventListener, historyStore)
 	scheduler := bot.NewScheduler(manager, logger)
 	go scheduler.Start()
-	srv, err := server.New(logger, manager)
+	srv, err := server.New(logger, manager, historyStore, metricsCollector)
 	if err != nil {
 		log.Fatalf("Error starting local server: %s", err.Error())
 	}
+	eventListener.Register(srv.HandleEvent, event.WithName("event-stream"))
+
+	scheme := "http"
+	if config.Koolo.Server.TLS.Enabled {
+		scheme = "https"
+	}
+	uiURL := scheme + "://" + net.JoinHostPort(uiHost(*address), strconv.Itoa(*port))
 
 	// Use wrapWithRecover for all goroutines to handle panics
-	g.Go(wrapWithRecover(logger, func() error {
-		defer cancel()
-		displayScale := config.GetCurrentDisplayScale()
-		w, err := gowebview.New(&gowebview.Config{URL: "http://localhost:8087", WindowConfig: &gowebview.WindowConfig{
-			Title: "Koolo",
-			Size: &gowebview.Point{
+	if *headless {
+		// No window, Koolo keeps running until it gets SIGINT/SIGTERM
+		logger.Info("Running in headless mode, the UI is only available from the browser", slog.String("url", uiURL))
+	} else {
+		g.Go(wrapWithRecover(logger, func() error {
+			defer cancel()
+			displayScale := config.GetCurrentDisplayScale()
+			w, err := gowebview.New(&gowebview.Config{URL: uiURL, WindowConfig: &gowebview.WindowConfig{
+				Title: "Koolo",
+				Size: &gowebview.Point{
+					X: int64(1280 * displayScale),
+					Y: int64(720 * displayScale),
+				},
+			}})
+			if err != nil {
+				w.Destroy()
+				return fmt.Errorf("error creating webview: %w", err)
+			}
+
+			w.SetSize(&gowebview.Point{
 				X: int64(1280 * displayScale),
 				Y: int64(720 * displayScale),
-			},
-		}})
-		if err != nil {
-			w.Destroy()
-			return fmt.Errorf("error creating webview: %w", err)
-		}
-
-		w.SetSize(&gowebview.Point{
-			X: int64(1280 * displayScale),
-			Y: int64(720 * displayScale),
-		}, gowebview.HintFixed)
+			}, gowebview.HintFixed)
 
-		defer w.Destroy()
-		w.Run()
+			defer w.Destroy()
+			w.Run()
 
-		return nil
-	}))
+			return nil
+		}))
+	}
 
 	// Discord Bot initialization
 	if config.Koolo.Discord.Enabled {
-		discordBot, err := discord.NewBot(config.Koolo.Discord.Token, config.Koolo.Discord.ChannelID, manager)
-		if err != nil {
-			logger.Error("Discord could not been initialized", slog.Any("error", err))
-			return
-		}
+		startWithSecret(ctx, g, logger, "Discord", config.Koolo.Discord.Token, func(token string) error {
+			discordBot, err := discord.NewBot(token, config.Koolo.Discord.ChannelID, manager)
+			if err != nil {
+				return err
+			}
 
-		eventListener.Register(discordBot.Handle)
-		g.Go(wrapWithRecover(logger, func() error {
-			return discordBot.Start(ctx)
-		}))
+			// Discord uploads can be slow, spill to disk instead of delaying the other handlers
+			eventListener.Register(discordBot.Handle, event.WithName("discord"), event.WithSpill(filepath.Join(dropBase, "event-spill", "discord")))
+			g.Go(wrapWithRecover(logger, func() error {
+				return discordBot.Start(ctx)
+			}))
+
+			return nil
+		})
 	}
 
 	// Telegram Bot initialization
 	if config.Koolo.Telegram.Enabled {
-		telegramBot, err := telegram.NewBot(config.Koolo.Telegram.Token, config.Koolo.Telegram.ChatID, logger)
-		if err != nil {
-			logger.Error("Telegram could not been initialized", slog.Any("error", err))
-			return
+		startWithSecret(ctx, g, logger, "Telegram", config.Koolo.Telegram.Token, func(token string) error {
+			telegramBot, err := telegram.NewBot(token, config.Koolo.Telegram.ChatID, logger)
+			if err != nil {
+				return err
+			}
+
+			eventListener.Register(telegramBot.Handle, event.WithName("telegram"), event.WithSpill(filepath.Join(dropBase, "event-spill", "telegram")))
+			g.Go(wrapWithRecover(logger, func() error {
+				return telegramBot.Start(ctx)
+			}))
+
+			return nil
+		})
+	}
+
+	// Generic webhooks initialization
+	if config.Koolo.Webhooks.Enabled {
+		queueDir := config.Koolo.Webhooks.QueueDir
+		if queueDir == "" {
+			queueDir = filepath.Join(dropBase, "webhooks")
 		}
+		startWhenUnlocked(ctx, g, logger, "Webhooks", func() error {
+			endpoints, err := config.ResolveWebhookEndpoints(config.Koolo.Webhooks.Endpoints)
+			if err != nil {
+				return err
+			}
+			webhookNotifier, err := webhook.NewNotifier(endpoints, queueDir, logger)
+			if err != nil {
+				return err
+			}
 
-		eventListener.Register(telegramBot.Handle)
-		g.Go(wrapWithRecover(logger, func() error {
-			return telegramBot.Start(ctx)
-		}))
+			eventListener.Register(webhookNotifier.Handle, event.WithName("webhooks"))
+			g.Go(wrapWithRecover(logger, func() error {
+				return webhookNotifier.Start(ctx)
+			}))
+
+			return nil
+		})
 	}
 
 	g.Go(wrapWithRecover(logger, func() error {
 		defer cancel()
-		return srv.Listen(8087)
+		return srv.Listen(net.JoinHostPort(*address, strconv.Itoa(*port)))
 	}))
 
 	g.Go(wrapWithRecover(logger, func() error {
@@ -160,6 +340,11 @@ func main() {
 		return eventListener.Listen(ctx)
 	}))
 
+	// Config and pickit changes are applied to the running supervisors at their next game
+	g.Go(wrapWithRecover(logger, func() error {
+		return manager.WatchConfig(ctx, "config", config.Koolo.CentralizedPickitPath)
+	}))
+
 	g.Go(wrapWithRecover(logger, func() error {
 		<-ctx.Done()
 		logger.Info("Koolo shutting down...")
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/pickit"
+)
+
+const pickitUsage = `Usage:
+  koolo pickit test [-stash file] <supervisor> <fixture>...       Evaluates the supervisor pickit rules against item fixtures
+  koolo pickit test [-stash file] -rules <file.nip> <fixture>...  Evaluates the rules of a single file instead
+
+Fixtures are JSON dumps of items, drops or droplog records (a droplog-*.jsonl file works as is), the stash file uses the
+same format and is used to check [maxquantity].`
+
+// runPickit implements "koolo pickit", to write pickit rules without testing them in live games
+func runPickit(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Println(pickitUsage)
+		return errors.New("missing pickit command")
+	}
+
+	fs := flag.NewFlagSet("pickit test", flag.ContinueOnError)
+	stashFile := fs.String("stash", "", "Items already in the stash")
+	rulesFile := fs.String("rules", "", "Rules file to test instead of the supervisor ones")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	fixtures := fs.Args()
+	ruleSet := pickit.NewRuleSet()
+	if *rulesFile != "" {
+		if err := ruleSet.AddFile(pickit.LayerMemory, *rulesFile); err != nil {
+			return err
+		}
+	} else {
+		if len(fixtures) == 0 {
+			fmt.Println(pickitUsage)
+			return errors.New("missing supervisor name")
+		}
+		cfg, found := config.GetCharacter(fixtures[0])
+		if !found {
+			return fmt.Errorf("supervisor %s not found", fixtures[0])
+		}
+		ruleSet = cfg.Runtime.Pickit
+		fixtures = fixtures[1:]
+	}
+	if len(fixtures) == 0 {
+		fmt.Println(pickitUsage)
+		return errors.New("missing item fixtures")
+	}
+
+	var stash []data.Item
+	if *stashFile != "" {
+		var err error
+		if stash, err = readFixtureFiles(*stashFile); err != nil {
+			return err
+		}
+	}
+	items, err := readFixtureFiles(fixtures...)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ITEM\tQUALITY\tRESULT\tRULE\tORIGIN\tMAX QUANTITY")
+	for _, it := range items {
+		res := ruleSet.Evaluate(it, stash)
+		origin, quantity := "-", "-"
+		if res.Rule != "" {
+			origin = res.Origin.String()
+		}
+		if res.MaxQuantity > 0 {
+			quantity = fmt.Sprintf("%d/%d in stash", res.StashMatches, res.MaxQuantity)
+			if res.ExceedsQuantity {
+				quantity += ", exceeded"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", res.ItemName(), it.Quality.ToString(), res.ResultName(), res.Rule, origin, quantity)
+	}
+
+	return tw.Flush()
+}
+
+func readFixtureFiles(files ...string) ([]data.Item, error) {
+	var items []data.Item
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		fileItems, err := pickit.ReadItems(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		items = append(items, fileItems...)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sloggger "github.com/hectorgimenez/koolo/cmd/koolo/log"
+	"github.com/hectorgimenez/koolo/internal/bot"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+	"github.com/hectorgimenez/koolo/internal/remote/discord"
+	"github.com/hectorgimenez/koolo/internal/remote/droplog"
+)
+
+// runReplay implements "koolo replay", it feeds a recording through a fresh event listener with the stats, droplog
+// and a Discord stand-in handlers. Everything is written to the output directory, the real logs are never touched.
+// It doesn't need the game, but it's part of the koolo executable and uses the same stats handlers as the bot, so like
+// the bot it only builds and runs on Windows. Recordings can be copied from any machine running Koolo.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	out := fs.String("out", filepath.Join("logs", "replay-"+time.Now().Format("2006-01-02-15-04-05")), "Directory where the replayed droplogs, history and logs are written")
+	supervisor := fs.String("supervisor", "", "Only replay the events of this supervisor")
+	realtime := fs.Bool("realtime", false, "Wait between events the same time they took when they were recorded")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: koolo replay [flags] <recording file or directory>")
+		fmt.Fprintln(fs.Output(), "Replays a recording offline, the game is not needed but Koolo only runs on Windows.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("a recording file or directory is required")
+	}
+	recording := fs.Arg(0)
+
+	logger, err := sloggger.NewLogger(config.Koolo.Debug.Log, *out, "")
+	if err != nil {
+		return fmt.Errorf("error starting logger: %w", err)
+	}
+	defer sloggger.FlushAndClose()
+
+	accepts := func(e event.Event) bool {
+		return *supervisor == "" || strings.EqualFold(e.Supervisor(), *supervisor)
+	}
+
+	// First pass finds the supervisors, every one of them needs its own stats handler like in a real session
+	supervisors := make(map[string]bool)
+	err = event.ReadRecording(recording, func(e event.Event) error {
+		if accepts(e) && e.Supervisor() != "" {
+			supervisors[e.Supervisor()] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading recording: %w", err)
+	}
+
+	ctx := context.Background()
+	listener := event.NewListener(logger)
+
+	historyStore := history.NewStore(filepath.Join(*out, "history"), 0)
+	names := make([]string, 0, len(supervisors))
+	statsHandlers := make(map[string]*bot.StatsHandler, len(supervisors))
+	for name := range supervisors {
+		statsHandler := bot.NewStatsHandler(name, logger, historyStore)
+		// Offline replay must handle every event, waiting for the handlers is fine
+		listener.Register(statsHandler.Handle, event.WithName("stats-"+name), event.WithOverflow(event.OverflowBlock))
+		statsHandlers[name] = statsHandler
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dropWriter := droplog.NewWriter(filepath.Join(*out, "droplogs"), logger)
+	listener.Register(dropWriter.Handle, event.WithName("droplog"), event.WithOverflow(event.OverflowBlock))
+	listener.Register(discord.NewStandIn(logger).Handle, event.WithName("discord"), event.WithOverflow(event.OverflowBlock))
+
+	logger.Info("Replaying recording", slog.String("recording", recording), slog.String("out", *out), slog.Int("supervisors", len(names)))
+
+	replayed := 0
+	var previous time.Time
+	err = event.ReadRecording(recording, func(e event.Event) error {
+		if !accepts(e) {
+			return nil
+		}
+		if *realtime && !previous.IsZero() && e.OccurredAt().After(previous) {
+			time.Sleep(e.OccurredAt().Sub(previous))
+		}
+		previous = e.OccurredAt()
+
+		listener.Dispatch(ctx, e)
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error replaying recording: %w", err)
+	}
+
+	if err = listener.Drain(ctx); err != nil {
+		return err
+	}
+
+	logger.Info("Replay finished", slog.Int("events", replayed))
+	for _, name := range names {
+		stats := statsHandlers[name].Stats()
+		logger.Info("Replayed supervisor stats",
+			slog.String("supervisor", name),
+			slog.Int("games", stats.TotalGames()),
+			slog.Int("deaths", stats.TotalDeaths()),
+			slog.Int("chickens", stats.TotalChickens()),
+			slog.Int("errors", stats.TotalErrors()),
+			slog.Int("drops", len(stats.Drops)),
+		)
+	}
+	for _, hs := range listener.HandlerStats() {
+		logger.Info("Replayed handler stats",
+			slog.String("handler", hs.Name),
+			slog.Uint64("processed", hs.Processed),
+			slog.Uint64("errors", hs.Errors),
+			slog.Uint64("dropped", hs.Dropped),
+			slog.Duration("maxLatency", hs.MaxLatency),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+const secretsUsage = `Usage:
+  koolo secrets set [-provider vault|dpapi] <name>     Stores a secret, referenced as ${provider:name} from the config files
+  koolo secrets delete [-provider vault|dpapi] <name>  Deletes a secret
+  koolo secrets list [-provider vault|dpapi]           Lists the stored secret names
+  koolo secrets protect [-provider vault|dpapi]        Moves every plain text password and token to the provider
+  koolo secrets passphrase                             Changes the vault master passphrase`
+
+// runSecrets implements "koolo secrets", the vault passphrase is taken from KOOLO_VAULT_PASSPHRASE or asked
+func runSecrets(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(secretsUsage)
+		return errors.New("missing secrets command")
+	}
+
+	fs := flag.NewFlagSet("secrets "+args[0], flag.ContinueOnError)
+	provider := fs.String("provider", config.SecretProviderVault, "Secret provider")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	if *provider == config.SecretProviderVault || args[0] == "passphrase" {
+		if err := unlockVault(stdin); err != nil {
+			return err
+		}
+	}
+	store, err := config.SecretStoreFor(*provider)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "set":
+		if fs.NArg() != 1 {
+			fmt.Println(secretsUsage)
+			return errors.New("missing secret name")
+		}
+		value, err := prompt(stdin, "Value: ")
+		if err != nil {
+			return err
+		}
+		if err = store.Set(fs.Arg(0), value); err != nil {
+			return err
+		}
+		fmt.Printf("Stored, use %s in the config files\n", config.SecretRef(*provider, fs.Arg(0)))
+	case "delete":
+		if fs.NArg() != 1 {
+			fmt.Println(secretsUsage)
+			return errors.New("missing secret name")
+		}
+		return store.Delete(fs.Arg(0))
+	case "list":
+		names, err := store.List()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(config.SecretRef(*provider, name))
+		}
+	case "protect":
+		stored, err := config.ProtectSecrets(*provider)
+		for _, name := range stored {
+			fmt.Printf("Moved %s to %s\n", name, config.SecretRef(*provider, name))
+		}
+		if err != nil {
+			return err
+		}
+		if len(stored) == 0 {
+			fmt.Println("There are no plain text secrets")
+		}
+	case "passphrase":
+		passphrase, err := newPassphrase(stdin)
+		if err != nil {
+			return err
+		}
+		return config.DefaultVault.ChangePassphrase(passphrase)
+	default:
+		fmt.Println(secretsUsage)
+		return fmt.Errorf("unknown secrets command %s", args[0])
+	}
+
+	return nil
+}
+
+func unlockVault(r *bufio.Reader) error {
+	vault := config.DefaultVault
+	if passphrase := os.Getenv(config.VaultPassphraseEnv); passphrase != "" {
+		return vault.Unlock(passphrase)
+	}
+
+	if !vault.Exists() {
+		fmt.Fprintf(os.Stderr, "Creating the secrets vault %s, the passphrase can't be recovered\n", vault.Path())
+		passphrase, err := newPassphrase(r)
+		if err != nil {
+			return err
+		}
+		return vault.Unlock(passphrase)
+	}
+
+	passphrase, err := prompt(r, "Vault passphrase: ")
+	if err != nil {
+		return err
+	}
+
+	return vault.Unlock(passphrase)
+}
+
+func newPassphrase(r *bufio.Reader) (string, error) {
+	passphrase, err := prompt(r, "New vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	repeated, err := prompt(r, "Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != repeated {
+		return "", errors.New("the passphrases don't match")
+	}
+
+	return passphrase, nil
+}
@@ -11,6 +11,7 @@ require (
 	github.com/inkeliz/gowebview v1.0.1
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e
 	github.com/otiai10/copy v1.14.0
+	golang.org/x/crypto v0.31.0
 	golang.org/x/sync v0.10.0
 	golang.org/x/sys v0.28.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -21,7 +22,6 @@ require (
 	github.com/expr-lang/expr v1.16.9 // indirect
 	github.com/inkeliz/w32 v1.0.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
 )
 
 replace github.com/hectorgimenez/d2go => github.com/kwader2k/d2go v0.0.0-20251015214129-b1273ff5d1b9
@@ -7,6 +7,7 @@ import (
 	"github.com/hectorgimenez/d2go/pkg/data/item"
 	"github.com/hectorgimenez/d2go/pkg/nip"
 	"github.com/hectorgimenez/koolo/internal/context"
+	"github.com/hectorgimenez/koolo/internal/event"
 	"github.com/hectorgimenez/koolo/internal/utils"
 )
 
@@ -420,10 +421,10 @@ func CubeRecipes() error {
 							continue
 						}
 
-						shouldStash, _, reason, _ := shouldStashIt(it, false)
+						decision := shouldStashIt(it, false)
 
-						if shouldStash {
-							ctx.Logger.Debug("Stashing item after cube recipe.", "item", it.Name, "recipe", recipe.Name, "reason", reason)
+						if decision.Action == event.ActionStash {
+							ctx.Logger.Debug("Stashing item after cube recipe.", "item", it.Name, "recipe", recipe.Name, "reason", decision.Reason, "rule", decision.Rule)
 							stashingRequired = true
 						} else if it.Name == "GrandCharm" {
 							ctx.Logger.Debug("Checking if we need to stash a GrandCharm that doesn't match any NIP rules.", "recipe", recipe.Name)
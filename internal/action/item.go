@@ -9,6 +9,7 @@ import (
 	"github.com/hectorgimenez/koolo/internal/action/step"
 	"github.com/hectorgimenez/koolo/internal/context"
 	"github.com/hectorgimenez/koolo/internal/game"
+	"github.com/hectorgimenez/koolo/internal/pickit"
 	"github.com/hectorgimenez/koolo/internal/ui"
 	"github.com/hectorgimenez/koolo/internal/utils"
 )
@@ -19,25 +20,7 @@ func doesExceedQuantity(rule nip.Rule) bool {
 
 	stashItems := ctx.Data.Inventory.ByLocation(item.LocationStash, item.LocationSharedStash)
 
-	maxQuantity := rule.MaxQuantity()
-	if maxQuantity == 0 {
-		return false
-	}
-
-	if maxQuantity == 0 {
-		return false
-	}
-
-	matchedItemsInStash := 0
-
-	for _, stashItem := range stashItems {
-		res, _ := rule.Evaluate(stashItem)
-		if res == nip.RuleResultFullMatch {
-			matchedItemsInStash += 1
-		}
-	}
-
-	return matchedItemsInStash >= maxQuantity
+	return pickit.ExceedsQuantity(rule, stashItems)
 }
 
 func DropMouseItem() {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/hectorgimenez/d2go/pkg/data"
@@ -340,9 +341,28 @@ func shouldBePickedUp(i data.Item) bool {
 	ctx := context.Get()
 	ctx.SetLastAction("shouldBePickedUp")
 
+	decision := pickupDecision(i)
+	ctx.RecordItemDecision(decision)
+
+	return decision.Action == event.ActionPickup
+}
+
+func pickupDecision(i data.Item) event.ItemDecision {
+	ctx := context.Get()
+
+	pickup := func(reason event.DecisionReason) event.ItemDecision {
+		return event.ItemDecision{Stage: event.StagePickup, Action: event.ActionPickup, Reason: reason, Item: i}
+	}
+	ignore := func(reason event.DecisionReason) event.ItemDecision {
+		return event.ItemDecision{Stage: event.StagePickup, Action: event.ActionIgnore, Reason: reason, Item: i}
+	}
+
 	// Always pickup Runewords and Wirt's Leg
-	if i.IsRuneword || i.Name == "WirtsLeg" {
-		return true
+	if i.IsRuneword {
+		return pickup(event.ReasonRuneword)
+	}
+	if i.Name == "WirtsLeg" {
+		return pickup(event.ReasonQuestItem)
 	}
 
 	// Pick up quest items if we're in leveling or questing run
@@ -350,51 +370,58 @@ func shouldBePickedUp(i data.Item) bool {
 	if specialRuns {
 		switch i.Name {
 		case "Scroll of Inifuss", "ScrollOfInifuss", "LamEsensTome", "HoradricCube", "AmuletoftheViper", "StaffofKings", "HoradricStaff", "AJadeFigurine", "KhalimsEye", "KhalimsBrain", "KhalimsHeart", "KhalimsFlail":
-			return true
+			return pickup(event.ReasonQuestItem)
 		}
 	}
 	if i.ID == 552 { // Book of Skill doesnt work by name, so we find it by ID
-		return true
+		return pickup(event.ReasonQuestItem)
 	}
 
 	if i.ID == 524 { // Scroll of Inifuss
-		return true
+		return pickup(event.ReasonQuestItem)
 	}
 	// Skip picking up gold if we can not carry more
 	gold, _ := ctx.Data.PlayerUnit.FindStat(stat.Gold, 0)
 	if gold.Value >= ctx.Data.PlayerUnit.MaxGold() && i.Name == "Gold" {
-		ctx.Logger.Debug("Skipping gold pickup, inventory full")
-		return false
+		return ignore(event.ReasonGoldFull)
 	}
 
 	// Skip picking up gold, usually early game there are small amounts of gold in many places full of enemies, better
 	// stay away of that
 	_, isLevelingChar := ctx.Char.(context.LevelingCharacter)
 	if isLevelingChar && ctx.Data.PlayerUnit.TotalPlayerGold() < 50000 && i.Name != "Gold" {
-		return true
+		return pickup(event.ReasonLowGold)
 	}
 
 	// Pickup all magic or superior items if total gold is low, filter will not pass and items will be sold to vendor
 	minGoldPickupThreshold := ctx.CharacterCfg.Game.MinGoldPickupThreshold
 	if ctx.Data.PlayerUnit.TotalPlayerGold() < minGoldPickupThreshold && i.Quality >= item.QualityMagic {
-		return true
+		return pickup(event.ReasonLowGold)
 	}
 
 	// Evaluate item based on NIP rules
 	matchedRule, result := ctx.Data.CharacterCfg.Runtime.Rules.EvaluateAll(i)
 	if result == nip.RuleResultNoMatch {
-		return false
+		return ignore(event.ReasonNoRuleMatch)
 	}
 	if result == nip.RuleResultPartial {
-		return true
+		decision := pickup(event.ReasonRulePartial)
+		decision.Rule = matchedRule.RawLine
+		decision.RuleFile = matchedRule.Filename + ":" + strconv.Itoa(matchedRule.LineNumber)
+		return decision
 	}
 
+	decision := pickup(event.ReasonRuleMatch)
+	decision.Rule = matchedRule.RawLine
+	decision.RuleFile = matchedRule.Filename + ":" + strconv.Itoa(matchedRule.LineNumber)
+
 	// Blacklist item if it exceeds quantity limits according to pickit rules
 	if doesExceedQuantity(matchedRule) {
 		ctx.CurrentGame.BlacklistedItems = append(ctx.CurrentGame.BlacklistedItems, i)
 		ctx.Logger.Debug(fmt.Sprintf("Blacklisted item %s (UnitID: %d) because it exceeds quantity limits defined in pickit.", i.Name, i.UnitID))
-		return false // Do not pick up the item if it exceeds quantity
+		decision.Action = event.ActionIgnore
+		decision.Reason = event.ReasonMaxQuantity
 	}
 
-	return true
+	return decision
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/hectorgimenez/koolo/internal/event"
 	"github.com/hectorgimenez/koolo/internal/game"
 	"github.com/hectorgimenez/koolo/internal/health"
+	"github.com/hectorgimenez/koolo/internal/pather"
 )
 
 const (
@@ -277,6 +278,37 @@ func MoveToArea(dst area.ID) error {
 	return nil
 }
 
+// MoveToAreaPosition moves to a position of any area following the cheapest route found by the path finder, taking
+// waypoints, entrances and area borders as needed
+func MoveToAreaPosition(dst area.ID, to data.Position) error {
+	ctx := context.Get()
+	ctx.SetLastAction("MoveToAreaPosition")
+
+	if err := checkPlayerDeath(ctx); err != nil {
+		return err
+	}
+
+	route, found := ctx.PathFinder.PlanRoute(dst, to)
+	if !found {
+		return fmt.Errorf("no route found to %s", dst.Area().Name)
+	}
+	ctx.Logger.Debug("Following route", slog.String("route", route.String()), slog.Int("cost", route.Cost))
+
+	for _, s := range route.Steps {
+		var err error
+		if s.Kind == pather.RouteWaypoint {
+			err = WayPoint(s.To)
+		} else {
+			err = MoveToArea(s.To)
+		}
+		if err != nil {
+			return fmt.Errorf("error moving from %s to %s: %w", s.From.Area().Name, s.To.Area().Name, err)
+		}
+	}
+
+	return MoveToCoords(route.Destination)
+}
+
 func MoveToCoords(to data.Position) error {
 	ctx := context.Get()
 
@@ -77,8 +77,8 @@ func isStashingRequired(firstRun bool) bool {
 			continue
 		}
 
-		stashIt, dropIt, _, _ := shouldStashIt(i, firstRun)
-		if stashIt || dropIt { // Check for dropIt as well
+		decision := shouldStashIt(i, firstRun)
+		if decision.Action == event.ActionStash || decision.Action == event.ActionDrop {
 			return true
 		}
 	}
@@ -170,9 +170,12 @@ func stashInventory(firstRun bool) {
 	}
 
 	for _, i := range itemsToProcess { // Iterate over the copy
-		stashIt, dropIt, matchedRule, ruleFile := shouldStashIt(i, firstRun)
+		decision := shouldStashIt(i, firstRun)
+		if decision.Reason != event.ReasonLockedSlot {
+			ctx.RecordItemDecision(decision)
+		}
 
-		if dropIt {
+		if decision.Action == event.ActionDrop {
 			ctx.Logger.Info(fmt.Sprintf("Dropping item %s [%s] due to MaxQuantity rule.", i.Desc().Name, i.Quality.ToString()))
 			blacklistItem(i) // Blacklist the item to prevent immediate re-pickup
 			utils.Sleep(500)
@@ -182,7 +185,7 @@ func stashInventory(firstRun bool) {
 			continue // Move to the next item
 		}
 
-		if !stashIt {
+		if decision.Action != event.ActionStash {
 			continue
 		}
 
@@ -197,7 +200,7 @@ func stashInventory(firstRun bool) {
 		for tabAttempt := 1; tabAttempt <= 5; tabAttempt++ {
 			SwitchStashTab(tabAttempt)
 
-			if stashItemAction(i, matchedRule, ruleFile, firstRun) {
+			if stashItemAction(i, decision.Rule, decision.RuleFile, firstRun) {
 				itemStashed = true
 				r, res := ctx.CharacterCfg.Runtime.Rules.EvaluateAll(i)
 
@@ -232,72 +235,80 @@ func stashInventory(firstRun bool) {
 	step.CloseAllMenus()
 }
 
-// shouldStashIt now returns stashIt, dropIt, matchedRule, ruleFile
-func shouldStashIt(i data.Item, firstRun bool) (bool, bool, string, string) {
+// shouldStashIt decides if the item should be stashed (ActionStash), dropped because it exceeds the rule max quantity
+// (ActionDrop) or kept in the inventory (ActionKeep)
+func shouldStashIt(i data.Item, firstRun bool) event.ItemDecision {
 	ctx := context.Get()
 	ctx.SetLastStep("shouldStashIt")
 
+	keep := func(reason event.DecisionReason) event.ItemDecision {
+		return event.ItemDecision{Stage: event.StageStash, Action: event.ActionKeep, Reason: reason, Item: i}
+	}
+	stash := func(reason event.DecisionReason) event.ItemDecision {
+		return event.ItemDecision{Stage: event.StageStash, Action: event.ActionStash, Reason: reason, Item: i}
+	}
+
 	// Don't stash items in protected slots (highest priority exclusion)
 	if ctx.CharacterCfg.Inventory.InventoryLock[i.Position.Y][i.Position.X] == 0 {
-		return false, false, "", ""
+		return keep(event.ReasonLockedSlot)
 	}
 
 	// These items should NEVER be stashed, regardless of quest status, pickit rules, or first run.
-	fmt.Printf("DEBUG: Evaluating item '%s' for *absolute* exclusion from stash.\n", i.Name)
-	if i.Name == "horadricstaff" { // This is the simplest way given your logs
-		fmt.Printf("DEBUG: ABSOLUTELY PREVENTING stash for '%s' (Horadric Staff exclusion).\n", i.Name)
-		return false, false, "", "" // Explicitly do NOT stash the Horadric Staff
+	if i.Name == "horadricstaff" {
+		return keep(event.ReasonQuestItem)
 	}
 
 	if i.Name == "tomeoftownportal" || i.Name == "tomeofidentify" || i.Name == "key" || i.Name == "wirtsleg" {
-		fmt.Printf("DEBUG: ABSOLUTELY PREVENTING stash for '%s' (Quest/Special item exclusion).\n", i.Name)
-		return false, false, "", ""
+		return keep(event.ReasonProtectedItem)
 	}
 
 	if _, isLevelingChar := ctx.Char.(context.LevelingCharacter); isLevelingChar && i.IsFromQuest() && i.Name != "HoradricCube" || i.Name == "HoradricStaff" {
-		return false, false, "", ""
+		return keep(event.ReasonQuestItem)
 	}
 
 	if firstRun {
-		fmt.Printf("DEBUG: Allowing stash for '%s' (first run).\n", i.Name)
-		return true, false, "FirstRun", ""
+		return stash(event.ReasonFirstRun)
 	}
 
 	if i.IsRuneword {
-		return true, false, "Runeword", ""
+		return stash(event.ReasonRuneword)
 	}
 
 	// Stash items that are part of a recipe which are not covered by the NIP rules
 	if shouldKeepRecipeItem(i) {
-		return true, false, "Item is part of a enabled recipe", ""
+		return stash(event.ReasonRecipe)
 	}
 
 	// Location/position checks
 	if i.Position.Y >= len(ctx.CharacterCfg.Inventory.InventoryLock) || i.Position.X >= len(ctx.CharacterCfg.Inventory.InventoryLock[0]) {
-		return false, false, "", ""
+		return keep(event.ReasonLockedSlot)
 	}
 
-	if i.Location.LocationType == item.LocationInventory && ctx.CharacterCfg.Inventory.InventoryLock[i.Position.Y][i.Position.X] == 0 || i.IsPotion() {
-		return false, false, "", ""
+	if i.Location.LocationType == item.LocationInventory && ctx.CharacterCfg.Inventory.InventoryLock[i.Position.Y][i.Position.X] == 0 {
+		return keep(event.ReasonLockedSlot)
+	}
+	if i.IsPotion() {
+		return keep(event.ReasonPotion)
 	}
 
 	// NOW, evaluate pickit rules.
 	rule, res := ctx.CharacterCfg.Runtime.Rules.EvaluateAll(i)
 
 	if res == nip.RuleResultFullMatch {
+		decision := stash(event.ReasonRuleMatch)
+		// If it matches a rule but exceeds quantity, we want to drop it, not stash.
 		if doesExceedQuantity(rule) {
-			// If it matches a rule but exceeds quantity, we want to drop it, not stash.
-			fmt.Printf("DEBUG: Dropping '%s' because MaxQuantity is exceeded.\n", i.Name)
-			return false, true, rule.RawLine, rule.Filename + ":" + strconv.Itoa(rule.LineNumber)
-		} else {
-			// If it matches a rule and quantity is fine, stash it.
-			fmt.Printf("DEBUG: Allowing stash for '%s' (pickit rule match: %s).\n", i.Name, rule.RawLine)
-			return true, false, rule.RawLine, rule.Filename + ":" + strconv.Itoa(rule.LineNumber)
+			decision.Stage = event.StageDrop
+			decision.Action = event.ActionDrop
+			decision.Reason = event.ReasonMaxQuantity
 		}
+		decision.Rule = rule.RawLine
+		decision.RuleFile = rule.Filename + ":" + strconv.Itoa(rule.LineNumber)
+
+		return decision
 	}
 
-	fmt.Printf("DEBUG: Disallowing stash for '%s' (no rule match and not explicitly kept, and not exceeding quantity).\n", i.Name)
-	return false, false, "", "" // Default if no other rule matches
+	return keep(event.ReasonNoRuleMatch)
 }
 
 func shouldKeepRecipeItem(i data.Item) bool {
@@ -394,8 +405,9 @@ func dropExcessItems() {
 			continue
 		}
 
-		_, dropIt, _, _ := shouldStashIt(i, false) // Re-evaluate if it should be dropped (not firstRun)
-		if dropIt {
+		decision := shouldStashIt(i, false) // Re-evaluate if it should be dropped (not firstRun)
+		if decision.Action == event.ActionDrop {
+			ctx.RecordItemDecision(decision)
 			itemsToDrop = append(itemsToDrop, i)
 		}
 	}
@@ -316,7 +316,8 @@ func MoveTo(dest data.Position, options ...MoveOption) error {
 			}
 		}
 
-		path, distance, found := ctx.PathFinder.GetPath(currentDest)
+		// Teleport characters get the teleport landings instead of every tile, the distance is still in tiles
+		path, distance, found := ctx.PathFinder.GetMovementPath(currentDest)
 		if !found {
 			if currentDest == shrineDestination {
 				ctx.Logger.Warn(fmt.Sprintf("Path to shrine at %v could not be calculated. Marking shrine as unreachable for a few minutes.", currentDest))
@@ -333,7 +334,7 @@ func MoveTo(dest data.Position, options ...MoveOption) error {
 			}
 			return nil
 		}
-		if distance <= minDistanceToFinishMoving || len(path) <= minDistanceToFinishMoving || len(path) == 0 {
+		if distance <= minDistanceToFinishMoving || len(path) == 0 {
 			if currentDest == dest {
 				return nil
 			}
@@ -74,6 +74,9 @@ func useWP(dest area.ID) error {
 	ctx := context.Get()
 	ctx.SetLastAction("useWP")
 
+	// The menu is open, the route planner can use the discovered waypoints from now on
+	ctx.PathFinder.RefreshWaypoints()
+
 	finalDestination := dest
 	traverseAreas := make([]area.ID, 0)
 	currentWP := area.WPAddresses[dest]
@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+)
+
+// Analytics aggregates game and run efficiency metrics over a set of games. Hours is the time spent in game, the rates
+// per hour don't include the time between games nor between sessions.
+type Analytics struct {
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Hours        float64        `json:"hours"`
+	Games        int            `json:"games"`
+	GamesPerHour float64        `json:"gamesPerHour"`
+	Runs         int            `json:"runs"`
+	RunsPerHour  float64        `json:"runsPerHour"`
+	DeathRate    float64        `json:"deathRate"`
+	ChickenRate  float64        `json:"chickenRate"`
+	Drops        int            `json:"drops"`
+	DropsPerHour float64        `json:"dropsPerHour"`
+	PerRun       []RunAnalytics `json:"perRun"`
+}
+
+// RunAnalytics contains the metrics for a single run name, durations are expressed in seconds
+type RunAnalytics struct {
+	Name            string                         `json:"name"`
+	Runs            int                            `json:"runs"`
+	Finished        int                            `json:"finished"`
+	RunsPerHour     float64                        `json:"runsPerHour"`
+	MedianDuration  float64                        `json:"medianDuration"`
+	P90Duration     float64                        `json:"p90Duration"`
+	TotalDuration   float64                        `json:"totalDuration"`
+	SuccessRatio    float64                        `json:"successRatio"`
+	DeathRate       float64                        `json:"deathRate"`
+	ChickenRate     float64                        `json:"chickenRate"`
+	Reasons         map[event.FinishReason]int     `json:"reasons"`
+	ReasonRatios    map[event.FinishReason]float64 `json:"reasonRatios"`
+	Potions         map[data.PotionType]int        `json:"potions"`
+	PotionsPerRun   float64                        `json:"potionsPerRun"`
+	MercPotions     int                            `json:"mercPotions"`
+	Drops           int                            `json:"drops"`
+	DropsPerHour    float64                        `json:"dropsPerHour"`
+	durationSeconds []float64
+	finishedDrops   int // Drops of the finished runs, the only ones with a duration
+}
+
+// AnalyzeGames computes the analytics for the given games. Runs still in progress are counted, but they are not
+// taken into account for durations and ratios.
+func AnalyzeGames(games []GameStats, now time.Time) Analytics {
+	a := Analytics{PerRun: make([]RunAnalytics, 0)}
+	perRun := make(map[string]*RunAnalytics)
+
+	var totalFinished, deaths, chickens int
+	for _, g := range games {
+		if a.From.IsZero() || g.StartedAt.Before(a.From) {
+			a.From = g.StartedAt
+		}
+		end := g.FinishedAt
+		if end.IsZero() {
+			end = now
+		}
+		if end.After(a.To) {
+			a.To = end
+		}
+		a.Hours += end.Sub(g.StartedAt).Hours()
+
+		for _, r := range g.Runs {
+			ra, found := perRun[r.Name]
+			if !found {
+				ra = &RunAnalytics{
+					Name:         r.Name,
+					Reasons:      make(map[event.FinishReason]int),
+					ReasonRatios: make(map[event.FinishReason]float64),
+					Potions:      make(map[data.PotionType]int),
+				}
+				perRun[r.Name] = ra
+			}
+
+			ra.Runs++
+			ra.Drops += len(r.Items)
+			for _, p := range r.UsedPotions {
+				if p.OnMerc {
+					ra.MercPotions++
+				}
+				ra.Potions[p.PotionType]++
+			}
+
+			if r.FinishedAt.IsZero() {
+				continue
+			}
+
+			ra.Finished++
+			ra.finishedDrops += len(r.Items)
+			ra.Reasons[r.Reason]++
+			ra.durationSeconds = append(ra.durationSeconds, r.FinishedAt.Sub(r.StartedAt).Seconds())
+
+			totalFinished++
+			switch r.Reason {
+			case event.FinishedDied:
+				deaths++
+			case event.FinishedChicken, event.FinishedMercChicken:
+				chickens++
+			}
+		}
+	}
+
+	a.Games = len(games)
+	for _, ra := range perRun {
+		ra.finish()
+		a.Runs += ra.Runs
+		a.Drops += ra.Drops
+		a.PerRun = append(a.PerRun, *ra)
+	}
+	sort.Slice(a.PerRun, func(i, j int) bool { return a.PerRun[i].Name < a.PerRun[j].Name })
+
+	a.GamesPerHour = ratio(float64(a.Games), a.Hours)
+	a.RunsPerHour = ratio(float64(a.Runs), a.Hours)
+	a.DropsPerHour = ratio(float64(a.Drops), a.Hours)
+	a.DeathRate = ratio(float64(deaths), float64(totalFinished))
+	a.ChickenRate = ratio(float64(chickens), float64(totalFinished))
+
+	return a
+}
+
+func (ra *RunAnalytics) finish() {
+	sort.Float64s(ra.durationSeconds)
+	for _, d := range ra.durationSeconds {
+		ra.TotalDuration += d
+	}
+	ra.MedianDuration = percentile(ra.durationSeconds, 50)
+	ra.P90Duration = percentile(ra.durationSeconds, 90)
+
+	hours := ra.TotalDuration / 3600
+	ra.RunsPerHour = ratio(float64(ra.Finished), hours)
+	ra.DropsPerHour = ratio(float64(ra.finishedDrops), hours)
+
+	totalPotions := 0
+	for _, count := range ra.Potions {
+		totalPotions += count
+	}
+	ra.PotionsPerRun = ratio(float64(totalPotions), float64(ra.Runs))
+
+	for reason, count := range ra.Reasons {
+		ra.ReasonRatios[reason] = ratio(float64(count), float64(ra.Finished))
+	}
+	ra.SuccessRatio = ra.ReasonRatios[event.FinishedOK]
+	ra.DeathRate = ra.ReasonRatios[event.FinishedDied]
+	ra.ChickenRate = ra.ReasonRatios[event.FinishedChicken] + ra.ReasonRatios[event.FinishedMercChicken]
+}
+
+// percentile uses the nearest-rank method over already sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+
+	return a / b
+}
+
+// GamesFromHistory rebuilds the per-game stats from persisted history records, records must be sorted by time
+func GamesFromHistory(records []history.Record) []GameStats {
+	gamesBySupervisor := make(map[string][]GameStats)
+	supervisors := make([]string, 0)
+	// lastRecord is the time of the last record of the current game of every supervisor
+	lastRecord := make(map[string]time.Time)
+
+	for _, rec := range records {
+		sup := strings.ToLower(rec.Supervisor)
+		games, found := gamesBySupervisor[sup]
+
+		if rec.Type == history.GameCreated {
+			// Records before the first game of the supervisor are skipped, it's only listed once it has games
+			if !found {
+				supervisors = append(supervisors, sup)
+			}
+			// A game never finished, e.g. the client crashed, ends with its last record instead of counting until now
+			if len(games) > 0 && games[len(games)-1].FinishedAt.IsZero() {
+				games[len(games)-1].FinishedAt = lastRecord[sup]
+			}
+			gamesBySupervisor[sup] = append(games, GameStats{StartedAt: rec.Time})
+			lastRecord[sup] = rec.Time
+			continue
+		}
+		if len(games) == 0 {
+			continue
+		}
+		lastRecord[sup] = rec.Time
+
+		lastGame := &games[len(games)-1]
+		var lastRun *RunStats
+		if len(lastGame.Runs) > 0 {
+			lastRun = &lastGame.Runs[len(lastGame.Runs)-1]
+		}
+
+		switch rec.Type {
+		case history.GameFinished:
+			lastGame.FinishedAt = rec.Time
+			lastGame.Reason = rec.Reason
+		case history.RunStarted:
+			lastGame.Runs = append(lastGame.Runs, RunStats{Name: rec.RunName, StartedAt: rec.Time})
+		case history.RunFinished:
+			if lastRun != nil {
+				lastRun.FinishedAt = rec.Time
+				lastRun.Reason = rec.Reason
+			}
+		case history.PotionUsed:
+			if lastRun != nil {
+				lastRun.UsedPotions = append(lastRun.UsedPotions, event.UsedPotionAt(rec.Supervisor, rec.Time, rec.PotionType, rec.OnMerc))
+			}
+		case history.ItemStashed:
+			if lastRun != nil && rec.Drop != nil {
+				lastRun.Items = append(lastRun.Items, rec.Drop.Item)
+			}
+		}
+	}
+
+	out := make([]GameStats, 0)
+	for _, sup := range supervisors {
+		out = append(out, gamesBySupervisor[sup]...)
+	}
+
+	return out
+}
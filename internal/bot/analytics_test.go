@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+)
+
+func assertFloat(t *testing.T, name string, expected, got float64) {
+	t.Helper()
+
+	if math.Abs(expected-got) > 1e-9 {
+		t.Errorf("%s: expected %v, got %v", name, expected, got)
+	}
+}
+
+func TestAnalyzeGames(t *testing.T) {
+	start := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	items := func(n int) []data.Item { return make([]data.Item, n) }
+
+	games := []GameStats{
+		{
+			StartedAt: at(0), FinishedAt: at(30), Reason: event.FinishedOK,
+			Runs: []RunStats{
+				{Name: "pindleskin", StartedAt: at(0), FinishedAt: at(10), Reason: event.FinishedOK, Items: items(2), UsedPotions: []event.UsedPotionEvent{
+					event.UsedPotionAt("sorc", at(5), data.HealingPotion, false),
+					event.UsedPotionAt("sorc", at(6), data.HealingPotion, true),
+				}},
+				{Name: "pindleskin", StartedAt: at(10), FinishedAt: at(15), Reason: event.FinishedDied, Items: items(1)},
+				{Name: "mephisto", StartedAt: at(15), FinishedAt: at(25), Reason: event.FinishedOK, Items: items(1)},
+			},
+		},
+		{
+			// Still running
+			StartedAt: at(30),
+			Runs: []RunStats{
+				{Name: "pindleskin", StartedAt: at(30), FinishedAt: at(40), Reason: event.FinishedChicken},
+				{Name: "mephisto", StartedAt: at(40), Items: items(3)},
+			},
+		},
+	}
+
+	a := AnalyzeGames(games, at(60))
+
+	if a.Games != 2 || a.Runs != 5 || a.Drops != 7 {
+		t.Errorf("expected 2 games, 5 runs and 7 drops, got %d, %d and %d", a.Games, a.Runs, a.Drops)
+	}
+	if !a.From.Equal(at(0)) || !a.To.Equal(at(60)) {
+		t.Errorf("unexpected period %s - %s", a.From, a.To)
+	}
+	assertFloat(t, "hours", 1, a.Hours)
+	assertFloat(t, "games per hour", 2, a.GamesPerHour)
+	assertFloat(t, "drops per hour", 7, a.DropsPerHour)
+	assertFloat(t, "death rate", 0.25, a.DeathRate)
+	assertFloat(t, "chicken rate", 0.25, a.ChickenRate)
+
+	if len(a.PerRun) != 2 || a.PerRun[0].Name != "mephisto" || a.PerRun[1].Name != "pindleskin" {
+		t.Fatalf("expected the runs sorted by name, got %+v", a.PerRun)
+	}
+
+	meph := a.PerRun[0]
+	if meph.Runs != 2 || meph.Finished != 1 || meph.Drops != 4 {
+		t.Errorf("mephisto: expected 2 runs, 1 finished and 4 drops, got %+v", meph)
+	}
+	// Only the drops of the finished run, the one in progress has no duration yet
+	assertFloat(t, "mephisto drops per hour", 6, meph.DropsPerHour)
+	assertFloat(t, "mephisto runs per hour", 6, meph.RunsPerHour)
+
+	pindle := a.PerRun[1]
+	assertFloat(t, "pindleskin median", 600, pindle.MedianDuration)
+	assertFloat(t, "pindleskin p90", 600, pindle.P90Duration)
+	assertFloat(t, "pindleskin total", 1500, pindle.TotalDuration)
+	assertFloat(t, "pindleskin runs per hour", 7.2, pindle.RunsPerHour)
+	assertFloat(t, "pindleskin drops per hour", 7.2, pindle.DropsPerHour)
+	assertFloat(t, "pindleskin success", 1.0/3, pindle.SuccessRatio)
+	assertFloat(t, "pindleskin potions per run", 2.0/3, pindle.PotionsPerRun)
+	if pindle.MercPotions != 1 || pindle.Potions[data.HealingPotion] != 2 {
+		t.Errorf("unexpected pindleskin potions %v, merc %d", pindle.Potions, pindle.MercPotions)
+	}
+}
+
+func TestAnalyzeGamesSessionsWithGap(t *testing.T) {
+	start := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	game := func(from, to int) GameStats {
+		return GameStats{
+			StartedAt: at(from), FinishedAt: at(to), Reason: event.FinishedOK,
+			Runs: []RunStats{{Name: "pindleskin", StartedAt: at(from), FinishedAt: at(to), Reason: event.FinishedOK, Items: make([]data.Item, 3)}},
+		}
+	}
+
+	// Two 30 minute sessions, a day apart
+	games := []GameStats{game(0, 15), game(15, 30), game(24*60, 24*60+15), game(24*60+15, 24*60+30)}
+	a := AnalyzeGames(games, at(48*60))
+
+	if !a.From.Equal(at(0)) || !a.To.Equal(at(24*60+30)) {
+		t.Errorf("unexpected period %s - %s", a.From, a.To)
+	}
+	assertFloat(t, "hours", 1, a.Hours)
+	assertFloat(t, "games per hour", 4, a.GamesPerHour)
+	assertFloat(t, "runs per hour", 4, a.RunsPerHour)
+	assertFloat(t, "drops per hour", 12, a.DropsPerHour)
+	assertFloat(t, "pindleskin runs per hour", 4, a.PerRun[0].RunsPerHour)
+}
+
+func TestAnalyzeGamesEmpty(t *testing.T) {
+	a := AnalyzeGames(nil, time.Now())
+	if a.Games != 0 || a.Hours != 0 || a.DropsPerHour != 0 || len(a.PerRun) != 0 {
+		t.Errorf("expected empty analytics, got %+v", a)
+	}
+}
+
+func TestGamesFromHistory(t *testing.T) {
+	start := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	records := []history.Record{
+		// Before the first game of the supervisor, ignored
+		{Time: at(0), Supervisor: "sorc", Type: history.RunStarted, RunName: "andariel"},
+		{Time: at(1), Supervisor: "sorc", Type: history.GameCreated},
+		{Time: at(1), Supervisor: "pala", Type: history.GameCreated},
+		{Time: at(2), Supervisor: "Sorc", Type: history.RunStarted, RunName: "pindleskin"},
+		{Time: at(2), Supervisor: "pala", Type: history.RunStarted, RunName: "mephisto"},
+		{Time: at(3), Supervisor: "sorc", Type: history.PotionUsed, PotionType: data.ManaPotion, OnMerc: true},
+		{Time: at(4), Supervisor: "sorc", Type: history.ItemStashed, Drop: &data.Drop{Item: data.Item{Name: "Ring"}}},
+		{Time: at(5), Supervisor: "sorc", Type: history.RunFinished, RunName: "pindleskin", Reason: event.FinishedOK},
+		{Time: at(6), Supervisor: "sorc", Type: history.GameFinished, Reason: event.FinishedOK},
+	}
+
+	games := GamesFromHistory(records)
+	if len(games) != 2 {
+		t.Fatalf("expected 2 games, got %+v", games)
+	}
+
+	sorc := games[0]
+	if !sorc.StartedAt.Equal(at(1)) || !sorc.FinishedAt.Equal(at(6)) || sorc.Reason != event.FinishedOK || len(sorc.Runs) != 1 {
+		t.Fatalf("unexpected sorc game %+v", sorc)
+	}
+	run := sorc.Runs[0]
+	if run.Name != "pindleskin" || !run.FinishedAt.Equal(at(5)) || run.Reason != event.FinishedOK || len(run.Items) != 1 || run.Items[0].Name != "Ring" {
+		t.Errorf("unexpected sorc run %+v", run)
+	}
+	if len(run.UsedPotions) != 1 {
+		t.Fatalf("expected 1 potion, got %+v", run.UsedPotions)
+	}
+	potion := run.UsedPotions[0]
+	if potion.PotionType != data.ManaPotion || !potion.OnMerc || !potion.OccurredAt().Equal(at(3)) || potion.Supervisor() != "sorc" {
+		t.Errorf("potion not rebuilt from the record: %+v at %s", potion, potion.OccurredAt())
+	}
+
+	pala := games[1]
+	if !pala.FinishedAt.IsZero() || len(pala.Runs) != 1 || pala.Runs[0].Name != "mephisto" || !pala.Runs[0].FinishedAt.IsZero() {
+		t.Errorf("unexpected pala game %+v", pala)
+	}
+}
+
+func TestGamesFromHistoryClosesAbandonedGames(t *testing.T) {
+	start := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	records := []history.Record{
+		{Time: at(0), Supervisor: "sorc", Type: history.GameCreated},
+		{Time: at(1), Supervisor: "sorc", Type: history.RunStarted, RunName: "pindleskin"},
+		// The client crashed, the game was never finished and the next one starts hours later
+		{Time: at(300), Supervisor: "sorc", Type: history.GameCreated},
+		{Time: at(301), Supervisor: "sorc", Type: history.RunStarted, RunName: "pindleskin"},
+	}
+
+	games := GamesFromHistory(records)
+	if len(games) != 2 {
+		t.Fatalf("expected 2 games, got %+v", games)
+	}
+	if !games[0].FinishedAt.Equal(at(1)) {
+		t.Errorf("the abandoned game should end with its last record, got %s", games[0].FinishedAt)
+	}
+	if !games[1].FinishedAt.IsZero() {
+		t.Errorf("the last game is still running, got %s", games[1].FinishedAt)
+	}
+	assertFloat(t, "hours", 1.0/60+10.0/60, AnalyzeGames(games, at(310)).Hours)
+}
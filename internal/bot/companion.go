@@ -24,6 +24,11 @@ func NewCompanionEventHandler(supervisor string, log *slog.Logger, cfg *config.C
 	}
 }
 
+// companionHandlerName is the name the companion handler of the supervisor is registered with on the event listener
+func companionHandlerName(supervisor string) string {
+	return "companion-" + supervisor
+}
+
 // Handle processes companion-related events
 func (h *CompanionEventHandler) Handle(ctx context.Context, e event.Event) error {
 
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+// configWatchInterval is how often config/ is checked for changes. Polling is used instead of file system
+// notifications, it works the same for every editor (some of them replace the file instead of writing it) and for
+// pickit folders outside config/.
+const configWatchInterval = 2 * time.Second
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// WatchConfig reloads the configuration when a config or pickit file changes, until ctx is done. Editors can write a
+// file in several steps, so the reload waits until nothing changed during a whole interval.
+func (mng *SupervisorManager) WatchConfig(ctx context.Context, dirs ...string) error {
+	last := configSnapshot(dirs)
+	changed := false
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			current := configSnapshot(dirs)
+			if !maps.Equal(current, last) {
+				// Files saved by Koolo itself are already loaded, only changes made outside of it are reloaded
+				if !writtenByKoolo(last, current) {
+					changed = true
+				}
+				last = current
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			changed = false
+			mng.logger.Info("Configuration files changed, reloading")
+			// A rejected config is already logged and notified, the next change is picked up again
+			_ = mng.ReloadConfig()
+		}
+	}
+}
+
+// writtenByKoolo returns true when every file changed between both snapshots was written by Koolo
+func writtenByKoolo(last, current map[string]fileStamp) bool {
+	for path, stamp := range current {
+		if previous, found := last[path]; found && previous == stamp {
+			continue
+		}
+		if !config.WrittenByKoolo(path, stamp.modTime, stamp.size) {
+			return false
+		}
+	}
+	for path := range last {
+		if _, found := current[path]; !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func configSnapshot(dirs []string) map[string]fileStamp {
+	snapshot := make(map[string]fileStamp)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if d.IsDir() || (ext != ".yaml" && ext != ".nip") {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			snapshot[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+
+			return nil
+		})
+	}
+
+	return snapshot
+}
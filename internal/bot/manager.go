@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 	"unsafe"
@@ -15,6 +16,7 @@ import (
 	"github.com/hectorgimenez/koolo/internal/event"
 	"github.com/hectorgimenez/koolo/internal/game"
 	"github.com/hectorgimenez/koolo/internal/health"
+	"github.com/hectorgimenez/koolo/internal/history"
 	"github.com/hectorgimenez/koolo/internal/pather"
 	"github.com/hectorgimenez/koolo/internal/utils"
 	"github.com/hectorgimenez/koolo/internal/utils/winproc"
@@ -26,15 +28,25 @@ type SupervisorManager struct {
 	supervisors    map[string]Supervisor
 	crashDetectors map[string]*game.CrashDetector
 	eventListener  *event.Listener
+	history        *history.Store
+	// applied is the config each running supervisor uses, it's what a reload is compared against
+	applied map[string]*config.CharacterCfg
+	// starting are the supervisors being built, they are added to supervisors once the game is running
+	starting map[string]struct{}
+	// mu guards supervisors, crashDetectors, applied and starting, and serializes the config reloads
+	mu sync.Mutex
 }
 
-func NewSupervisorManager(logger *slog.Logger, eventListener *event.Listener) *SupervisorManager {
+func NewSupervisorManager(logger *slog.Logger, eventListener *event.Listener, historyStore *history.Store) *SupervisorManager {
 
 	return &SupervisorManager{
 		logger:         logger,
 		supervisors:    make(map[string]Supervisor),
 		crashDetectors: make(map[string]*game.CrashDetector),
 		eventListener:  eventListener,
+		history:        historyStore,
+		applied:        make(map[string]*config.CharacterCfg),
+		starting:       make(map[string]struct{}),
 	}
 }
 
@@ -50,16 +62,10 @@ func (mng *SupervisorManager) AvailableSupervisors() []string {
 }
 
 func (mng *SupervisorManager) Start(supervisorName string, attachToExisting bool, pidHwnd ...uint32) error {
-	// Avoid multiple instances of the supervisor - shitstorm prevention
-	if _, exists := mng.supervisors[supervisorName]; exists {
-		return fmt.Errorf("supervisor %s is already running", supervisorName)
-	}
-
-	// Reload config to get the latest local changes before starting the supervisor
-	err := config.Load()
-	if err != nil {
-		return fmt.Errorf("error loading config: %w", err)
+	if err := mng.reserve(supervisorName); err != nil {
+		return err
 	}
+	defer mng.release(supervisorName)
 
 	supervisorLogger, err := log.NewLogger(config.Koolo.Debug.Log, config.Koolo.LogSaveDirectory, supervisorName)
 	if err != nil {
@@ -84,12 +90,14 @@ func (mng *SupervisorManager) Start(supervisorName string, attachToExisting bool
 		return err
 	}
 
+	mng.mu.Lock()
 	if oldCrashDetector, exists := mng.crashDetectors[supervisorName]; exists {
 		oldCrashDetector.Stop() // Stop the old crash detector if it exists
 	}
-
 	mng.supervisors[supervisorName] = supervisor
 	mng.crashDetectors[supervisorName] = crashDetector
+	mng.applied[supervisorName] = clone(mng.logger, supervisorName, supervisor.GetContext().CharacterCfg)
+	mng.mu.Unlock()
 
 	if config.Koolo.GameWindowArrangement {
 		go func() {
@@ -111,97 +119,199 @@ func (mng *SupervisorManager) Start(supervisorName string, attachToExisting bool
 	return nil
 }
 
-func (mng *SupervisorManager) ReloadConfig() error {
+// reserve marks the supervisor as starting, loading the latest config from disk. It fails if the supervisor is already
+// running or starting, or if its config is not valid.
+func (mng *SupervisorManager) reserve(supervisorName string) error {
+	mng.mu.Lock()
+	// Avoid multiple instances of the supervisor - shitstorm prevention
+	_, running := mng.supervisors[supervisorName]
+	_, starting := mng.starting[supervisorName]
+	if running || starting {
+		mng.mu.Unlock()
+		return fmt.Errorf("supervisor %s is already running", supervisorName)
+	}
+	mng.starting[supervisorName] = struct{}{}
+	mng.mu.Unlock()
 
-	// Load fresh configs
+	// Reload config to get the latest local changes before starting the supervisor. It's loaded without holding mu,
+	// reading the files and the pickit rules would block the config watcher and the status of every supervisor.
 	if err := config.Load(); err != nil {
+		mng.release(supervisorName)
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if errs, invalid := config.InvalidCharacters()[supervisorName]; invalid {
+		mng.release(supervisorName)
+		return fmt.Errorf("error loading %s config: %w", supervisorName, errs)
+	}
+
+	return nil
+}
+
+// release removes the starting mark set by reserve
+func (mng *SupervisorManager) release(supervisorName string) {
+	mng.mu.Lock()
+	defer mng.mu.Unlock()
+
+	delete(mng.starting, supervisorName)
+}
+
+// ReloadConfig loads the configuration from disk and sends a config changed event for every character that changed.
+// Running supervisors switch to the new config at their next game boundary, a config failing validation is rejected
+// and the current one is kept.
+func (mng *SupervisorManager) ReloadConfig() error {
+	return mng.reload(config.Load)
+}
+
+// SaveSupervisorConfig writes the character config and applies it like ReloadConfig does, the config watcher ignores
+// the files written by Koolo.
+func (mng *SupervisorManager) SaveSupervisorConfig(supervisorName string, cfg *config.CharacterCfg) error {
+	return mng.reload(func() error {
+		return config.SaveSupervisorConfig(supervisorName, cfg)
+	})
+}
+
+func (mng *SupervisorManager) reload(load func() error) error {
+	mng.mu.Lock()
+	defer mng.mu.Unlock()
+
+	previous := config.GetCharacters()
+	if err := load(); err != nil {
+		mng.logger.Error("New configuration rejected, keeping the current one", slog.Any("error", err))
+		event.Send(event.ConfigChanged(event.Text("", "New configuration rejected"), nil, err))
 		return err
 	}
 
-	// Apply new configs to running supervisors
-	for name, sup := range mng.supervisors {
-		newCfg, exists := config.GetCharacter(name)
-		if !exists {
+	// Broken character configs are rejected one by one, those characters keep their current config
+	for name, errs := range config.InvalidCharacters() {
+		mng.logger.Error("New configuration rejected, keeping the current one", slog.String("supervisor", name), slog.Any("error", errs))
+		event.Send(event.ConfigChanged(event.Text(name, "New configuration rejected"), nil, errs))
+	}
+
+	for name, newCfg := range config.GetCharacters() {
+		oldCfg, running := mng.applied[name]
+		if !running {
+			oldCfg = previous[name]
+		}
+		if oldCfg == nil {
 			continue
 		}
 
-		ctx := sup.GetContext()
-		if ctx == nil {
+		changes, err := config.Diff(oldCfg, newCfg)
+		if err != nil {
+			mng.logger.Error("Error comparing configurations", slog.String("supervisor", name), slog.Any("error", err))
+			continue
+		}
+		if len(changes) == 0 {
 			continue
 		}
 
-		// Preserve runtime data
-		//oldRuntimeData := ctx.CharacterCfg.Runtime
+		eventChanges := make([]event.ConfigChange, 0, len(changes))
+		for _, c := range changes {
+			eventChanges = append(eventChanges, event.ConfigChange{Path: c.Path, Old: c.Old, New: c.New})
+		}
+		event.Send(event.ConfigChanged(event.Text(name, fmt.Sprintf("Configuration changed, %d values updated", len(changes))), eventChanges, nil))
 
-		// Update the config
-		*ctx.CharacterCfg = *newCfg
-		//ctx.CharacterCfg.Runtime = oldRuntimeData
+		if sup, found := mng.supervisors[name]; found {
+			sup.QueueConfig(newCfg)
+			mng.applied[name] = clone(mng.logger, name, newCfg)
+		}
 	}
 
 	return nil
 }
 
+// clone keeps a copy, the UI and the bot modify the config in place
+func clone(logger *slog.Logger, name string, cfg *config.CharacterCfg) *config.CharacterCfg {
+	c, err := cfg.Clone()
+	if err != nil {
+		logger.Error("Error copying configuration", slog.String("supervisor", name), slog.Any("error", err))
+		return cfg
+	}
+
+	return c
+}
+
 func (mng *SupervisorManager) StopAll() {
-	for _, s := range mng.supervisors {
+	for _, s := range mng.runningSupervisors() {
 		s.Stop()
 	}
 }
 
 func (mng *SupervisorManager) Stop(supervisor string) {
-
+	mng.mu.Lock()
 	s, found := mng.supervisors[supervisor]
+	cd, hasCrashDetector := mng.crashDetectors[supervisor]
+	// Delete him from the list of Supervisors
+	delete(mng.supervisors, supervisor)
+	delete(mng.crashDetectors, supervisor)
+	delete(mng.applied, supervisor)
+	mng.mu.Unlock()
+
+	// Stopped without holding the lock, the crash detector calls Stop and Start when restarting the client
 	if found {
-
-		// Stop the Supervisor
 		s.Stop()
-
-		// Delete him from the list of Supervisors
-		delete(mng.supervisors, supervisor)
-
-		if cd, ok := mng.crashDetectors[supervisor]; ok {
-			cd.Stop()
-			delete(mng.crashDetectors, supervisor)
-		}
 	}
+	if hasCrashDetector {
+		cd.Stop()
+	}
+
+	// Removed after the events sent while stopping, so the last game is still recorded
+	mng.eventListener.Unregister(statsHandlerName(supervisor))
+	mng.eventListener.Unregister(companionHandlerName(supervisor))
 }
 
 func (mng *SupervisorManager) TogglePause(supervisor string) {
-	s, found := mng.supervisors[supervisor]
+	s, found := mng.supervisor(supervisor)
 	if found {
 		s.TogglePause()
 	}
 }
 
 func (mng *SupervisorManager) Status(characterName string) Stats {
-	for name, supervisor := range mng.supervisors {
-		if name == characterName {
-			return supervisor.Stats()
-		}
+	if s, found := mng.supervisor(characterName); found {
+		return s.Stats()
 	}
 
 	return Stats{}
 }
 
 func (mng *SupervisorManager) GetData(characterName string) *game.Data {
-	for name, supervisor := range mng.supervisors {
-		if name == characterName {
-			return supervisor.GetData()
-		}
+	if s, found := mng.supervisor(characterName); found {
+		return s.GetData()
 	}
 
 	return nil
 }
 
 func (mng *SupervisorManager) GetContext(characterName string) *context.Context {
-	for name, supervisor := range mng.supervisors {
-		if name == characterName {
-			return supervisor.GetContext()
-		}
+	if s, found := mng.supervisor(characterName); found {
+		return s.GetContext()
 	}
 
 	return nil
 }
 
+func (mng *SupervisorManager) supervisor(name string) (Supervisor, bool) {
+	mng.mu.Lock()
+	defer mng.mu.Unlock()
+
+	s, found := mng.supervisors[name]
+
+	return s, found
+}
+
+func (mng *SupervisorManager) runningSupervisors() []Supervisor {
+	mng.mu.Lock()
+	defer mng.mu.Unlock()
+
+	supervisors := make([]Supervisor, 0, len(mng.supervisors))
+	for _, s := range mng.supervisors {
+		supervisors = append(supervisors, s)
+	}
+
+	return supervisors
+}
+
 func (mng *SupervisorManager) buildSupervisor(supervisorName string, logger *slog.Logger, attach bool, optionalPID uint32, optionalHWND win.HWND) (Supervisor, *game.CrashDetector, error) {
 	cfg, found := config.GetCharacter(supervisorName)
 	if !found {
@@ -219,8 +329,15 @@ func (mng *SupervisorManager) buildSupervisor(supervisorName string, logger *slo
 			return nil, nil, fmt.Errorf("pid and hwnd are required when attaching to an existing game")
 		}
 	} else {
-		var err error
-		pid, hwnd, err = game.StartGame(cfg.Username, cfg.Password, cfg.AuthMethod, cfg.AuthToken, cfg.Realm, cfg.CommandLineArgs, config.Koolo.UseCustomSettings)
+		password, err := config.ResolveSecret(cfg.Password)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading battle.net password: %w", err)
+		}
+		authToken, err := config.ResolveSecret(cfg.AuthToken)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading auth token: %w", err)
+		}
+		pid, hwnd, err = game.StartGame(cfg.Username, password, cfg.AuthMethod, authToken, cfg.Realm, cfg.CommandLineArgs, config.Koolo.UseCustomSettings)
 		if err != nil {
 			return nil, nil, fmt.Errorf("error starting game: %w", err)
 		}
@@ -262,13 +379,9 @@ func (mng *SupervisorManager) buildSupervisor(supervisorName string, logger *slo
 
 	bot := NewBot(ctx.Context)
 
-	statsHandler := NewStatsHandler(supervisorName, logger)
+	statsHandler := NewStatsHandler(supervisorName, logger, mng.history)
 	companionHandler := NewCompanionEventHandler(supervisorName, logger, cfg)
 
-	// Register event handler for stats
-	mng.eventListener.Register(statsHandler.Handle)
-	mng.eventListener.Register(companionHandler.Handle)
-
 	// Create the supervisor
 	var supervisor Supervisor
 
@@ -278,6 +391,11 @@ func (mng *SupervisorManager) buildSupervisor(supervisorName string, logger *slo
 		return nil, nil, err
 	}
 
+	// Register event handler for stats, they replace the ones of a previous start and are removed by Stop. Stats feed
+	// the history and analytics, the dispatcher waits for them instead of dropping events
+	mng.eventListener.Register(statsHandler.Handle, event.WithName(statsHandlerName(supervisorName)), event.WithOverflow(event.OverflowBlock))
+	mng.eventListener.Register(companionHandler.Handle, event.WithName(companionHandlerName(supervisorName)))
+
 	supervisor.GetContext().StopSupervisorFn = supervisor.Stop
 
 	// This function will be used to restart the client - passed to the crashDetector
@@ -356,10 +474,7 @@ func (mng *SupervisorManager) buildSupervisor(supervisorName string, logger *slo
 }
 
 func (mng *SupervisorManager) GetSupervisorStats(supervisor string) Stats {
-	if mng.supervisors[supervisor] == nil {
-		return Stats{}
-	}
-	return mng.supervisors[supervisor].Stats()
+	return mng.Status(supervisor)
 }
 
 func (mng *SupervisorManager) rearrangeWindows() {
@@ -380,7 +495,7 @@ func (mng *SupervisorManager) rearrangeWindows() {
 	)
 
 	var column, row int32
-	for _, sp := range mng.supervisors {
+	for _, sp := range mng.runningSupervisors() {
 		// reminder that columns are vertical (they go up and down) and rows are horizontal (they go left and right)
 		if column > maxColumns {
 			column = 0
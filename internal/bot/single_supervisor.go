@@ -76,6 +76,9 @@ func (s *SinglePlayerSupervisor) Start() error {
 		default:
 		}
 
+		// Between games, the only safe point to swap the config
+		s.applyQueuedConfig()
+
 		if firstRun {
 			if err = s.waitUntilCharacterSelectionScreen(); err != nil {
 				return fmt.Errorf("error waiting for character selection screen: %w", err)
@@ -8,6 +8,7 @@ import (
 
 	"github.com/hectorgimenez/d2go/pkg/data"
 	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
 )
 
 const (
@@ -21,15 +22,17 @@ const (
 type SupervisorStatus string
 
 type StatsHandler struct {
-	stats  *Stats
-	name   string
-	logger *slog.Logger
+	stats   *Stats
+	name    string
+	logger  *slog.Logger
+	history *history.Store
 }
 
-func NewStatsHandler(name string, logger *slog.Logger) *StatsHandler {
+func NewStatsHandler(name string, logger *slog.Logger, historyStore *history.Store) *StatsHandler {
 	return &StatsHandler{
-		name:   name,
-		logger: logger,
+		name:    name,
+		logger:  logger,
+		history: historyStore,
 		stats: &Stats{
 			SupervisorStatus: Starting,
 			StartedAt:        time.Now(),
@@ -37,12 +40,19 @@ func NewStatsHandler(name string, logger *slog.Logger) *StatsHandler {
 	}
 }
 
+// statsHandlerName is the name the stats handler of the supervisor is registered with on the event listener
+func statsHandlerName(supervisor string) string {
+	return "stats-" + supervisor
+}
+
 func (h *StatsHandler) Handle(_ context.Context, e event.Event) error {
 	// Only handle events from the supervisor
 	if !strings.EqualFold(e.Supervisor(), h.name) {
 		return nil
 	}
 
+	h.persist(e)
+
 	switch evt := e.(type) {
 	case event.GameCreatedEvent:
 		h.stats.Games = append(h.stats.Games, GameStats{
@@ -80,6 +90,11 @@ func (h *StatsHandler) Handle(_ context.Context, e event.Event) error {
 
 	case event.ItemStashedEvent:
 		h.stats.Drops = append(h.stats.Drops, evt.Item)
+		// Attribute the drop to the current (or last finished) run, used for drops per hour analytics
+		if len(h.stats.Games) > 0 && len(h.stats.Games[len(h.stats.Games)-1].Runs) > 0 {
+			lastRun := &h.stats.Games[len(h.stats.Games)-1].Runs[len(h.stats.Games[len(h.stats.Games)-1].Runs)-1]
+			lastRun.Items = append(lastRun.Items, evt.Item.Item)
+		}
 
 	case event.UsedPotionEvent:
 		if len(h.stats.Games) > 0 && len(h.stats.Games[len(h.stats.Games)-1].Runs) > 0 {
@@ -91,6 +106,22 @@ func (h *StatsHandler) Handle(_ context.Context, e event.Event) error {
 	return nil
 }
 
+// persist appends the event to the history store, failures are only logged to not break the stats tracking
+func (h *StatsHandler) persist(e event.Event) {
+	if h.history == nil {
+		return
+	}
+
+	rec, ok := history.FromEvent(e)
+	if !ok {
+		return
+	}
+
+	if err := h.history.Append(rec); err != nil {
+		h.logger.Error("Failed to persist history record", slog.Any("error", err))
+	}
+}
+
 func (h *StatsHandler) Stats() Stats {
 	return *h.stats
 }
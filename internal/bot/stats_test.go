@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+)
+
+func TestStatsHandlerRestartWritesRecordsOnce(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := history.NewStore(t.TempDir(), 0)
+	l := event.NewListener(logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Listen(ctx)
+
+	// Started, stopped and started again like the manager does
+	for _, game := range []string{"game-1", "game-2"} {
+		h := NewStatsHandler("char", logger, store)
+		l.Register(h.Handle, event.WithName(statsHandlerName("char")), event.WithOverflow(event.OverflowBlock))
+		event.Send(event.GameCreated(event.Text("char", "New game created"), game, ""))
+		event.Send(event.GameFinished(event.Text("char", "Game finished successfully"), event.FinishedOK))
+		l.Unregister(statsHandlerName("char"))
+	}
+
+	var records []history.Record
+	deadline := time.Now().Add(5 * time.Second)
+	for len(records) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		if records, err = store.Query(history.Query{Supervisor: "char"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Give a duplicated handler the time to write its copy
+	time.Sleep(100 * time.Millisecond)
+	records, err := store.Query(history.Query{Supervisor: "char"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := make(map[string]int)
+	finished := 0
+	for _, rec := range records {
+		switch rec.Type {
+		case history.GameCreated:
+			created[rec.GameName]++
+		case history.GameFinished:
+			finished++
+		}
+	}
+	if len(records) != 4 || created["game-1"] != 1 || created["game-2"] != 1 || finished != 2 {
+		t.Errorf("expected every record written once, got %d records: %+v", len(records), records)
+	}
+	for _, s := range l.HandlerStats() {
+		if s.Name == statsHandlerName("char") {
+			t.Error("the stats handler must be removed once unregistered")
+		}
+	}
+}
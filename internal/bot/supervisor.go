@@ -7,8 +7,10 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/hectorgimenez/koolo/internal/config"
 	ct "github.com/hectorgimenez/koolo/internal/context"
 	"github.com/hectorgimenez/koolo/internal/event"
 	"github.com/hectorgimenez/koolo/internal/game"
@@ -26,6 +28,7 @@ type Supervisor interface {
 	SetWindowPosition(x, y int)
 	GetData() *game.Data
 	GetContext() *ct.Context
+	QueueConfig(cfg *config.CharacterCfg)
 }
 
 type baseSupervisor struct {
@@ -33,6 +36,7 @@ type baseSupervisor struct {
 	name         string
 	statsHandler *StatsHandler
 	cancelFn     context.CancelFunc
+	pendingCfg   atomic.Pointer[config.CharacterCfg]
 }
 
 func newBaseSupervisor(
@@ -69,6 +73,34 @@ func (s *baseSupervisor) TogglePause() {
 	}
 }
 
+// QueueConfig replaces the supervisor config at the next game boundary, the current game keeps using the old one
+func (s *baseSupervisor) QueueConfig(cfg *config.CharacterCfg) {
+	s.pendingCfg.Store(cfg)
+}
+
+// applyQueuedConfig must only be called between games, when the bot is not reading the config
+func (s *baseSupervisor) applyQueuedConfig() {
+	cfg := s.pendingCfg.Swap(nil)
+	if cfg == nil {
+		return
+	}
+
+	current := s.bot.ctx.CharacterCfg
+	newCfg := *cfg
+	// Runtime state is not part of the config files
+	newCfg.Game.PublicGameCounter = current.Game.PublicGameCounter
+	newCfg.Runtime.Drops = current.Runtime.Drops
+
+	if !strings.EqualFold(newCfg.Character.Class, current.Character.Class) {
+		s.bot.ctx.Logger.Warn("Character class changed, restart the supervisor to apply it", slog.String("configuration", s.name))
+		newCfg.Character.Class = current.Character.Class
+	}
+
+	// Updated in place, the game reader, pather and the rest of components share the same pointer
+	*current = newCfg
+	s.bot.ctx.Logger.Info("New configuration applied", slog.String("configuration", s.name))
+}
+
 func (s *baseSupervisor) Stop() {
 	s.bot.ctx.Logger.Info("Stopping...", slog.String("configuration", s.name))
 	if s.cancelFn != nil {
@@ -10,6 +10,7 @@ import (
 	"github.com/hectorgimenez/koolo/internal/context"
 )
 
+// BuildCharacter classes must be kept in sync with config.AvailableClasses and config.AvailableLevelingClasses
 func BuildCharacter(ctx *context.Context) (context.Character, error) {
 	bc := BaseCharacter{
 		Context: ctx,
@@ -0,0 +1,429 @@
+package config
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleFormat is the archive format of a config bundle
+type BundleFormat string
+
+const (
+	BundleZip BundleFormat = "zip"
+	BundleTar BundleFormat = "tar" // gzipped
+
+	bundleManifest = "koolo-bundle.yaml"
+	bundleConfig   = "config.yaml"
+	bundleSettings = "Settings.json"
+
+	// maxBundleSize protects from absurd uploads, a character with every pickit file is a few hundred KB
+	maxBundleSize = 50 << 20
+)
+
+// bundleDirs are the character folders included in a bundle
+var bundleDirs = []string{"pickit", "pickit_leveling"}
+
+// BundleManifest describes the bundle content, it's informative only
+type BundleManifest struct {
+	Character     string    `yaml:"character"`
+	Class         string    `yaml:"class"`
+	ConfigVersion int       `yaml:"configVersion"`
+	KooloVersion  string    `yaml:"kooloVersion"`
+	ExportedAt    time.Time `yaml:"exportedAt"`
+}
+
+// BundleSecrets are the values stripped on export, they must be provided again on import
+type BundleSecrets struct {
+	Password  string
+	AuthToken string
+}
+
+// ImportOptions configures ImportBundle
+type ImportOptions struct {
+	Secrets BundleSecrets
+	// SecretProvider stores the secrets in this provider and writes references to them, instead of plain text values.
+	// Secrets given as a ${provider:name} reference are written as they are.
+	SecretProvider string
+	// ReplaceSettings overwrites config/Settings.json with the bundle one, it's shared by every character
+	ReplaceSettings bool
+}
+
+// ExportBundle writes a bundle with the character config.yaml, without secrets and with the profile values resolved so
+// it doesn't depend on any other file, its pickit folders and config/Settings.json
+func ExportBundle(name string, format BundleFormat, w io.Writer) error {
+	cfg, found := GetCharacter(name)
+	if !found {
+		return fmt.Errorf("character %s not found", name)
+	}
+
+	exported, err := cfg.Clone()
+	if err != nil {
+		return err
+	}
+	exported.Extends = ""
+	exported.Password = ""
+	exported.AuthToken = ""
+	content, err := yaml.Marshal(exported)
+	if err != nil {
+		return fmt.Errorf("error encoding character config: %w", err)
+	}
+
+	manifest, err := yaml.Marshal(BundleManifest{
+		Character:     name,
+		Class:         cfg.Character.Class,
+		ConfigVersion: cfg.ConfigVersion,
+		KooloVersion:  Version,
+		ExportedAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		bundleManifest: manifest,
+		bundleConfig:   content,
+	}
+	if settings, err := os.ReadFile(filepath.Join("config", bundleSettings)); err == nil {
+		files[bundleSettings] = settings
+	}
+	for _, dir := range bundleDirs {
+		root := filepath.Join("config", name, dir)
+		err = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[path.Join(dir, filepath.ToSlash(rel))] = b
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", root, err)
+		}
+	}
+
+	switch format {
+	case BundleZip, "":
+		return writeZip(w, files)
+	case BundleTar:
+		return writeTar(w, files)
+	default:
+		return fmt.Errorf("unknown bundle format %s", format)
+	}
+}
+
+// ImportBundle creates the character name from a bundle created by ExportBundle, the format is detected from the
+// content. The config is validated, and migrated when it's from an older version, before anything is written.
+func ImportBundle(name string, r io.Reader, opts ImportOptions) (BundleManifest, error) {
+	if err := validCharacterName(name); err != nil {
+		return BundleManifest{}, err
+	}
+	if _, err := os.Stat(filepath.Join("config", name)); !os.IsNotExist(err) {
+		return BundleManifest{}, errors.New("configuration with that name already exists")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxBundleSize+1))
+	if err != nil {
+		return BundleManifest{}, fmt.Errorf("error reading bundle: %w", err)
+	}
+	if len(data) > maxBundleSize {
+		return BundleManifest{}, fmt.Errorf("bundle is bigger than %d MB", maxBundleSize>>20)
+	}
+
+	files, err := readBundle(data)
+	if err != nil {
+		return BundleManifest{}, err
+	}
+
+	var manifest BundleManifest
+	if b, found := files[bundleManifest]; found {
+		if err = yaml.Unmarshal(b, &manifest); err != nil {
+			return BundleManifest{}, fmt.Errorf("invalid bundle manifest: %w", err)
+		}
+	}
+
+	content, found := files[bundleConfig]
+	if !found {
+		return BundleManifest{}, fmt.Errorf("%s not found in the bundle", bundleConfig)
+	}
+	bundleFile := "bundle/" + bundleConfig
+	content, _, err = migrateConfig(bundleFile, content, readTemplate("config"))
+	if err != nil {
+		return BundleManifest{}, err
+	}
+
+	cfg := &CharacterCfg{}
+	if err = DecodeCharacterConfig(bundleFile, content, cfg); err != nil {
+		return BundleManifest{}, err
+	}
+
+	var stored []string
+	// Everything written is removed again if the character can't be loaded
+	rollback := func() {
+		os.RemoveAll(filepath.Join("config", name))
+		if store, err := SecretStoreFor(opts.SecretProvider); err == nil {
+			for _, secret := range stored {
+				store.Delete(secret)
+			}
+		}
+	}
+	storeSecret := func(secret, value string) (string, error) {
+		if value == "" || opts.SecretProvider == "" {
+			return value, nil
+		}
+		if _, _, found := ParseSecretRef(value); found {
+			return value, nil
+		}
+		store, err := SecretStoreFor(opts.SecretProvider)
+		if err != nil {
+			return "", err
+		}
+		if err = store.Set(secret, value); err != nil {
+			return "", fmt.Errorf("error storing %s: %w", secret, err)
+		}
+		stored = append(stored, secret)
+
+		return SecretRef(opts.SecretProvider, secret), nil
+	}
+	if cfg.Password, err = storeSecret(name+".password", opts.Secrets.Password); err != nil {
+		rollback()
+		return BundleManifest{}, err
+	}
+	if cfg.AuthToken, err = storeSecret(name+".authToken", opts.Secrets.AuthToken); err != nil {
+		rollback()
+		return BundleManifest{}, err
+	}
+	if content, err = yaml.Marshal(cfg); err != nil {
+		rollback()
+		return BundleManifest{}, fmt.Errorf("error encoding character config: %w", err)
+	}
+
+	settingsFile := filepath.Join("config", bundleSettings)
+	previousSettings, settingsErr := os.ReadFile(settingsFile)
+	restoreSettings := func() {
+		if _, replaced := files[bundleSettings]; !replaced {
+			return
+		}
+		if settingsErr == nil {
+			os.WriteFile(settingsFile, previousSettings, 0644)
+		} else if os.IsNotExist(settingsErr) {
+			os.Remove(settingsFile)
+		}
+	}
+
+	// The template provides anything missing in the bundle, like pickit_leveling for non leveling characters
+	if err = copyTemplate(name); err != nil {
+		rollback()
+		return BundleManifest{}, err
+	}
+
+	if err = writeBundleFiles(name, files, content, opts.ReplaceSettings); err != nil {
+		rollback()
+		restoreSettings()
+		return BundleManifest{}, err
+	}
+
+	err = Load()
+	if errs, invalid := InvalidCharacters()[name]; err == nil && invalid {
+		err = errs
+	}
+	if err != nil {
+		rollback()
+		restoreSettings()
+		// Back to the config before the import
+		Load()
+		return BundleManifest{}, fmt.Errorf("error loading the imported config: %w", err)
+	}
+
+	return manifest, nil
+}
+
+func writeBundleFiles(name string, files map[string][]byte, content []byte, replaceSettings bool) error {
+	charDir := filepath.Join("config", name)
+	for _, dir := range bundleDirs {
+		if !bundleHasDir(files, dir) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(charDir, dir)); err != nil {
+			return err
+		}
+	}
+
+	for p, b := range files {
+		dst := ""
+		switch {
+		case p == bundleConfig || p == bundleManifest:
+			continue
+		case p == bundleSettings:
+			if _, err := os.Stat(filepath.Join("config", bundleSettings)); err == nil && !replaceSettings {
+				continue
+			}
+			dst = filepath.Join("config", bundleSettings)
+		default:
+			dst = filepath.Join(charDir, filepath.FromSlash(p))
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, b, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", dst, err)
+		}
+	}
+
+	return os.WriteFile(filepath.Join(charDir, bundleConfig), content, 0644)
+}
+
+func bundleHasDir(files map[string][]byte, dir string) bool {
+	for p := range files {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readBundle returns the bundle files by their slash separated path, anything outside the expected files is rejected
+func readBundle(data []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	add := func(name string, r io.Reader) error {
+		name = path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+		if !allowedBundlePath(name) {
+			return fmt.Errorf("unexpected file %s in bundle", name)
+		}
+		b, err := io.ReadAll(io.LimitReader(r, maxBundleSize))
+		if err != nil {
+			return fmt.Errorf("error reading %s from bundle: %w", name, err)
+		}
+		files[name] = b
+
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zip bundle: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s from bundle: %w", f.Name, err)
+			}
+			err = add(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar bundle: %w", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			h, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid tar bundle: %w", err)
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err = add(h.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("unknown bundle format, expected zip or tar.gz")
+	}
+
+	return files, nil
+}
+
+func allowedBundlePath(name string) bool {
+	if name == bundleManifest || name == bundleConfig || name == bundleSettings {
+		return true
+	}
+	if strings.HasPrefix(name, "/") || strings.Contains(name, "..") {
+		return false
+	}
+	for _, dir := range bundleDirs {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeZip(w io.Writer, files map[string][]byte) error {
+	zw := zip.NewWriter(w)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		b := files[name]
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeTar(w io.Writer, files map[string][]byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		b := files[name]
+		h := &tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
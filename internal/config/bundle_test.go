@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type memorySecrets map[string]string
+
+func (s memorySecrets) Get(name string) (string, error) {
+	value, found := s[name]
+	if !found {
+		return "", ErrSecretNotFound
+	}
+
+	return value, nil
+}
+
+func (s memorySecrets) Set(name, value string) error {
+	s[name] = value
+	return nil
+}
+
+func (s memorySecrets) Delete(name string) error {
+	delete(s, name)
+	return nil
+}
+
+func (s memorySecrets) List() ([]string, error) {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func exportTestBundle(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	useConfigDir(t, map[string]string{
+		"config/koolo.yaml":            "debug:\n  log: true\n",
+		"config/template/config.yaml":  testCharacterConfig,
+		"config/template/pickit/a.nip": "[name] == ring\n",
+		"config/char/config.yaml":      testCharacterConfig,
+		"config/char/pickit/a.nip":     "[name] == ring\n",
+	})
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle bytes.Buffer
+	if err := ExportBundle("char", BundleZip, &bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	return &bundle
+}
+
+func TestImportBundleStoresSecrets(t *testing.T) {
+	bundle := exportTestBundle(t)
+	store := memorySecrets{}
+	RegisterSecretProvider("memory", store)
+
+	_, err := ImportBundle("imported", bundle, ImportOptions{
+		Secrets:        BundleSecrets{Password: "hunter2", AuthToken: "${env:KOOLO_TOKEN}"},
+		SecretProvider: "memory",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join("config", "imported", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "hunter2") {
+		t.Errorf("plain text password written to the config:\n%s", content)
+	}
+	cfg, found := GetCharacter("imported")
+	if !found {
+		t.Fatalf("imported character not loaded: %v", InvalidCharacters())
+	}
+	if cfg.Password != "${memory:imported.password}" || store["imported.password"] != "hunter2" {
+		t.Errorf("password not stored in the provider, config value %s", cfg.Password)
+	}
+	// References are kept as they are
+	if cfg.AuthToken != "${env:KOOLO_TOKEN}" || len(store) != 1 {
+		t.Errorf("auth token reference changed to %s", cfg.AuthToken)
+	}
+}
+
+func TestImportBundleRollsBack(t *testing.T) {
+	exportTestBundle(t)
+	// The config is valid, but the pickit rules can't be loaded
+	var bundle bytes.Buffer
+	err := writeZip(&bundle, map[string][]byte{
+		bundleConfig:   []byte(testCharacterConfig),
+		"pickit/a.nip": []byte("[name] ==\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memorySecrets{}
+	RegisterSecretProvider("memory", store)
+
+	_, err = ImportBundle("imported", &bundle, ImportOptions{
+		Secrets:        BundleSecrets{Password: "hunter2"},
+		SecretProvider: "memory",
+	})
+	if err == nil {
+		t.Fatal("expected the import to fail")
+	}
+
+	if _, err = os.Stat(filepath.Join("config", "imported")); !os.IsNotExist(err) {
+		t.Error("the imported files should be removed")
+	}
+	if len(store) > 0 {
+		t.Errorf("the stored secrets should be removed, got %v", store)
+	}
+	if _, invalid := InvalidCharacters()["imported"]; invalid {
+		t.Error("the config should be loaded again without the imported character")
+	}
+}
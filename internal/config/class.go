@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/hectorgimenez/d2go/pkg/data/stat"
+)
+
+// ClassImmunities are the immunities stopping the main damage of every class in AvailableClasses and
+// AvailableLevelingClasses, a monster immune to all of them can't be killed by the character. Classes relying on
+// physical damage have none.
+var ClassImmunities = map[string][]stat.Resist{
+	"sorceress":    {stat.ColdImmune},
+	"fireballsorc": {stat.FireImmune},
+	"nova":         {stat.LightImmune},
+	"hydraorb":     {stat.ColdImmune, stat.FireImmune},
+	"lightsorc":    {stat.LightImmune},
+	"hammerdin":    {stat.MagicImmune},
+	"foh":          {stat.LightImmune},
+	"trapsin":      {stat.LightImmune},
+	"mosaic":       {stat.LightImmune, stat.ColdImmune, stat.FireImmune},
+	"winddruid":    nil,
+	"javazon":      {stat.LightImmune},
+	"berserker":    {stat.MagicImmune},
+
+	"sorceress_leveling": {stat.ColdImmune, stat.FireImmune},
+	"necromancer":        {stat.MagicImmune},
+	"paladin":            {stat.MagicImmune},
+	"assassin":           {stat.FireImmune, stat.LightImmune},
+	"druid_leveling":     nil,
+}
+
+// Immunities returns the ClassImmunities of the character class
+func (c *CharacterCfg) Immunities() []stat.Resist {
+	return ClassImmunities[strings.ToLower(c.Character.Class)]
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hectorgimenez/d2go/pkg/data/stat"
+)
+
+func TestClassImmunities(t *testing.T) {
+	for _, class := range append(slices.Clone(AvailableClasses), AvailableLevelingClasses...) {
+		if _, found := ClassImmunities[class]; !found {
+			t.Errorf("missing immunities of class %s", class)
+		}
+	}
+	for class := range ClassImmunities {
+		if !slices.Contains(AvailableClasses, class) && !slices.Contains(AvailableLevelingClasses, class) {
+			t.Errorf("immunities of unknown class %s", class)
+		}
+	}
+
+	cfg := &CharacterCfg{}
+	cfg.Character.Class = "Hammerdin"
+	if immunities := cfg.Immunities(); len(immunities) != 1 || immunities[0] != stat.MagicImmune {
+		t.Errorf("unexpected hammerdin immunities %v", immunities)
+	}
+}
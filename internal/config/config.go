@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/pickit"
 	"github.com/hectorgimenez/koolo/internal/utils"
 
 	"os"
@@ -29,18 +30,42 @@ var (
 	Koolo      *KooloCfg
 	Characters map[string]*CharacterCfg
 	Version    = "dev"
+
+	// invalidCharacters are the characters whose config failed to load the last time, see Load
+	invalidCharacters map[string]ValidationErrors
 )
 
 type KooloCfg struct {
 	Debug struct {
-		Log         bool `yaml:"log"`
-		Screenshots bool `yaml:"screenshots"`
-		RenderMap   bool `yaml:"renderMap"`
+		Log          bool `yaml:"log"`
+		Screenshots  bool `yaml:"screenshots"`
+		RenderMap    bool `yaml:"renderMap"`
+		RecordEvents bool `yaml:"recordEvents"`
 	} `yaml:"debug"`
 	FirstRun              bool   `yaml:"firstRun"`
 	UseCustomSettings     bool   `yaml:"useCustomSettings"`
 	GameWindowArrangement bool   `yaml:"gameWindowArrangement"`
 	LogSaveDirectory      string `yaml:"logSaveDirectory"`
+	HistoryRetentionDays  int    `yaml:"historyRetentionDays"`
+	Server                struct {
+		Headless bool   `yaml:"headless"`
+		Address  string `yaml:"address"`
+		Port     int    `yaml:"port"`
+		// AllowedOrigins are the web pages allowed to open the event stream besides the Koolo UI itself
+		AllowedOrigins []string `yaml:"allowedOrigins"`
+		Auth           struct {
+			Enabled      bool       `yaml:"enabled"`
+			Username     string     `yaml:"username"`
+			PasswordHash string     `yaml:"passwordHash"`
+			Tokens       []APIToken `yaml:"tokens"`
+			SessionHours int        `yaml:"sessionHours"`
+		} `yaml:"auth"`
+		TLS struct {
+			Enabled  bool   `yaml:"enabled"`
+			CertFile string `yaml:"certFile"`
+			KeyFile  string `yaml:"keyFile"`
+		} `yaml:"tls"`
+	} `yaml:"server"`
 	D2LoDPath             string `yaml:"D2LoDPath"`
 	D2RPath               string `yaml:"D2RPath"`
 	CentralizedPickitPath string `yaml:"centralizedPickitPath"`
@@ -60,6 +85,28 @@ type KooloCfg struct {
 		ChatID  int64  `yaml:"chatId"`
 		Token   string `yaml:"token"`
 	}
+	Webhooks struct {
+		Enabled   bool              `yaml:"enabled"`
+		QueueDir  string            `yaml:"queueDir"`
+		Endpoints []WebhookEndpoint `yaml:"endpoints"`
+	} `yaml:"webhooks"`
+}
+
+// APIToken is a bearer token accepted by the web server, only the SHA-256 of the token is stored
+type APIToken struct {
+	Name string `yaml:"name"`
+	Hash string `yaml:"hash"`
+}
+
+type WebhookEndpoint struct {
+	Name              string            `yaml:"name"`
+	URL               string            `yaml:"url"`
+	Secret            string            `yaml:"secret"`
+	Events            []string          `yaml:"events"`
+	Supervisors       []string          `yaml:"supervisors"`
+	IncludeScreenshot bool              `yaml:"includeScreenshot"`
+	Headers           map[string]string `yaml:"headers"`
+	MaxRetries        int               `yaml:"maxRetries"`
 }
 
 type Day struct {
@@ -78,6 +125,9 @@ type TimeRange struct {
 }
 
 type CharacterCfg struct {
+	// ConfigVersion is the schema version of the file, older files are migrated when loaded, see migrate.go
+	ConfigVersion        int    `yaml:"configVersion"`
+	Extends              string `yaml:"extends,omitempty"`
 	MaxGameLength        int    `yaml:"maxGameLength"`
 	Username             string `yaml:"username"`
 	Password             string `yaml:"password"`
@@ -290,6 +340,10 @@ type CharacterCfg struct {
 	Runtime struct {
 		Rules nip.Rules   `yaml:"-"`
 		Drops []data.Item `yaml:"-"`
+		// Pickit is where each of the Rules comes from, and the duplicated rules that were skipped
+		Pickit *pickit.RuleSet `yaml:"-"`
+		// Inherited lists the values coming from the Extends profile chain
+		Inherited []InheritedValue `yaml:"-"`
 	} `yaml:"-"`
 }
 
@@ -312,6 +366,17 @@ func GetCharacters() map[string]*CharacterCfg {
 	return copy
 }
 
+// InvalidCharacters returns the errors of the character configs that failed to load, by character name
+func InvalidCharacters() map[string]ValidationErrors {
+	cfgMux.RLock()
+	defer cfgMux.RUnlock()
+	copy := make(map[string]ValidationErrors, len(invalidCharacters))
+	for k, v := range invalidCharacters {
+		copy[k] = v
+	}
+	return copy
+}
+
 func (bm BeltColumns) Total(potionType data.PotionType) int {
 	typeString := ""
 	switch potionType {
@@ -333,10 +398,14 @@ func (bm BeltColumns) Total(potionType data.PotionType) int {
 	return total
 }
 
+// Load reads koolo.yaml and every character config. A broken koolo.yaml is rejected and the current configuration is
+// kept. A broken character config is skipped and reported by InvalidCharacters, the rest of the characters are loaded,
+// and a character already loaded keeps its previous config until the file is fixed.
 func Load() error {
 	cfgMux.Lock()
 	defer cfgMux.Unlock()
-	Characters = make(map[string]*CharacterCfg)
+	koolo := &KooloCfg{}
+	characters := make(map[string]*CharacterCfg)
 
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -355,7 +424,7 @@ func Load() error {
 	defer r.Close()
 
 	d := yaml.NewDecoder(r)
-	if err = d.Decode(&Koolo); err != nil {
+	if err = d.Decode(koolo); err != nil {
 		return fmt.Errorf("error reading config %s: %w", kooloPath, err)
 	}
 
@@ -365,25 +434,39 @@ func Load() error {
 		return fmt.Errorf("error reading config directory %s: %w", configDir, err)
 	}
 
+	// Missing keys of old character configs are filled with the template values when they are migrated
+	template := readTemplate(configDir)
+
+	var validationErrs ValidationErrors
+	invalid := make(map[string]ValidationErrors)
+
+	// Secret references are resolved when they are used, but a wrong one is reported while loading
+	for _, verr := range append([]*ValidationError{
+		validateSecretRef(kooloPath, "discord.token", koolo.Discord.Token),
+		validateSecretRef(kooloPath, "telegram.token", koolo.Telegram.Token),
+	}, validateWebhookSecretRefs(kooloPath, koolo.Webhooks.Endpoints)...) {
+		if verr != nil {
+			validationErrs = append(validationErrs, *verr)
+		}
+	}
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() || entry.Name() == ProfilesDir {
 			continue
 		}
 
 		charCfg := CharacterCfg{}
 
 		charConfigPath := getAbsPath(filepath.Join("config", entry.Name(), "config.yaml"))
-		r, err = os.Open(charConfigPath)
-		if err != nil {
-			return fmt.Errorf("error loading config.yaml: %w", err)
-		}
-
-		d := yaml.NewDecoder(r)
-		if err = d.Decode(&charCfg); err != nil {
-			_ = r.Close()
-			return fmt.Errorf("error reading %s character config: %w", charConfigPath, err)
+		charErrs := characterErrors(charConfigPath, loadCharacterConfig(configDir, charConfigPath, &charCfg, template))
+
+		for _, verr := range []*ValidationError{
+			validateSecretRef(charConfigPath, "password", charCfg.Password),
+			validateSecretRef(charConfigPath, "authToken", charCfg.AuthToken),
+		} {
+			if verr != nil {
+				charErrs = append(charErrs, *verr)
+			}
 		}
-		_ = r.Close()
 
 		charCfg.ConfigFolderName = entry.Name()
 
@@ -391,111 +474,110 @@ func Load() error {
 			charCfg.Game.MaxFailedMenuAttempts = 10
 		}
 
-		var pickitPath string
-		if Koolo.CentralizedPickitPath != "" && charCfg.UseCentralizedPickit {
-			if _, err := os.Stat(Koolo.CentralizedPickitPath); os.IsNotExist(err) {
-				utils.ShowDialog("Error loading pickit rules for "+entry.Name(), "The centralized pickit path does not exist: "+Koolo.CentralizedPickitPath+"\nPlease check your Koolo settings.\nFalling back to local pickit.")
-				pickitPath = getAbsPath(filepath.Join("config", entry.Name(), "pickit")) + "\\"
-			} else {
-				pickitPath = Koolo.CentralizedPickitPath + "\\"
+		if len(charErrs) == 0 {
+			ruleSet, err := loadPickitRules(koolo, &charCfg, configDir)
+			if err == nil {
+				charCfg.Runtime.Rules = ruleSet.Rules
+				charCfg.Runtime.Pickit = ruleSet
 			}
-		} else {
-			pickitPath = getAbsPath(filepath.Join("config", entry.Name(), "pickit")) + "\\"
-		}
-
-		rules, err := nip.ReadDir(pickitPath)
-		if err != nil {
-			return fmt.Errorf("error reading pickit directory %s: %w", pickitPath, err)
+			charErrs = characterErrors(charConfigPath, err)
 		}
 
-		// Load the leveling pickit rules
-		if len(charCfg.Game.Runs) > 0 && charCfg.Game.Runs[0] == "leveling" {
-			levelingPickitPath := getAbsPath(filepath.Join("config", entry.Name(), "pickit_leveling"))
-			classPickitFile := filepath.Join(levelingPickitPath, charCfg.Character.Class+".nip")
-			questPickitFile := filepath.Join(levelingPickitPath, "quest.nip")
-
-			// Try to load the class-specific nip file first
-			if _, errStat := os.Stat(classPickitFile); errStat == nil {
-				classRules, err := readSinglePickitFile(classPickitFile)
-				if err != nil {
-					return err
-				}
-				rules = append(rules, classRules...)
-			} else {
-				// Fallback: if no class file, load all files EXCEPT quest.nip (to avoid duplicates)
-				if _, err := os.Stat(levelingPickitPath); !os.IsNotExist(err) {
-					allLevelingFiles, err := os.ReadDir(levelingPickitPath)
-					if err != nil {
-						return fmt.Errorf("could not read pickit_leveling dir: %w", err)
-					}
-
-					// Create a temporary directory for all non-class, non-quest files
-					tempDir := filepath.Join(levelingPickitPath, "temp_fallback")
-					if err := os.MkdirAll(tempDir, 0755); err == nil {
-						for _, file := range allLevelingFiles {
-							// Exclude quest.nip since it will be loaded separately
-							if file.Name() != "quest.nip" && strings.HasSuffix(file.Name(), ".nip") {
-								sourceData, _ := os.ReadFile(filepath.Join(levelingPickitPath, file.Name()))
-								os.WriteFile(filepath.Join(tempDir, file.Name()), sourceData, 0644)
-							}
-						}
-
-						fallbackRules, _ := nip.ReadDir(tempDir + "\\")
-						rules = append(rules, fallbackRules...)
-						os.RemoveAll(tempDir)
-					}
-				}
-			}
-
-			// Separately, try to load quest.nip and append its rules
-			if _, errStat := os.Stat(questPickitFile); errStat == nil {
-				questRules, err := readSinglePickitFile(questPickitFile)
-				if err != nil {
-					return err
-				}
-				rules = append(rules, questRules...)
+		if len(charErrs) > 0 {
+			invalid[entry.Name()] = charErrs
+			if previous, found := Characters[entry.Name()]; found {
+				characters[entry.Name()] = previous
 			}
+			continue
 		}
 
-		charCfg.Runtime.Rules = rules
-		Characters[entry.Name()] = &charCfg
+		charCfg.Validate()
+		characters[entry.Name()] = &charCfg
 	}
 
-	for _, charCfg := range Characters {
-		charCfg.Validate()
+	if len(validationErrs) > 0 {
+		return validationErrs
 	}
 
+	Koolo = koolo
+	Characters = characters
+	invalidCharacters = invalid
+
 	return nil
 }
 
-// Helper function to read a single NIP file using the temp directory workaround
-func readSinglePickitFile(filePath string) (nip.Rules, error) {
-	tempDir := filepath.Join(filepath.Dir(filePath), "temp_single_read")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create temp pickit directory: %w", err)
+// characterErrors returns the errors of a character config as ValidationErrors, errors that are not validation ones,
+// like a YAML syntax error or a broken pickit rule, are reported for the whole file
+func characterErrors(path string, err error) ValidationErrors {
+	if err == nil {
+		return nil
 	}
-	defer os.RemoveAll(tempDir)
 
-	destFile := filepath.Join(tempDir, filepath.Base(filePath))
-	sourceData, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read source pickit file %s: %w", filePath, err)
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		return verrs
 	}
-	if err := os.WriteFile(destFile, sourceData, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write to temp pickit file: %w", err)
+
+	return ValidationErrors{{File: path, Message: err.Error()}}
+}
+
+// loadPickitRules loads the character pickit rules, in this order: the character or the centralized pickit folder,
+// the leveling rules (the class file, or every leveling file when there is no class one) and the quest rules
+func loadPickitRules(koolo *KooloCfg, cfg *CharacterCfg, configDir string) (*pickit.RuleSet, error) {
+	ruleSet := pickit.NewRuleSet()
+	charDir := filepath.Join(configDir, cfg.ConfigFolderName)
+
+	pickitPath, layer := filepath.Join(charDir, "pickit"), pickit.LayerBase
+	if koolo.CentralizedPickitPath != "" && cfg.UseCentralizedPickit {
+		if _, err := os.Stat(koolo.CentralizedPickitPath); os.IsNotExist(err) {
+			utils.ShowDialog("Error loading pickit rules for "+cfg.ConfigFolderName, "The centralized pickit path does not exist: "+koolo.CentralizedPickitPath+"\nPlease check your Koolo settings.\nFalling back to local pickit.")
+		} else {
+			pickitPath, layer = koolo.CentralizedPickitPath, pickit.LayerCentralized
+		}
+	}
+	if err := ruleSet.AddDir(layer, pickitPath); err != nil {
+		return nil, err
 	}
 
-	rules, err := nip.ReadDir(tempDir + "\\")
-	if err != nil {
-		return nil, fmt.Errorf("error reading from temp pickit directory %s: %w", tempDir, err)
+	if len(cfg.Game.Runs) == 0 || cfg.Game.Runs[0] != "leveling" {
+		return ruleSet, nil
 	}
 
-	return rules, nil
+	levelingPickitPath := filepath.Join(charDir, "pickit_leveling")
+	classPickitFile := filepath.Join(levelingPickitPath, cfg.Character.Class+".nip")
+	questPickitFile := filepath.Join(levelingPickitPath, "quest.nip")
+
+	if _, err := os.Stat(classPickitFile); err == nil {
+		if err = ruleSet.AddFile(pickit.LayerClass, classPickitFile); err != nil {
+			return nil, err
+		}
+	} else if _, err = os.Stat(levelingPickitPath); err == nil {
+		// quest.nip is loaded below in its own layer
+		if err = ruleSet.AddDir(pickit.LayerLeveling, levelingPickitPath, "quest.nip"); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := os.Stat(questPickitFile); err == nil {
+		if err = ruleSet.AddFile(pickit.LayerQuest, questPickitFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return ruleSet, nil
 }
 
 func CreateFromTemplate(name string) error {
-	if name == "" {
-		return errors.New("name cannot be empty")
+	if err := copyTemplate(name); err != nil {
+		return err
+	}
+
+	return Load()
+}
+
+func copyTemplate(name string) error {
+	if err := validCharacterName(name); err != nil {
+		return err
 	}
 
 	if _, err := os.Stat("config/" + name); !os.IsNotExist(err) {
@@ -507,7 +589,23 @@ func CreateFromTemplate(name string) error {
 		return fmt.Errorf("error copying template: %w", err)
 	}
 
-	return Load()
+	return nil
+}
+
+func validCharacterName(name string) error {
+	if name == "" {
+		return errors.New("name cannot be empty")
+	}
+
+	if name == ProfilesDir {
+		return fmt.Errorf("%s is reserved for the shared profiles", ProfilesDir)
+	}
+
+	if filepath.Base(name) != name || name == "." || name == ".." {
+		return fmt.Errorf("invalid name %s", name)
+	}
+
+	return nil
 }
 
 func ValidateAndSaveConfig(config KooloCfg) error {
@@ -527,7 +625,7 @@ func ValidateAndSaveConfig(config KooloCfg) error {
 		return fmt.Errorf("error parsing koolo config: %w", err)
 	}
 
-	err = os.WriteFile("config/koolo.yaml", text, 0644)
+	err = writeConfigFile("config/koolo.yaml", text)
 	if err != nil {
 		return fmt.Errorf("error writing koolo config: %w", err)
 	}
@@ -543,7 +641,19 @@ func SaveSupervisorConfig(supervisorName string, config *CharacterCfg) error {
 		return err
 	}
 
-	err = os.WriteFile(filePath, d, 0644)
+	// Never write a config that would fail to load
+	if err = DecodeCharacterConfig(filePath, d, &CharacterCfg{}); err != nil {
+		return err
+	}
+
+	// Only the overrides are written, so changes to the profile keep applying to this character
+	if config.Extends != "" {
+		if d, err = overridesOnly("config", config); err != nil {
+			return fmt.Errorf("error resolving profile %s: %w", config.Extends, err)
+		}
+	}
+
+	err = writeConfigFile(filePath, d)
 	if err != nil {
 		return fmt.Errorf("error writing supervisor config: %w", err)
 	}
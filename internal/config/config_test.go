@@ -0,0 +1,176 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCharacterConfig = `configVersion: 1
+character:
+  class: sorceress
+game:
+  runs: [pindleskin]
+inventory:
+  beltColumns: [healing, healing, mana, mana]
+`
+
+// useConfigDir writes files, relative to a new temporary directory, and makes it the working directory like Koolo
+// expects, Characters is restored when the test ends
+func useConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	koolo, characters, invalid := Koolo, Characters, invalidCharacters
+	Characters, invalidCharacters = nil, nil
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+		Koolo, Characters, invalidCharacters = koolo, characters, invalid
+	})
+
+	return dir
+}
+
+func TestLoadSkipsInvalidCharacters(t *testing.T) {
+	dir := useConfigDir(t, map[string]string{
+		"config/koolo.yaml":           "debug:\n  log: true\n",
+		"config/valid/config.yaml":    testCharacterConfig,
+		"config/valid/pickit/a.nip":   "[name] == ring\n",
+		"config/invalid/config.yaml":  testCharacterConfig + "health:\n  chickenAt: 150\nunknownKey: true\n",
+		"config/invalid/pickit/a.nip": "[name] == ring\n",
+		"config/broken/config.yaml":   "character: [\n",
+	})
+
+	if err := Load(); err != nil {
+		t.Fatalf("invalid characters must not fail the load: %v", err)
+	}
+
+	if _, found := GetCharacter("valid"); !found {
+		t.Error("valid character not loaded")
+	}
+	for _, name := range []string{"invalid", "broken"} {
+		if _, found := GetCharacter(name); found {
+			t.Errorf("%s character loaded", name)
+		}
+	}
+
+	invalid := InvalidCharacters()
+	if len(invalid) != 2 {
+		t.Fatalf("expected 2 invalid characters, got %v", invalid)
+	}
+	if errs := invalid["invalid"]; len(errs) != 2 {
+		t.Errorf("expected both errors of the invalid character, got %v", errs)
+	}
+	if errs := invalid["broken"]; len(errs) != 1 || errs[0].File != filepath.Join(dir, "config", "broken", "config.yaml") {
+		t.Errorf("expected the syntax error of the broken character, got %v", errs)
+	}
+}
+
+func TestLoadKeepsPreviousConfig(t *testing.T) {
+	dir := useConfigDir(t, map[string]string{
+		"config/koolo.yaml":        "debug:\n  log: true\n",
+		"config/char/config.yaml":  testCharacterConfig,
+		"config/char/pickit/a.nip": "[name] == ring\n",
+	})
+
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	previous, _ := GetCharacter("char")
+
+	if err := os.WriteFile(filepath.Join(dir, "config", "char", "config.yaml"), []byte(testCharacterConfig+"unknownKey: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, found := GetCharacter("char")
+	if !found || current != previous {
+		t.Error("the previous config must be kept while the file is broken")
+	}
+	if _, found = InvalidCharacters()["char"]; !found {
+		t.Error("the broken config is not reported")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config", "char", "config.yaml"), []byte(testCharacterConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(InvalidCharacters()) != 0 {
+		t.Errorf("fixed config still reported: %v", InvalidCharacters())
+	}
+}
+
+func TestLoadRejectsInvalidKooloConfig(t *testing.T) {
+	useConfigDir(t, map[string]string{
+		"config/koolo.yaml":       "discord:\n  token: ${unknown:token}\n",
+		"config/char/config.yaml": testCharacterConfig,
+	})
+
+	if err := Load(); err == nil {
+		t.Fatal("expected the wrong secret reference of koolo.yaml to fail the load")
+	}
+	if Characters != nil {
+		t.Error("the configuration must not change when koolo.yaml is rejected")
+	}
+}
+
+func TestWrittenByKoolo(t *testing.T) {
+	useConfigDir(t, map[string]string{
+		"config/koolo.yaml":        "debug:\n  log: true\n",
+		"config/char/config.yaml":  testCharacterConfig,
+		"config/char/pickit/a.nip": "[name] == ring\n",
+	})
+
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	cfg, found := GetCharacter("char")
+	if !found {
+		t.Fatalf("character not loaded: %v", InvalidCharacters())
+	}
+	if err := SaveSupervisorConfig("char", cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join("config", "char", "config.yaml")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !WrittenByKoolo(path, info.ModTime(), info.Size()) {
+		t.Error("the saved config should be reported as written by Koolo")
+	}
+
+	// Edited by the user afterwards
+	if err = os.WriteFile(path, []byte(testCharacterConfig+"\n# edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	if WrittenByKoolo(path, info.ModTime(), info.Size()) {
+		t.Error("a file modified outside of Koolo is reported as written by it")
+	}
+}
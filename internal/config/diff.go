@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxRuleChanges limits the pickit rules listed one by one, replacing a whole pickit folder would be too verbose
+const maxRuleChanges = 20
+
+// Change is a value that differs between two character configs, Path uses the config.yaml keys. Pickit rules are
+// reported with the "pickit" path.
+type Change struct {
+	Path string
+	Old  string
+	New  string
+}
+
+// Diff returns every value changed from old to new, including the pickit rules
+func Diff(old, new *CharacterCfg) ([]Change, error) {
+	oldValues, err := leafValues(old)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := leafValues(new)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, path := range newValues.paths {
+		if oldValue, found := oldValues.values[path]; !found || oldValue != newValues.values[path] {
+			changes = append(changes, Change{Path: path, Old: oldValue, New: newValues.values[path]})
+		}
+	}
+	for _, path := range oldValues.paths {
+		if _, found := newValues.values[path]; !found {
+			changes = append(changes, Change{Path: path, Old: oldValues.values[path]})
+		}
+	}
+
+	return append(changes, ruleChanges(old, new)...), nil
+}
+
+// Clone returns a deep copy of the config values, Runtime is shared with the original
+func (c *CharacterCfg) Clone() (*CharacterCfg, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding character config: %w", err)
+	}
+
+	clone := &CharacterCfg{}
+	if err = yaml.Unmarshal(b, clone); err != nil {
+		return nil, fmt.Errorf("error decoding character config: %w", err)
+	}
+	clone.ConfigFolderName = c.ConfigFolderName
+	clone.Game.PublicGameCounter = c.Game.PublicGameCounter
+	clone.Runtime = c.Runtime
+
+	return clone, nil
+}
+
+type leaves struct {
+	paths  []string
+	values map[string]string
+}
+
+func leafValues(cfg *CharacterCfg) (leaves, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return leaves{}, fmt.Errorf("error encoding character config: %w", err)
+	}
+
+	l := leaves{values: make(map[string]string)}
+	walkLeaves(documentContent(&node), "", func(path string, n *yaml.Node) {
+		l.paths = append(l.paths, path)
+		l.values[path] = nodeValue(n)
+	})
+
+	return l, nil
+}
+
+func ruleChanges(old, new *CharacterCfg) []Change {
+	oldRules := make(map[string]int)
+	for _, r := range old.Runtime.Rules {
+		oldRules[r.RawLine]++
+	}
+	newRules := make(map[string]int)
+	for _, r := range new.Runtime.Rules {
+		newRules[r.RawLine]++
+	}
+
+	var changes []Change
+	added, removed := 0, 0
+	for _, r := range new.Runtime.Rules {
+		if oldRules[r.RawLine] > 0 {
+			oldRules[r.RawLine]--
+			continue
+		}
+		added++
+		if added <= maxRuleChanges {
+			changes = append(changes, Change{Path: "pickit", New: r.RawLine})
+		}
+	}
+	for _, r := range old.Runtime.Rules {
+		if newRules[r.RawLine] > 0 {
+			newRules[r.RawLine]--
+			continue
+		}
+		removed++
+		if removed <= maxRuleChanges {
+			changes = append(changes, Change{Path: "pickit", Old: r.RawLine})
+		}
+	}
+
+	if added > maxRuleChanges || removed > maxRuleChanges {
+		changes = append(changes, Change{
+			Path: "pickit",
+			Old:  fmt.Sprintf("%d rules", len(old.Runtime.Rules)),
+			New:  fmt.Sprintf("%d rules, %d added and %d removed", len(new.Runtime.Rules), added, removed),
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfilesDir contains the shared base profiles, config/profiles/<name>.yaml. A profile is a partial character config
+// and can extend another profile, a character config picks one with "extends: <name>" and only overrides what changes.
+const ProfilesDir = "profiles"
+
+// maxExtendsDepth is far more than anyone needs, it only protects from runaway chains
+const maxExtendsDepth = 16
+
+// InheritedValue is a value of the character config coming from one of its profiles
+type InheritedValue struct {
+	Path    string
+	Value   string
+	Profile string
+}
+
+// configLayer is one file of the extends chain, name is empty for the character config itself
+type configLayer struct {
+	name    string
+	file    string
+	content []byte
+	root    yaml.Node
+}
+
+func parseConfigLayer(name, file string, content []byte) (*configLayer, error) {
+	l := &configLayer{name: name, file: file, content: content}
+	if err := yaml.Unmarshal(content, &l.root); err != nil {
+		return nil, fmt.Errorf("error reading %s character config: %w", file, err)
+	}
+
+	return l, nil
+}
+
+// Profiles returns the name of every profile available to extend
+func Profiles() []string {
+	entries, err := os.ReadDir(filepath.Join("config", ProfilesDir))
+	if err != nil {
+		return nil
+	}
+
+	profiles := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yaml" {
+			profiles = append(profiles, strings.TrimSuffix(entry.Name(), ".yaml"))
+		}
+	}
+
+	return profiles
+}
+
+// loadCharacterConfig decodes and validates the character config at path after resolving its extends chain, old
+// config versions are migrated first
+func loadCharacterConfig(configDir, path string, cfg *CharacterCfg, template *yaml.Node) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error loading config.yaml: %w", err)
+	}
+
+	if content, err = migrateFile(path, content, template); err != nil {
+		var verr ValidationError
+		if errors.As(err, &verr) {
+			return ValidationErrors{verr}
+		}
+		return err
+	}
+
+	character, err := parseConfigLayer("", path, content)
+	if err != nil {
+		return err
+	}
+
+	layers, err := resolveProfiles(configDir, extendsOf(&character.root))
+	if err != nil {
+		var verr ValidationError
+		if !errors.As(err, &verr) {
+			return err
+		}
+		// Without its profiles the character config is incomplete, validating it would only add noise
+		verr.File = path
+		verr.Line, _ = nodeLine(&character.root, []any{"extends"})
+
+		return ValidationErrors{verr}
+	}
+	layers = append(layers, character)
+
+	err = decodeLayers(cfg, layers)
+	cfg.Runtime.Inherited = inheritedValues(layers)
+
+	return err
+}
+
+// resolveProfiles returns the layers of the profile chain starting at name, base profile first
+func resolveProfiles(configDir, name string) ([]*configLayer, error) {
+	var layers []*configLayer
+	seen := make(map[string]bool)
+	for name != "" {
+		if seen[name] {
+			return nil, ValidationError{Field: "extends", Message: fmt.Sprintf("extends cycle at profile %q", name)}
+		}
+		if len(seen) >= maxExtendsDepth {
+			return nil, ValidationError{Field: "extends", Message: fmt.Sprintf("more than %d profiles extended", maxExtendsDepth)}
+		}
+		seen[name] = true
+
+		if filepath.Base(name) != name || name == ".." {
+			return nil, ValidationError{Field: "extends", Message: fmt.Sprintf("invalid profile name %q", name)}
+		}
+
+		file := filepath.Join(configDir, ProfilesDir, name+".yaml")
+		content, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, ValidationError{Field: "extends", Message: fmt.Sprintf("profile %q not found in %s", name, filepath.Join(configDir, ProfilesDir))}
+			}
+			return nil, fmt.Errorf("error reading profile %s: %w", name, err)
+		}
+
+		if content, err = migrateFile(file, content, nil); err != nil {
+			return nil, err
+		}
+
+		l, err := parseConfigLayer(name, file, content)
+		if err != nil {
+			return nil, err
+		}
+		layers = append([]*configLayer{l}, layers...)
+		name = extendsOf(&l.root)
+	}
+
+	return layers, nil
+}
+
+func extendsOf(root *yaml.Node) string {
+	node := documentContent(root)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return ""
+	}
+	if i := mappingIndex(node, "extends"); i >= 0 {
+		return strings.TrimSpace(node.Content[i+1].Value)
+	}
+
+	return ""
+}
+
+func mergeLayers(layers []*configLayer) *yaml.Node {
+	var merged *yaml.Node
+	for _, l := range layers {
+		merged = mergeNodes(merged, &l.root)
+	}
+
+	return merged
+}
+
+// mergeNodes overlays override on top of base, mappings are merged key by key and anything else is replaced, so a
+// list in a profile is fully replaced by the character one
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	base, override = documentContent(base), documentContent(override)
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	merged := *base
+	merged.Content = append([]*yaml.Node(nil), base.Content...)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		if j := mappingIndex(&merged, key.Value); j >= 0 {
+			merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+			continue
+		}
+		merged.Content = append(merged.Content, key, value)
+	}
+
+	return &merged
+}
+
+// diffNodes returns the parts of node different from base, nil when they are equal
+func diffNodes(node, base *yaml.Node) *yaml.Node {
+	node, base = documentContent(node), documentContent(base)
+	if base == nil || node == nil {
+		return node
+	}
+
+	if node.Kind == yaml.MappingNode && base.Kind == yaml.MappingNode {
+		diff := &yaml.Node{Kind: yaml.MappingNode, Tag: node.Tag}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var baseValue *yaml.Node
+			if j := mappingIndex(base, node.Content[i].Value); j >= 0 {
+				baseValue = base.Content[j+1]
+			}
+			if d := diffNodes(node.Content[i+1], baseValue); d != nil {
+				diff.Content = append(diff.Content, node.Content[i], d)
+			}
+		}
+		if len(diff.Content) == 0 {
+			return nil
+		}
+
+		return diff
+	}
+
+	if equalNodes(node, base) {
+		return nil
+	}
+
+	return node
+}
+
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Tag != b.Tag || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inheritedValues lists the values set by a profile that the character config doesn't override
+func inheritedValues(layers []*configLayer) []InheritedValue {
+	origin := make(map[string]InheritedValue)
+	for _, l := range layers {
+		walkLeaves(documentContent(&l.root), "", func(path string, node *yaml.Node) {
+			if path == "extends" {
+				return
+			}
+			for p := range origin {
+				if p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(path, p+".") {
+					delete(origin, p)
+				}
+			}
+			if l.name != "" {
+				origin[path] = InheritedValue{Path: path, Value: nodeValue(node), Profile: l.name}
+			}
+		})
+	}
+
+	inherited := make([]InheritedValue, 0, len(origin))
+	for _, v := range origin {
+		inherited = append(inherited, v)
+	}
+	sort.Slice(inherited, func(i, j int) bool {
+		return inherited[i].Path < inherited[j].Path
+	})
+
+	return inherited
+}
+
+// walkLeaves calls fn for every value that is not a mapping, lists are a single value as they are replaced as a whole
+func walkLeaves(node *yaml.Node, path string, fn func(path string, node *yaml.Node)) {
+	if node == nil {
+		return
+	}
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		if path != "" {
+			fn(path, node)
+		}
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		childPath := node.Content[i].Value
+		if path != "" {
+			childPath = path + "." + childPath
+		}
+		walkLeaves(node.Content[i+1], childPath, fn)
+	}
+}
+
+func nodeValue(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+
+	flow := *node
+	flow.Style |= yaml.FlowStyle
+	b, err := yaml.Marshal(&flow)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+// overridesOnly returns the character config without the values it would inherit from its profiles anyway
+func overridesOnly(configDir string, cfg *CharacterCfg) ([]byte, error) {
+	layers, err := resolveProfiles(configDir, cfg.Extends)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := &CharacterCfg{}
+	if merged := mergeLayers(layers); merged != nil {
+		var typeErr *yaml.TypeError
+		if err = merged.Decode(parent); err != nil && !errors.As(err, &typeErr) {
+			return nil, err
+		}
+	}
+
+	var node, base yaml.Node
+	if err = node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	if err = base.Encode(parent); err != nil {
+		return nil, err
+	}
+
+	diff := diffNodes(&node, &base)
+	if diff == nil {
+		diff = &yaml.Node{Kind: yaml.MappingNode}
+	}
+
+	// The profiles can have the same values, but the file must always say its version and what it extends
+	deleteKey(diff, "configVersion")
+	deleteKey(diff, "extends")
+	diff.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "configVersion"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(CurrentConfigVersion)},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "extends"},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: cfg.Extends},
+	}, diff.Content...)
+
+	return yaml.Marshal(diff)
+}
+
+func documentContent(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return node.Content[0]
+	}
+
+	return node
+}
+
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+
+	return -1
+}
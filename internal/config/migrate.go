@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the configVersion of config/template/config.yaml, every change renaming or moving keys of
+// CharacterCfg must increase it and add its migration below
+const CurrentConfigVersion = 1
+
+// migration upgrades a config from version-1 to version, doc is the root mapping of the file
+type migration struct {
+	version     int
+	description string
+	apply       func(doc *yaml.Node)
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "move top level leveling keys, remove the settings not used anymore",
+		apply: func(doc *yaml.Node) {
+			// It was never read, moving it to cubing.enabled would turn on cubing for every old config
+			deleteKey(doc, "enableCubeRecipes")
+			moveKey(doc, "stopLevelingAt", "game.stopLevelingAt")
+			moveKey(doc, "game.diablo.onlyElites", "game.diablo.focusOnElitePacks")
+			deleteKey(doc, "game.diablo.clearArea")
+			deleteKey(doc, "companion.attack")
+			deleteKey(doc, "companion.followLeader")
+		},
+	},
+}
+
+// migrateConfig upgrades content to CurrentConfigVersion. When template is not nil the keys missing in content are
+// added with the template values, files extending a profile don't get them as they would hide the profile ones.
+// It returns the same content when no migration was needed.
+func migrateConfig(file string, content []byte, template *yaml.Node) ([]byte, bool, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, false, fmt.Errorf("error reading %s character config: %w", file, err)
+	}
+	doc := documentContent(&root)
+	if doc == nil || doc.Kind != yaml.MappingNode {
+		return content, false, nil
+	}
+
+	version := 0
+	if i := mappingIndex(doc, "configVersion"); i >= 0 {
+		v, err := strconv.Atoi(doc.Content[i+1].Value)
+		if err != nil {
+			return nil, false, ValidationError{File: file, Line: doc.Content[i].Line, Field: "configVersion", Message: "must be a number"}
+		}
+		version = v
+	}
+
+	if version > CurrentConfigVersion {
+		return nil, false, ValidationError{File: file, Field: "configVersion", Message: fmt.Sprintf("version %d is newer than the supported one (%d), please update Koolo", version, CurrentConfigVersion)}
+	}
+	if version == CurrentConfigVersion {
+		return content, false, nil
+	}
+
+	for _, m := range migrations {
+		if m.version > version {
+			m.apply(doc)
+		}
+	}
+	versionNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(CurrentConfigVersion)}
+	if i := mappingIndex(doc, "configVersion"); i >= 0 {
+		doc.Content[i+1] = versionNode
+	} else {
+		doc.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: "configVersion"}, versionNode}, doc.Content...)
+	}
+	if template != nil && mappingIndex(doc, "extends") < 0 {
+		addMissingKeys(doc, documentContent(template))
+	}
+
+	buf := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, false, fmt.Errorf("error encoding migrated %s: %w", file, err)
+	}
+
+	return buf.Bytes(), true, nil
+}
+
+// migrateFile upgrades the config file in place, the original is kept as <file>.v<version>-<time>.bak
+func migrateFile(file string, content []byte, template *yaml.Node) ([]byte, error) {
+	migrated, changed, err := migrateConfig(file, content, template)
+	if err != nil || !changed {
+		return migrated, err
+	}
+
+	backup := fmt.Sprintf("%s.v%d-%s.bak", file, fileVersion(content), time.Now().Format("20060102-150405"))
+	if err = os.WriteFile(backup, content, 0644); err != nil {
+		return nil, fmt.Errorf("error writing backup of %s before migrating it: %w", file, err)
+	}
+	if err = writeConfigFile(file, migrated); err != nil {
+		return nil, fmt.Errorf("error writing migrated %s: %w", file, err)
+	}
+
+	return migrated, nil
+}
+
+func fileVersion(content []byte) int {
+	var v struct {
+		ConfigVersion int `yaml:"configVersion"`
+	}
+	_ = yaml.Unmarshal(content, &v)
+
+	return v.ConfigVersion
+}
+
+func readTemplate(configDir string) *yaml.Node {
+	content, err := os.ReadFile(filepath.Join(configDir, "template", "config.yaml"))
+	if err != nil {
+		return nil
+	}
+
+	var root yaml.Node
+	if err = yaml.Unmarshal(content, &root); err != nil {
+		return nil
+	}
+
+	return &root
+}
+
+// lookupKey returns the mapping containing the last key of the dotted path and the key index, -1 if not found.
+// With create the missing parent mappings are added.
+func lookupKey(doc *yaml.Node, path string, create bool) (*yaml.Node, string, int) {
+	keys := strings.Split(path, ".")
+	node := doc
+	for _, key := range keys[:len(keys)-1] {
+		i := mappingIndex(node, key)
+		if i < 0 {
+			if !create {
+				return nil, "", -1
+			}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+			)
+			i = len(node.Content) - 2
+		}
+		node = node.Content[i+1]
+		if node.Kind != yaml.MappingNode {
+			return nil, "", -1
+		}
+	}
+
+	last := keys[len(keys)-1]
+
+	return node, last, mappingIndex(node, last)
+}
+
+// moveKey moves or renames a key, the destination wins if both exist
+func moveKey(doc *yaml.Node, from, to string) {
+	parent, _, i := lookupKey(doc, from, false)
+	if i < 0 {
+		return
+	}
+	key, value := parent.Content[i], parent.Content[i+1]
+	parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+
+	dst, last, j := lookupKey(doc, to, true)
+	if dst == nil || j >= 0 {
+		return
+	}
+	key.Value = last
+	dst.Content = append(dst.Content, key, value)
+}
+
+func deleteKey(doc *yaml.Node, path string) {
+	parent, _, i := lookupKey(doc, path, false)
+	if i < 0 {
+		return
+	}
+	parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+}
+
+// addMissingKeys copies every key of template missing in doc, including nested ones
+func addMissingKeys(doc, template *yaml.Node) {
+	if doc == nil || template == nil || doc.Kind != yaml.MappingNode || template.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(template.Content); i += 2 {
+		key, value := template.Content[i], template.Content[i+1]
+		j := mappingIndex(doc, key.Value)
+		if j < 0 {
+			doc.Content = append(doc.Content, key, value)
+			continue
+		}
+		addMissingKeys(doc.Content[j+1], value)
+	}
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseDoc(t *testing.T, content string) *yaml.Node {
+	t.Helper()
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &root); err != nil {
+		t.Fatal(err)
+	}
+
+	return documentContent(&root)
+}
+
+func encodeDoc(t *testing.T, doc *yaml.Node) string {
+	t.Helper()
+
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestMoveKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		from, to string
+		expected string
+	}{
+		{
+			name:     "rename",
+			content:  "game:\n  diablo:\n    onlyElites: true\n",
+			from:     "game.diablo.onlyElites",
+			to:       "game.diablo.focusOnElitePacks",
+			expected: "game:\n    diablo:\n        focusOnElitePacks: true\n",
+		},
+		{
+			name:     "creates the parents",
+			content:  "stopLevelingAt: 90\n",
+			from:     "stopLevelingAt",
+			to:       "game.stopLevelingAt",
+			expected: "game:\n    stopLevelingAt: 90\n",
+		},
+		{
+			name:     "destination wins",
+			content:  "stopLevelingAt: 90\ngame:\n  stopLevelingAt: 80\n",
+			from:     "stopLevelingAt",
+			to:       "game.stopLevelingAt",
+			expected: "game:\n    stopLevelingAt: 80\n",
+		},
+		{
+			name:     "missing key",
+			content:  "game:\n  runs: [pindleskin]\n",
+			from:     "stopLevelingAt",
+			to:       "game.stopLevelingAt",
+			expected: "game:\n    runs: [pindleskin]\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := parseDoc(t, tc.content)
+			moveKey(doc, tc.from, tc.to)
+			if got := encodeDoc(t, doc); got != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAddMissingKeys(t *testing.T) {
+	doc := parseDoc(t, "character:\n  class: hammerdin\ngame:\n  runs: [pindleskin]\n")
+	template := parseDoc(t, "character:\n  class: sorceress\n  useMerc: true\ngame:\n  runs: [mephisto]\n  difficulty: hell\nhealth:\n  chickenAt: 30\n")
+
+	addMissingKeys(doc, template)
+
+	expected := "character:\n    class: hammerdin\n    useMerc: true\ngame:\n    runs: [pindleskin]\n    difficulty: hell\nhealth:\n    chickenAt: 30\n"
+	if got := encodeDoc(t, doc); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	template := parseDoc(t, "configVersion: 1\ncharacter:\n  class: sorceress\ncubing:\n  enabled: false\n")
+	content := `enableCubeRecipes: true
+stopLevelingAt: 90
+character:
+  class: hammerdin
+game:
+  diablo:
+    onlyElites: true
+    clearArea: true
+companion:
+  leaderName: leader
+  attack: true
+  followLeader: true
+`
+
+	migrated, changed, err := migrateConfig("config.yaml", []byte(content), template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected the config to be migrated")
+	}
+
+	var cfg struct {
+		ConfigVersion int `yaml:"configVersion"`
+	}
+	if err = yaml.Unmarshal(migrated, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConfigVersion != CurrentConfigVersion {
+		t.Errorf("expected configVersion %d, got %d", CurrentConfigVersion, cfg.ConfigVersion)
+	}
+
+	got := string(migrated)
+	for _, removed := range []string{"enableCubeRecipes", "onlyElites", "clearArea", "attack", "followLeader"} {
+		if strings.Contains(got, removed) {
+			t.Errorf("%s should be removed:\n%s", removed, got)
+		}
+	}
+	for _, expected := range []string{"stopLevelingAt: 90", "focusOnElitePacks: true", "class: hammerdin", "leaderName: leader", "enabled: false"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected %q in the migrated config:\n%s", expected, got)
+		}
+	}
+
+	// Already migrated, the content is not touched
+	again, changed, err := migrateConfig("config.yaml", migrated, template)
+	if err != nil || changed || string(again) != got {
+		t.Errorf("a migrated config must not change again, changed %v, error %v", changed, err)
+	}
+}
+
+func TestMigrateConfigWithExtends(t *testing.T) {
+	template := parseDoc(t, "configVersion: 1\ncharacter:\n  class: sorceress\n")
+
+	migrated, changed, err := migrateConfig("config.yaml", []byte("extends: base\nstopLevelingAt: 90\n"), template)
+	if err != nil || !changed {
+		t.Fatalf("expected the config to be migrated, changed %v, error %v", changed, err)
+	}
+	// The template values would hide the profile ones
+	if strings.Contains(string(migrated), "class") {
+		t.Errorf("template keys added to a config extending a profile:\n%s", migrated)
+	}
+}
+
+func TestMigrateConfigNewerVersion(t *testing.T) {
+	if _, _, err := migrateConfig("config.yaml", []byte("configVersion: 99\n"), nil); err == nil {
+		t.Error("expected an error for a version newer than the supported one")
+	}
+}
+
+func TestSaveKeepsConfigVersionWithExtends(t *testing.T) {
+	dir := useConfigDir(t, map[string]string{
+		"config/koolo.yaml":         "debug:\n  log: true\n",
+		"config/profiles/base.yaml": "configVersion: 1\n" + strings.TrimPrefix(testCharacterConfig, "configVersion: 1\n"),
+		"config/char/config.yaml":   "configVersion: 1\nextends: base\n",
+		"config/char/pickit/a.nip":  "[name] == ring\n",
+	})
+
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	cfg, found := GetCharacter("char")
+	if !found {
+		t.Fatalf("character not loaded: %v", InvalidCharacters())
+	}
+	updated, err := cfg.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated.Game.Runs = []Run{"mephisto"}
+
+	if err = SaveSupervisorConfig("char", updated); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "config", "char", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"configVersion: 1", "extends: base", "mephisto"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected %q in the saved config:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(string(content), "sorceress") {
+		t.Errorf("inherited values written to the character config:\n%s", content)
+	}
+	// Without its version the saved file would be migrated again by the next load
+	if backups, _ := filepath.Glob(filepath.Join(dir, "config", "char", "*.bak")); len(backups) > 0 {
+		t.Errorf("the saved config was migrated again: %v", backups)
+	}
+}
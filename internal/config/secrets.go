@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret values (passwords, tokens) can be written in the config files as a reference, ${provider:name}, so the files
+// can be backed up or shared without the credentials. References are resolved when the value is used, see ResolveSecret.
+const (
+	SecretProviderVault = "vault"
+	SecretProviderEnv   = "env"
+	SecretProviderDPAPI = "dpapi"
+)
+
+var secretRefRegexp = regexp.MustCompile(`^\$\{([a-z]+):([A-Za-z0-9_.-]+)\}$`)
+
+// ErrSecretNotFound is returned by the providers when the name doesn't exist
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider returns the value of a secret by its name
+type SecretProvider interface {
+	Get(name string) (string, error)
+}
+
+// SecretStore is a SecretProvider where secrets can also be written, used by "koolo secrets"
+type SecretStore interface {
+	SecretProvider
+	Set(name, value string) error
+	Delete(name string) error
+	List() ([]string, error)
+}
+
+var (
+	secretsMux      sync.RWMutex
+	secretProviders = map[string]SecretProvider{
+		SecretProviderVault: DefaultVault,
+		SecretProviderEnv:   envSecrets{},
+	}
+)
+
+// RegisterSecretProvider makes a provider available to the references, an existing one with the same name is replaced
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretsMux.Lock()
+	defer secretsMux.Unlock()
+
+	secretProviders[name] = provider
+}
+
+// GetSecretProvider returns the provider registered with name
+func GetSecretProvider(name string) (SecretProvider, bool) {
+	secretsMux.RLock()
+	defer secretsMux.RUnlock()
+
+	provider, found := secretProviders[name]
+
+	return provider, found
+}
+
+// SecretProviders returns the names of the registered providers, sorted
+func SecretProviders() []string {
+	secretsMux.RLock()
+	defer secretsMux.RUnlock()
+
+	names := make([]string, 0, len(secretProviders))
+	for name := range secretProviders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// SecretRef returns the reference to the secret name of provider, as it's written in the config files
+func SecretRef(provider, name string) string {
+	return fmt.Sprintf("${%s:%s}", provider, name)
+}
+
+// ParseSecretRef returns the provider and name of a reference, found is false for plain values
+func ParseSecretRef(value string) (provider, name string, found bool) {
+	m := secretRefRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", false
+	}
+
+	return m[1], m[2], true
+}
+
+// ResolveSecret returns value as is, or the secret it references when it's a ${provider:name} reference
+func ResolveSecret(value string) (string, error) {
+	providerName, name, found := ParseSecretRef(value)
+	if !found {
+		return value, nil
+	}
+
+	provider, found := GetSecretProvider(providerName)
+	if !found {
+		return "", fmt.Errorf("unknown secret provider %s in %s", providerName, value)
+	}
+	secret, err := provider.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("error resolving secret %s: %w", value, err)
+	}
+
+	return secret, nil
+}
+
+// ResolveWebhookEndpoints returns a copy of endpoints with the references of the secrets and header values resolved
+func ResolveWebhookEndpoints(endpoints []WebhookEndpoint) ([]WebhookEndpoint, error) {
+	resolved := make([]WebhookEndpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		secret, err := ResolveSecret(ep.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s secret: %w", ep.Name, err)
+		}
+		ep.Secret = secret
+
+		headers := make(map[string]string, len(ep.Headers))
+		for key, value := range ep.Headers {
+			if headers[key], err = ResolveSecret(value); err != nil {
+				return nil, fmt.Errorf("webhook %s header %s: %w", ep.Name, key, err)
+			}
+		}
+		ep.Headers = headers
+
+		resolved = append(resolved, ep)
+	}
+
+	return resolved, nil
+}
+
+// validateWebhookSecretRefs checks the references of the secrets and header values of the webhook endpoints
+func validateWebhookSecretRefs(file string, endpoints []WebhookEndpoint) []*ValidationError {
+	var verrs []*ValidationError
+	for i, ep := range endpoints {
+		verrs = append(verrs, validateSecretRef(file, fmt.Sprintf("webhooks.endpoints[%d].secret", i), ep.Secret))
+		for _, key := range slices.Sorted(maps.Keys(ep.Headers)) {
+			verrs = append(verrs, validateSecretRef(file, fmt.Sprintf("webhooks.endpoints[%d].headers.%s", i, key), ep.Headers[key]))
+		}
+	}
+
+	return verrs
+}
+
+// validateSecretRef checks that a reference is well formed and its provider exists, the secret itself is only read
+// when it's used, the vault can still be locked while loading the config
+func validateSecretRef(file, field, value string) *ValidationError {
+	providerName, _, found := ParseSecretRef(value)
+	if !found {
+		if len(value) > 2 && value[:2] == "${" {
+			return &ValidationError{File: file, Field: field, Message: "invalid secret reference, expected ${provider:name}"}
+		}
+		return nil
+	}
+	if _, found = GetSecretProvider(providerName); !found {
+		return &ValidationError{File: file, Field: field, Message: fmt.Sprintf("unknown secret provider %s, available ones are %v", providerName, SecretProviders())}
+	}
+
+	return nil
+}
+
+// envSecrets reads the secrets from environment variables, the name is the variable name
+type envSecrets struct{}
+
+func (envSecrets) Get(name string) (string, error) {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return "", fmt.Errorf("environment variable %s is not set: %w", name, ErrSecretNotFound)
+	}
+
+	return value, nil
+}
+
+// SecretStoreFor returns the provider registered with name, when secrets can be written to it
+func SecretStoreFor(name string) (SecretStore, error) {
+	provider, found := GetSecretProvider(name)
+	if !found {
+		return nil, fmt.Errorf("unknown secret provider %s, available ones are %v", name, SecretProviders())
+	}
+	store, ok := provider.(SecretStore)
+	if !ok {
+		return nil, fmt.Errorf("secrets can't be written to the %s provider", name)
+	}
+
+	return store, nil
+}
+
+// ProtectSecrets moves the plain text passwords and tokens of koolo.yaml and every character config to the store of
+// provider, and writes the files again with references to them. It returns the names of the stored secrets. Values
+// inherited from profiles are not changed, profiles should not contain credentials.
+func ProtectSecrets(provider string) ([]string, error) {
+	store, err := SecretStoreFor(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []string
+	protect := func(name string, value *string) (bool, error) {
+		if *value == "" {
+			return false, nil
+		}
+		if _, _, found := ParseSecretRef(*value); found {
+			return false, nil
+		}
+		if err := store.Set(name, *value); err != nil {
+			return false, fmt.Errorf("error storing %s: %w", name, err)
+		}
+		*value = SecretRef(provider, name)
+		stored = append(stored, name)
+
+		return true, nil
+	}
+
+	cfgMux.RLock()
+	koolo := *Koolo
+	cfgMux.RUnlock()
+
+	discordChanged, err := protect("discord.token", &koolo.Discord.Token)
+	if err != nil {
+		return stored, err
+	}
+	telegramChanged, err := protect("telegram.token", &koolo.Telegram.Token)
+	if err != nil {
+		return stored, err
+	}
+	if discordChanged || telegramChanged {
+		// Written as is, ValidateAndSaveConfig would require the game paths of this machine
+		text, err := yaml.Marshal(koolo)
+		if err != nil {
+			return stored, fmt.Errorf("error parsing koolo config: %w", err)
+		}
+		if err = writeConfigFile(filepath.Join("config", "koolo.yaml"), text); err != nil {
+			return stored, fmt.Errorf("error writing koolo config: %w", err)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(GetCharacters())) {
+		cfg, found := GetCharacter(name)
+		if !found {
+			continue
+		}
+		// The loaded config is shared with the running supervisor, it's only replaced by the reload
+		updated, err := cfg.Clone()
+		if err != nil {
+			return stored, err
+		}
+
+		passwordChanged, err := protect(name+".password", &updated.Password)
+		if err != nil {
+			return stored, err
+		}
+		tokenChanged, err := protect(name+".authToken", &updated.AuthToken)
+		if err != nil {
+			return stored, err
+		}
+		if passwordChanged || tokenChanged {
+			if err = SaveSupervisorConfig(name, updated); err != nil {
+				return stored, fmt.Errorf("error writing %s config: %w", name, err)
+			}
+		}
+	}
+
+	return stored, Load()
+}
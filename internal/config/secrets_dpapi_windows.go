@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/billgraziano/dpapi"
+	"gopkg.in/yaml.v3"
+)
+
+// DPAPISecrets stores every secret encrypted with the Windows user credentials, there is no passphrase but the file
+// can only be read by the same Windows user on the same machine
+type DPAPISecrets struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultDPAPISecrets is the store used by the ${dpapi:name} references
+var DefaultDPAPISecrets = &DPAPISecrets{path: filepath.Join("config", "secrets.dpapi.yaml")}
+
+func init() {
+	RegisterSecretProvider(SecretProviderDPAPI, DefaultDPAPISecrets)
+}
+
+func (d *DPAPISecrets) Get(name string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	secrets, err := d.read()
+	if err != nil {
+		return "", err
+	}
+	encrypted, found := secrets[name]
+	if !found {
+		return "", fmt.Errorf("%s: %w", name, ErrSecretNotFound)
+	}
+
+	value, err := dpapi.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting %s, it can only be read by the Windows user that stored it: %w", name, err)
+	}
+
+	return value, nil
+}
+
+func (d *DPAPISecrets) Set(name, value string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	secrets, err := d.read()
+	if err != nil {
+		return err
+	}
+	if secrets[name], err = dpapi.Encrypt(value); err != nil {
+		return fmt.Errorf("error encrypting %s: %w", name, err)
+	}
+
+	return d.write(secrets)
+}
+
+func (d *DPAPISecrets) Delete(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	secrets, err := d.read()
+	if err != nil {
+		return err
+	}
+	if _, found := secrets[name]; !found {
+		return fmt.Errorf("%s: %w", name, ErrSecretNotFound)
+	}
+	delete(secrets, name)
+
+	return d.write(secrets)
+}
+
+func (d *DPAPISecrets) List() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	secrets, err := d.read()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// read returns the base64 encoded DPAPI blobs by name
+func (d *DPAPISecrets) read() (map[string]string, error) {
+	secrets := make(map[string]string)
+	content, err := os.ReadFile(d.path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", d.path, err)
+	}
+	if err = yaml.Unmarshal(content, &secrets); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", d.path, err)
+	}
+
+	return secrets, nil
+}
+
+func (d *DPAPISecrets) write(secrets map[string]string) error {
+	content, err := yaml.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(d.path, content, 0600); err != nil {
+		return fmt.Errorf("error writing %s: %w", d.path, err)
+	}
+
+	return nil
+}
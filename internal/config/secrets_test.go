@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	tests := []struct {
+		value    string
+		provider string
+		name     string
+		found    bool
+	}{
+		{value: "${vault:discord.token}", provider: "vault", name: "discord.token", found: true},
+		{value: "${env:KOOLO_TOKEN}", provider: "env", name: "KOOLO_TOKEN", found: true},
+		{value: "${dpapi:char-1_password}", provider: "dpapi", name: "char-1_password", found: true},
+		{value: "plain password"},
+		{value: ""},
+		{value: "${vault}"},
+		{value: "${vault:}"},
+		{value: "${Vault:name}"},
+		{value: "${vault:name with spaces}"},
+		{value: "prefix ${vault:name}"},
+		{value: "${vault:name} suffix"},
+	}
+
+	for _, tc := range tests {
+		provider, name, found := ParseSecretRef(tc.value)
+		if provider != tc.provider || name != tc.name || found != tc.found {
+			t.Errorf("ParseSecretRef(%q) = %q, %q, %v, expected %q, %q, %v", tc.value, provider, name, found, tc.provider, tc.name, tc.found)
+		}
+	}
+
+	if ref := SecretRef("vault", "discord.token"); ref != "${vault:discord.token}" {
+		t.Errorf("unexpected reference %s", ref)
+	}
+}
+
+type staticSecrets map[string]string
+
+func (s staticSecrets) Get(name string) (string, error) {
+	value, found := s[name]
+	if !found {
+		return "", ErrSecretNotFound
+	}
+
+	return value, nil
+}
+
+func TestResolveSecret(t *testing.T) {
+	RegisterSecretProvider("test", staticSecrets{"token": "secret-token"})
+	t.Setenv("KOOLO_TEST_SECRET", "env-secret")
+
+	tests := []struct {
+		value    string
+		expected string
+		err      error
+	}{
+		{value: "plain password", expected: "plain password"},
+		{value: "${test:token}", expected: "secret-token"},
+		{value: "${env:KOOLO_TEST_SECRET}", expected: "env-secret"},
+		{value: "${test:missing}", err: ErrSecretNotFound},
+		{value: "${env:KOOLO_TEST_MISSING_SECRET}", err: ErrSecretNotFound},
+	}
+
+	for _, tc := range tests {
+		value, err := ResolveSecret(tc.value)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("ResolveSecret(%q) returned error %v, expected %v", tc.value, err, tc.err)
+			continue
+		}
+		if value != tc.expected {
+			t.Errorf("ResolveSecret(%q) = %q, expected %q", tc.value, value, tc.expected)
+		}
+	}
+
+	if _, err := ResolveSecret("${unknown:token}"); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestValidateSecretRef(t *testing.T) {
+	if err := validateSecretRef("char.yaml", "password", "plain"); err != nil {
+		t.Errorf("unexpected error for a plain value: %v", err)
+	}
+	if err := validateSecretRef("char.yaml", "password", "${vault:char.password}"); err != nil {
+		t.Errorf("unexpected error for a valid reference: %v", err)
+	}
+	if err := validateSecretRef("char.yaml", "password", "${vault:}"); err == nil {
+		t.Error("expected an error for a malformed reference")
+	}
+	if err := validateSecretRef("char.yaml", "password", "${unknown:name}"); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestResolveWebhookEndpoints(t *testing.T) {
+	RegisterSecretProvider("test", staticSecrets{"webhook": "signing-secret"})
+	t.Setenv("KOOLO_TEST_WEBHOOK_TOKEN", "Bearer env-token")
+
+	endpoints := []WebhookEndpoint{{
+		Name:   "home",
+		Secret: "${test:webhook}",
+		Headers: map[string]string{
+			"Authorization": "${env:KOOLO_TEST_WEBHOOK_TOKEN}",
+			"X-Source":      "koolo",
+		},
+	}}
+
+	resolved, err := ResolveWebhookEndpoints(endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved[0].Secret != "signing-secret" {
+		t.Errorf("unexpected secret %q", resolved[0].Secret)
+	}
+	if resolved[0].Headers["Authorization"] != "Bearer env-token" || resolved[0].Headers["X-Source"] != "koolo" {
+		t.Errorf("unexpected headers %v", resolved[0].Headers)
+	}
+	if endpoints[0].Secret != "${test:webhook}" || endpoints[0].Headers["Authorization"] != "${env:KOOLO_TEST_WEBHOOK_TOKEN}" {
+		t.Error("the configured endpoints were modified")
+	}
+
+	endpoints[0].Headers["Authorization"] = "${test:missing}"
+	if _, err = ResolveWebhookEndpoints(endpoints); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestValidateWebhookSecretRefs(t *testing.T) {
+	endpoints := []WebhookEndpoint{{
+		Secret:  "${vault:webhook.secret}",
+		Headers: map[string]string{"Authorization": "${unknown:token}", "X-Source": "koolo"},
+	}}
+
+	var fields []string
+	for _, verr := range validateWebhookSecretRefs("koolo.yaml", endpoints) {
+		if verr != nil {
+			fields = append(fields, verr.Field)
+		}
+	}
+	if len(fields) != 1 || fields[0] != "webhooks.endpoints[0].headers.Authorization" {
+		t.Errorf("unexpected invalid fields %v", fields)
+	}
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AvailableClasses must be kept in sync with character.BuildCharacter
+var AvailableClasses = []string{
+	"sorceress",
+	"fireballsorc",
+	"nova",
+	"hydraorb",
+	"lightsorc",
+	"hammerdin",
+	"foh",
+	"trapsin",
+	"mosaic",
+	"winddruid",
+	"javazon",
+	"berserker",
+}
+
+// AvailableLevelingClasses are the classes supported when the first run is leveling, see character.BuildCharacter
+var AvailableLevelingClasses = []string{
+	"sorceress_leveling",
+	"necromancer",
+	"paladin",
+	"assassin",
+	"druid_leveling",
+}
+
+var beltColumnTypes = []string{"healing", "mana", "rejuvenation"}
+
+var (
+	yamlLineRegexp     = regexp.MustCompile(`^line (\d+): (.*)$`)
+	unknownFieldRegexp = regexp.MustCompile(`^field (\S+) not found in type`)
+)
+
+// ValidationError is a single problem found in a config file, Line is 0 when it can't be located
+type ValidationError struct {
+	File    string
+	Line    int
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", location, e.Field, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", location, e.Message)
+}
+
+// ValidationErrors aggregates every problem found, so all of them can be fixed at once
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+
+	return fmt.Sprintf("%d config error(s):\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// DecodeCharacterConfig strictly decodes a character config.yaml and validates its values, file is only used to
+// report the errors. It returns ValidationErrors when the file is valid YAML but its content is not.
+func DecodeCharacterConfig(file string, content []byte, cfg *CharacterCfg) error {
+	layer, err := parseConfigLayer("", file, content)
+	if err != nil {
+		return err
+	}
+
+	return decodeLayers(cfg, []*configLayer{layer})
+}
+
+// decodeLayers merges the layers, base profile first, into cfg and validates the result. Every layer is checked for
+// unknown keys on its own so errors point to the file defining them.
+func decodeLayers(cfg *CharacterCfg, layers []*configLayer) error {
+	var errs ValidationErrors
+	for _, l := range layers {
+		keyErrs, err := l.keyErrors()
+		if err != nil {
+			return err
+		}
+		errs = append(errs, keyErrs...)
+	}
+
+	if merged := mergeLayers(layers); merged != nil {
+		// Unknown keys and wrong types don't stop the decoder and they were already reported, everything else is
+		// still decoded and validated
+		var typeErr *yaml.TypeError
+		if err := merged.Decode(cfg); err != nil && !errors.As(err, &typeErr) {
+			return fmt.Errorf("error reading %s character config: %w", layers[len(layers)-1].file, err)
+		}
+	}
+
+	v := validator{layers: layers}
+	v.validate(cfg)
+	errs = append(errs, v.errs...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// keyErrors strictly decodes the layer alone, reporting unknown keys and values with the wrong type
+func (l *configLayer) keyErrors() (ValidationErrors, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(l.content))
+	dec.KnownFields(true)
+	err := dec.Decode(&CharacterCfg{})
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return nil, fmt.Errorf("error reading %s character config: %w", l.file, err)
+	}
+
+	var errs ValidationErrors
+	for _, msg := range typeErr.Errors {
+		verr := ValidationError{File: l.file, Message: msg}
+		if m := yamlLineRegexp.FindStringSubmatch(msg); m != nil {
+			verr.Line, _ = strconv.Atoi(m[1])
+			verr.Message = m[2]
+		}
+		// The decoder message includes the whole Go type, the full key path is more useful
+		if m := unknownFieldRegexp.FindStringSubmatch(verr.Message); m != nil {
+			verr.Message = "unknown key"
+			verr.Field = keyPathAt(&l.root, verr.Line, m[1])
+			if verr.Field == "" {
+				verr.Field = m[1]
+			}
+		}
+		errs = append(errs, verr)
+	}
+
+	return errs, nil
+}
+
+type validator struct {
+	layers []*configLayer
+	errs   ValidationErrors
+}
+
+func (v *validator) validate(cfg *CharacterCfg) {
+	leveling := len(cfg.Game.Runs) > 0 && cfg.Game.Runs[0] == LevelingRun
+
+	for i, run := range cfg.Game.Runs {
+		if _, found := AvailableRuns[run]; !found {
+			v.addf([]any{"game", "runs", i}, "unknown run %q", run)
+		}
+	}
+
+	class := strings.ToLower(cfg.Character.Class)
+	switch {
+	case leveling && !slices.Contains(AvailableLevelingClasses, class):
+		v.addf([]any{"character", "class"}, "class %q doesn't support leveling, allowed values: %s", cfg.Character.Class, strings.Join(AvailableLevelingClasses, ", "))
+	case !leveling && !slices.Contains(AvailableClasses, class):
+		v.addf([]any{"character", "class"}, "unknown class %q, allowed values: %s", cfg.Character.Class, strings.Join(AvailableClasses, ", "))
+	}
+
+	for i, recipe := range cfg.CubeRecipes.EnabledRecipes {
+		if !slices.Contains(AvailableRecipes, recipe) {
+			v.addf([]any{"cubing", "enabledRecipes", i}, "unknown recipe %q", recipe)
+		}
+	}
+
+	for i, recipe := range cfg.Game.Leveling.EnabledRunewordRecipes {
+		if !slices.Contains(AvailableRunewordRecipes, recipe) {
+			v.addf([]any{"game", "leveling", "enabledRunewordRecipes", i}, "unknown runeword recipe %q", recipe)
+		}
+	}
+
+	for i, column := range cfg.Inventory.BeltColumns {
+		if !slices.Contains(beltColumnTypes, strings.ToLower(column)) {
+			v.addf([]any{"inventory", "beltColumns", i}, "invalid belt column %q, allowed values: %s", column, strings.Join(beltColumnTypes, ", "))
+		}
+	}
+
+	h := cfg.Health
+	v.checkPercent([]any{"health", "healingPotionAt"}, h.HealingPotionAt)
+	v.checkPercent([]any{"health", "manaPotionAt"}, h.ManaPotionAt)
+	v.checkPercent([]any{"health", "rejuvPotionAtLife"}, h.RejuvPotionAtLife)
+	v.checkPercent([]any{"health", "rejuvPotionAtMana"}, h.RejuvPotionAtMana)
+	v.checkPercent([]any{"health", "chickenAt"}, h.ChickenAt)
+	v.checkPercent([]any{"health", "mercHealingPotionAt"}, h.MercHealingPotionAt)
+	v.checkPercent([]any{"health", "mercRejuvPotionAt"}, h.MercRejuvPotionAt)
+	v.checkPercent([]any{"health", "mercChickenAt"}, h.MercChickenAt)
+	v.checkOrder("health", "chickenAt", h.ChickenAt, "rejuvPotionAtLife", h.RejuvPotionAtLife)
+	v.checkOrder("health", "rejuvPotionAtLife", h.RejuvPotionAtLife, "healingPotionAt", h.HealingPotionAt)
+	v.checkOrder("health", "chickenAt", h.ChickenAt, "healingPotionAt", h.HealingPotionAt)
+	v.checkOrder("health", "mercChickenAt", h.MercChickenAt, "mercRejuvPotionAt", h.MercRejuvPotionAt)
+	v.checkOrder("health", "mercRejuvPotionAt", h.MercRejuvPotionAt, "mercHealingPotionAt", h.MercHealingPotionAt)
+	v.checkOrder("health", "mercChickenAt", h.MercChickenAt, "mercHealingPotionAt", h.MercHealingPotionAt)
+}
+
+func (v *validator) checkPercent(path []any, value int) {
+	if value < 0 || value > 100 {
+		v.addf(path, "must be between 0 and 100, got %d", value)
+	}
+}
+
+// checkOrder requires lower < higher, a 0 disables the threshold so it's not compared
+func (v *validator) checkOrder(section, lowerKey string, lower int, higherKey string, higher int) {
+	if lower <= 0 || higher <= 0 || lower < higher {
+		return
+	}
+
+	v.addf([]any{section, lowerKey}, "must be lower than %s (%d), got %d", higherKey, higher, lower)
+}
+
+func (v *validator) addf(path []any, format string, args ...any) {
+	file, line := v.locate(path)
+	v.errs = append(v.errs, ValidationError{
+		File:    file,
+		Line:    line,
+		Field:   fieldName(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// locate returns the file and line where the value at path is set, the character file is checked before its profiles
+func (v *validator) locate(path []any) (string, int) {
+	for i := len(v.layers) - 1; i >= 0; i-- {
+		if line, found := nodeLine(&v.layers[i].root, path); found {
+			return v.layers[i].file, line
+		}
+	}
+
+	character := v.layers[len(v.layers)-1]
+	line, _ := nodeLine(&character.root, path)
+
+	return character.file, line
+}
+
+// nodeLine returns the line of the node at path (map keys and sequence indexes), or the closest parent found and false
+func nodeLine(root *yaml.Node, path []any) (int, bool) {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := 0
+	for _, p := range path {
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return line, false
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+					line = node.Content[i].Line
+					break
+				}
+			}
+		case int:
+			if node.Kind != yaml.SequenceNode || key >= len(node.Content) {
+				return line, false
+			}
+			next = node.Content[key]
+			line = next.Line
+		}
+		if next == nil {
+			return line, false
+		}
+		node = next
+	}
+
+	return line, true
+}
+
+// keyPathAt returns the dotted path of the mapping key found at line, empty if there is none
+func keyPathAt(node *yaml.Node, line int, key string) string {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			if p := keyPathAt(child, line, key); p != "" {
+				return p
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, value := node.Content[i], node.Content[i+1]
+			if k.Line == line && k.Value == key {
+				return key
+			}
+			if p := keyPathAt(value, line, key); p != "" {
+				return k.Value + "." + p
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			if p := keyPathAt(child, line, key); p != "" {
+				return fmt.Sprintf("[%d].%s", i, p)
+			}
+		}
+	}
+
+	return ""
+}
+
+func fieldName(path []any) string {
+	var sb strings.Builder
+	for _, p := range path {
+		switch key := p.(type) {
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(key)
+		case int:
+			fmt.Fprintf(&sb, "[%d]", key)
+		}
+	}
+
+	return sb.String()
+}
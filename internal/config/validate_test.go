@@ -0,0 +1,123 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+// beltColumns are required in every config
+const testBeltColumns = "inventory:\n  beltColumns: [healing, healing, mana, mana]\n"
+
+func TestDecodeCharacterConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []ValidationError
+	}{
+		{
+			name: "valid",
+			content: `character:
+  class: sorceress
+game:
+  runs: [pindleskin]
+`,
+		},
+		{
+			name: "unknown keys",
+			content: `character:
+  class: sorceress
+  clas: hammerdin
+unknownKey: true
+`,
+			expected: []ValidationError{
+				{Line: 3, Field: "character.clas", Message: "unknown key"},
+				{Line: 4, Field: "unknownKey", Message: "unknown key"},
+			},
+		},
+		{
+			name: "wrong type",
+			content: `character:
+  class: sorceress
+health:
+  chickenAt: thirty
+`,
+			expected: []ValidationError{
+				{Line: 4},
+			},
+		},
+		{
+			name: "invalid values",
+			content: `character:
+  class: necromancer
+game:
+  runs: [pindleskin, cows2]
+health:
+  healingPotionAt: 150
+  chickenAt: 40
+  rejuvPotionAtLife: 30
+`,
+			expected: []ValidationError{
+				{Line: 4, Field: "game.runs[1]"},
+				{Line: 2, Field: "character.class"},
+				{Line: 6, Field: "health.healingPotionAt"},
+				{Line: 7, Field: "health.chickenAt"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := DecodeCharacterConfig("config.yaml", []byte(tc.content+testBeltColumns), &CharacterCfg{})
+			if len(tc.expected) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected ValidationErrors, got %v", err)
+			}
+			if len(errs) != len(tc.expected) {
+				t.Fatalf("expected %d errors, got %d: %v", len(tc.expected), len(errs), errs)
+			}
+			for i, expected := range tc.expected {
+				got := errs[i]
+				if got.File != "config.yaml" || got.Line != expected.Line {
+					t.Errorf("error %d: expected line %d, got %s", i, expected.Line, got)
+				}
+				if expected.Field != "" && got.Field != expected.Field {
+					t.Errorf("error %d: expected field %s, got %s", i, expected.Field, got)
+				}
+				if expected.Message != "" && got.Message != expected.Message {
+					t.Errorf("error %d: expected message %q, got %s", i, expected.Message, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCharacterConfigSyntaxError(t *testing.T) {
+	err := DecodeCharacterConfig("config.yaml", []byte("character:\n  class: [sorceress\n"), &CharacterCfg{})
+	if err == nil {
+		t.Fatal("expected an error for invalid YAML")
+	}
+
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		t.Errorf("a YAML syntax error is not a ValidationErrors, got %v", errs)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	errs := ValidationErrors{
+		{File: "a/config.yaml", Line: 3, Field: "character.class", Message: "unknown class"},
+		{File: "b/config.yaml", Message: "error loading config.yaml"},
+	}
+
+	expected := "2 config error(s):\na/config.yaml:3: character.class: unknown class\nb/config.yaml: error loading config.yaml"
+	if errs.Error() != expected {
+		t.Errorf("unexpected message:\n%s", errs.Error())
+	}
+}
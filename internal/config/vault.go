@@ -0,0 +1,345 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// VaultPassphraseEnv unlocks the vault on startup when it's set, otherwise it's unlocked from the settings page
+const VaultPassphraseEnv = "KOOLO_VAULT_PASSPHRASE"
+
+const (
+	vaultVersion = 1
+	vaultKDF     = "argon2id"
+	vaultKeyLen  = 32 // AES-256
+	vaultAAD     = "koolo-vault"
+
+	// Upper bounds of the key derivation parameters read from the file, so a tampered vault can't make Unlock use an
+	// arbitrary amount of memory or time. The vaults written by Koolo use 3 passes, 64 MiB and 4 threads.
+	vaultMaxTime    = 10
+	vaultMaxMemory  = 1024 * 1024 // KiB, 1 GiB
+	vaultMaxThreads = 16
+)
+
+var (
+	ErrVaultLocked     = errors.New("the secrets vault is locked")
+	ErrWrongPassphrase = errors.New("wrong vault passphrase")
+)
+
+// DefaultVault is the vault used by the ${vault:name} references
+var DefaultVault = NewVault(filepath.Join("config", "secrets.vault"))
+
+// vaultFile is the vault on disk, the secrets are a JSON object encrypted with AES-GCM using a key derived from the
+// master passphrase, so the file is safe to back up and the same on every OS
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // KiB
+	Threads uint8  `json:"threads"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// validateKDF checks the key derivation parameters are within the bounds, argon2 also panics with 0 passes or threads
+func (f vaultFile) validateKDF() error {
+	if f.Time < 1 || f.Time > vaultMaxTime {
+		return fmt.Errorf("key derivation passes %d out of range 1-%d", f.Time, vaultMaxTime)
+	}
+	if f.Threads < 1 || f.Threads > vaultMaxThreads {
+		return fmt.Errorf("key derivation threads %d out of range 1-%d", f.Threads, vaultMaxThreads)
+	}
+	if f.Memory < 8*uint32(f.Threads) || f.Memory > vaultMaxMemory {
+		return fmt.Errorf("key derivation memory %d KiB out of range %d-%d KiB", f.Memory, 8*uint32(f.Threads), vaultMaxMemory)
+	}
+
+	return nil
+}
+
+// Vault is a SecretStore kept in a single encrypted file, it must be unlocked with the master passphrase before use
+type Vault struct {
+	mu      sync.RWMutex
+	path    string
+	header  vaultFile
+	key     []byte
+	secrets map[string]string
+	// unlocked is closed when the vault is unlocked, see Unlocked
+	unlocked chan struct{}
+}
+
+func NewVault(path string) *Vault {
+	return &Vault{path: path}
+}
+
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Exists returns false until the first secret is stored
+func (v *Vault) Exists() bool {
+	_, err := os.Stat(v.path)
+	return err == nil
+}
+
+func (v *Vault) Locked() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.key == nil
+}
+
+// Unlocked returns a channel closed once the vault is unlocked, so the secrets can be resolved by whoever was waiting
+// for them
+func (v *Vault) Unlocked() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.unlocked == nil {
+		v.unlocked = make(chan struct{})
+		if v.key != nil {
+			close(v.unlocked)
+		}
+	}
+
+	return v.unlocked
+}
+
+// notifyUnlocked closes the Unlocked channel, v.mu must be held
+func (v *Vault) notifyUnlocked() {
+	if v.unlocked == nil {
+		return
+	}
+	select {
+	case <-v.unlocked:
+	default:
+		close(v.unlocked)
+	}
+}
+
+// Unlock decrypts the vault, when it doesn't exist yet the passphrase is the one used to create it
+func (v *Vault) Unlock(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("the vault passphrase can't be empty")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	content, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		if err = v.reset(passphrase); err != nil {
+			return err
+		}
+		v.notifyUnlocked()
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading vault %s: %w", v.path, err)
+	}
+
+	var header vaultFile
+	if err = json.Unmarshal(content, &header); err != nil {
+		return fmt.Errorf("error reading vault %s: %w", v.path, err)
+	}
+	if header.Version != vaultVersion || header.KDF != vaultKDF {
+		return fmt.Errorf("unsupported vault %s, version %d with %s", v.path, header.Version, header.KDF)
+	}
+	if err = header.validateKDF(); err != nil {
+		return fmt.Errorf("unsupported vault %s: %w", v.path, err)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), header.Salt, header.Time, header.Memory, header.Threads, vaultKeyLen)
+	gcm, err := newVaultCipher(key)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, header.Nonce, header.Data, []byte(vaultAAD))
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	secrets := make(map[string]string)
+	if err = json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("error reading vault %s secrets: %w", v.path, err)
+	}
+
+	v.header = header
+	v.key = key
+	v.secrets = secrets
+	v.notifyUnlocked()
+
+	return nil
+}
+
+// Lock forgets the key and the decrypted secrets
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.key = nil
+	v.secrets = nil
+	if v.unlocked != nil {
+		// Waiters from now on wait for the next unlock
+		select {
+		case <-v.unlocked:
+			v.unlocked = nil
+		default:
+		}
+	}
+}
+
+// ChangePassphrase encrypts the vault again with a new passphrase, it must be unlocked
+func (v *Vault) ChangePassphrase(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("the vault passphrase can't be empty")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrVaultLocked
+	}
+	secrets := v.secrets
+	if err := v.reset(passphrase); err != nil {
+		return err
+	}
+	v.secrets = secrets
+
+	return v.save()
+}
+
+func (v *Vault) Get(name string) (string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.key == nil {
+		return "", ErrVaultLocked
+	}
+	value, found := v.secrets[name]
+	if !found {
+		return "", fmt.Errorf("%s: %w", name, ErrSecretNotFound)
+	}
+
+	return value, nil
+}
+
+func (v *Vault) Set(name, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrVaultLocked
+	}
+	v.secrets[name] = value
+
+	return v.save()
+}
+
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.key == nil {
+		return ErrVaultLocked
+	}
+	if _, found := v.secrets[name]; !found {
+		return fmt.Errorf("%s: %w", name, ErrSecretNotFound)
+	}
+	delete(v.secrets, name)
+
+	return v.save()
+}
+
+func (v *Vault) List() ([]string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.key == nil {
+		return nil, ErrVaultLocked
+	}
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// reset derives a new key with a new salt and starts an empty vault, nothing is written until a secret is stored
+func (v *Vault) reset(passphrase string) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("error generating vault salt: %w", err)
+	}
+
+	v.header = vaultFile{
+		Version: vaultVersion,
+		KDF:     vaultKDF,
+		Salt:    salt,
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}
+	v.key = argon2.IDKey([]byte(passphrase), salt, v.header.Time, v.header.Memory, v.header.Threads, vaultKeyLen)
+	v.secrets = make(map[string]string)
+
+	return nil
+}
+
+func (v *Vault) save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newVaultCipher(v.key)
+	if err != nil {
+		return err
+	}
+	header := v.header
+	header.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(header.Nonce); err != nil {
+		return fmt.Errorf("error generating vault nonce: %w", err)
+	}
+	header.Data = gcm.Seal(nil, header.Nonce, plain, []byte(vaultAAD))
+
+	content, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Written to a temporary file first, a partial write would lose every secret
+	tmp := v.path + ".tmp"
+	if err = os.WriteFile(tmp, content, 0600); err != nil {
+		return fmt.Errorf("error writing vault %s: %w", v.path, err)
+	}
+	if err = os.Rename(tmp, v.path); err != nil {
+		return fmt.Errorf("error writing vault %s: %w", v.path, err)
+	}
+	v.header = header
+
+	return nil
+}
+
+func newVaultCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+
+	v := NewVault(path)
+	if !v.Locked() {
+		t.Fatal("new vault should be locked")
+	}
+	if _, err := v.Get("discord.token"); !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("expected ErrVaultLocked, got %v", err)
+	}
+	if err := v.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("discord.token", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header vaultFile
+	if err = json.Unmarshal(content, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.KDF != vaultKDF || len(header.Salt) == 0 || len(header.Nonce) == 0 {
+		t.Errorf("unexpected vault header %+v", header)
+	}
+	if json.Valid(header.Data) {
+		t.Error("vault secrets are not encrypted")
+	}
+
+	reopened := NewVault(path)
+	if err = reopened.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	value, err := reopened.Get("discord.token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "secret-token" {
+		t.Errorf("expected secret-token, got %s", value)
+	}
+
+	reopened.Lock()
+	if _, err = reopened.Get("discord.token"); !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("expected ErrVaultLocked after Lock, got %v", err)
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+
+	v := NewVault(path)
+	if err := v.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("discord.token", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewVault(path)
+	if err := reopened.Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if !reopened.Locked() {
+		t.Error("vault unlocked with a wrong passphrase")
+	}
+}
+
+func TestVaultTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+
+	v := NewVault(path)
+	if err := v.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("discord.token", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header vaultFile
+	if err = json.Unmarshal(content, &header); err != nil {
+		t.Fatal(err)
+	}
+	header.Data[len(header.Data)/2] ^= 0xff
+	if content, err = json.Marshal(header); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = NewVault(path).Unlock("passphrase"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected the tampered vault to be rejected, got %v", err)
+	}
+}
+
+func TestVaultChangePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+
+	v := NewVault(path)
+	if err := v.Unlock("old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("discord.token", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.ChangePassphrase("new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewVault(path).Unlock("old"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected the old passphrase to be rejected, got %v", err)
+	}
+	reopened := NewVault(path)
+	if err := reopened.Unlock("new"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := reopened.Get("discord.token"); value != "secret-token" {
+		t.Errorf("expected secret-token, got %s", value)
+	}
+}
+
+func TestVaultUnlocked(t *testing.T) {
+	v := NewVault(filepath.Join(t.TempDir(), "secrets.vault"))
+
+	unlocked := v.Unlocked()
+	select {
+	case <-unlocked:
+		t.Fatal("Unlocked closed while the vault is locked")
+	default:
+	}
+
+	if err := v.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-unlocked:
+	default:
+		t.Fatal("Unlocked not closed after unlocking the vault")
+	}
+
+	v.Lock()
+	select {
+	case <-v.Unlocked():
+		t.Fatal("Unlocked closed after locking the vault again")
+	default:
+	}
+}
+
+func TestVaultRejectsUnsafeKDFParameters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+
+	v := NewVault(path)
+	if err := v.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("discord.token", "secret-token"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tamper := range map[string]func(*vaultFile){
+		"huge memory": func(h *vaultFile) { h.Memory = 64 * 1024 * 1024 },
+		"many passes": func(h *vaultFile) { h.Time = 1 << 20 },
+		"no passes":   func(h *vaultFile) { h.Time = 0 },
+		"no threads":  func(h *vaultFile) { h.Threads = 0 },
+	} {
+		var header vaultFile
+		if err = json.Unmarshal(content, &header); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&header)
+		tampered, err := json.Marshal(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, tampered, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened := NewVault(path)
+		if err = reopened.Unlock("passphrase"); err == nil || errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("%s: expected the parameters to be rejected, got %v", name, err)
+		}
+		if !reopened.Locked() {
+			t.Errorf("%s: vault unlocked", name)
+		}
+	}
+}
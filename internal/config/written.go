@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type writtenStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+var (
+	writtenMux sync.Mutex
+	// writtenFiles are the config files last written by Koolo, so the config watcher can tell them apart from the
+	// changes made by the user
+	writtenFiles = make(map[string]writtenStamp)
+)
+
+// writeConfigFile writes the file and remembers it as written by Koolo.
+func writeConfigFile(path string, content []byte) error {
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		// Written anyway, at worst the watcher reloads it
+		return nil
+	}
+
+	writtenMux.Lock()
+	defer writtenMux.Unlock()
+	writtenFiles[filepath.Clean(path)] = writtenStamp{modTime: info.ModTime(), size: info.Size()}
+
+	return nil
+}
+
+// WrittenByKoolo returns true when the file is still the one Koolo wrote, false once anything else modified it.
+func WrittenByKoolo(path string, modTime time.Time, size int64) bool {
+	writtenMux.Lock()
+	defer writtenMux.Unlock()
+
+	stamp, found := writtenFiles[filepath.Clean(path)]
+
+	return found && stamp.modTime.Equal(modTime) && stamp.size == size
+}
@@ -1,6 +1,7 @@
 package context
 
 import (
+	"fmt"
 	"log/slog"
 	"runtime"
 	"strconv"
@@ -76,6 +77,15 @@ type CurrentGameHelper struct {
 	PickupItems                bool
 	FailedToCreateGameAttempts int
 	FailedMenuAttempts         int
+	// ItemDecisions are the decisions already sent during this game, items are evaluated again on every loop
+	ItemDecisions map[ItemDecisionKey]struct{}
+}
+
+type ItemDecisionKey struct {
+	UnitID data.UnitID
+	Stage  event.DecisionStage
+	Action event.DecisionAction
+	Reason event.DecisionReason
 }
 
 func (ctx *Context) StopSupervisor() {
@@ -115,6 +125,7 @@ func NewGameHelper() *CurrentGameHelper {
 		PickedUpItems:              make(map[int]int),
 		BlacklistedItems:           []data.Item{},
 		FailedToCreateGameAttempts: 0,
+		ItemDecisions:              make(map[ItemDecisionKey]struct{}),
 	}
 }
 
@@ -170,6 +181,24 @@ func (ctx *Context) EnableItemPickup() {
 	ctx.CurrentGame.PickupItems = true
 }
 
+// RecordItemDecision sends the decision taken for an item, repeated decisions for the same item are sent once per game
+func (ctx *Context) RecordItemDecision(decision event.ItemDecision) {
+	key := ItemDecisionKey{UnitID: decision.Item.UnitID, Stage: decision.Stage, Action: decision.Action, Reason: decision.Reason}
+	if _, found := ctx.CurrentGame.ItemDecisions[key]; found {
+		return
+	}
+	ctx.CurrentGame.ItemDecisions[key] = struct{}{}
+
+	ctx.Logger.Debug("Item decision",
+		slog.String("item", string(decision.Item.Name)),
+		slog.String("stage", string(decision.Stage)),
+		slog.String("action", string(decision.Action)),
+		slog.String("reason", string(decision.Reason)),
+		slog.String("rule", decision.Rule),
+	)
+	event.Send(event.ItemDecided(event.Text(ctx.Name, fmt.Sprintf("%s %s: %s", decision.Action, decision.Item.Name, decision.Reason)), decision))
+}
+
 func (s *Status) PauseIfNotPriority() {
 	// This prevents bot from trying to move when loading screen is shown.
 	if s.Data.OpenMenus.LoadingScreen {
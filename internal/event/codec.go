@@ -0,0 +1,118 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"reflect"
+	"time"
+)
+
+// registry contains every event type that can be decoded, new event types must be added here
+var registry = typesByName(
+	BaseEvent{},
+	UsedPotionEvent{},
+	GameCreatedEvent{},
+	GameFinishedEvent{},
+	RunFinishedEvent{},
+	ItemStashedEvent{},
+	RunStartedEvent{},
+	ItemBlackListedEvent{},
+	CompanionLeaderAttackEvent{},
+	CompanionRequestedTPEvent{},
+	InteractedToEvent{},
+	GamePausedEvent{},
+	RequestCompanionJoinGameEvent{},
+	ResetCompanionGameInfoEvent{},
+	ConfigChangedEvent{},
+	ItemDecisionEvent{},
+)
+
+var baseEventType = reflect.TypeOf(BaseEvent{})
+
+// encodedEvent is the lossless serialized form of an event, including its screenshot as JPEG
+type encodedEvent struct {
+	Type       string          `json:"type"`
+	Supervisor string          `json:"supervisor"`
+	Message    string          `json:"message"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Image      []byte          `json:"image,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func typesByName(events ...Event) map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(events))
+	for _, e := range events {
+		types[TypeName(e)] = reflect.TypeOf(e)
+	}
+
+	return types
+}
+
+// Marshal serializes the event so it can be restored later with Unmarshal
+func Marshal(e Event) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding event data: %w", err)
+	}
+
+	enc := encodedEvent{
+		Type:       TypeName(e),
+		Supervisor: e.Supervisor(),
+		Message:    e.Message(),
+		OccurredAt: e.OccurredAt(),
+		Data:       data,
+	}
+
+	if e.Image() != nil {
+		buf := new(bytes.Buffer)
+		if err = jpeg.Encode(buf, e.Image(), &jpeg.Options{Quality: 80}); err != nil {
+			return nil, fmt.Errorf("error encoding event image: %w", err)
+		}
+		enc.Image = buf.Bytes()
+	}
+
+	return json.Marshal(enc)
+}
+
+// Unmarshal restores an event serialized with Marshal, keeping its concrete type
+func Unmarshal(b []byte) (Event, error) {
+	var enc encodedEvent
+	if err := json.Unmarshal(b, &enc); err != nil {
+		return nil, fmt.Errorf("error decoding event: %w", err)
+	}
+
+	t, found := registry[enc.Type]
+	if !found {
+		return nil, fmt.Errorf("unknown event type %s", enc.Type)
+	}
+
+	be := BaseEvent{
+		message:    enc.Message,
+		occurredAt: enc.OccurredAt,
+		supervisor: enc.Supervisor,
+	}
+	if len(enc.Image) > 0 {
+		img, err := jpeg.Decode(bytes.NewReader(enc.Image))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding event image: %w", err)
+		}
+		be.image = img
+	}
+
+	v := reflect.New(t).Elem()
+	if t == baseEventType {
+		v.Set(reflect.ValueOf(be))
+		return v.Interface().(Event), nil
+	}
+
+	if len(enc.Data) > 0 {
+		if err := json.Unmarshal(enc.Data, v.Addr().Interface()); err != nil {
+			return nil, fmt.Errorf("error decoding %s data: %w", enc.Type, err)
+		}
+	}
+	v.FieldByName("BaseEvent").Set(reflect.ValueOf(be))
+
+	return v.Interface().(Event), nil
+}
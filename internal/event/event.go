@@ -2,6 +2,8 @@ package event
 
 import (
 	"image"
+	"reflect"
+	"strings"
 	"time"
 )
 
@@ -54,3 +56,41 @@ func Text(supervisor string, message string) BaseEvent {
 		supervisor: supervisor,
 	}
 }
+
+// Envelope is the JSON representation of an event for external consumers, Data holds the typed event fields
+type Envelope struct {
+	Type       string    `json:"type"`
+	Supervisor string    `json:"supervisor"`
+	Message    string    `json:"message"`
+	Timestamp  time.Time `json:"timestamp"`
+	HasImage   bool      `json:"hasImage"`
+	Data       Event     `json:"data,omitempty"`
+}
+
+func NewEnvelope(e Event) Envelope {
+	return Envelope{
+		Type:       TypeName(e),
+		Supervisor: e.Supervisor(),
+		Message:    e.Message(),
+		Timestamp:  e.OccurredAt(),
+		HasImage:   e.Image() != nil,
+		Data:       e,
+	}
+}
+
+// TypeName returns the name of the concrete event type, e.g. "RunFinishedEvent"
+func TypeName(e Event) string {
+	t := reflect.TypeOf(e)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Name()
+}
+
+// MatchesType checks if the event type matches name, the "Event" suffix is optional and the comparison is case-insensitive
+func MatchesType(e Event, name string) bool {
+	typeName := TypeName(e)
+
+	return strings.EqualFold(typeName, name) || strings.EqualFold(strings.TrimSuffix(typeName, "Event"), name)
+}
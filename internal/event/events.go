@@ -1,6 +1,8 @@
 package event
 
 import (
+	"time"
+
 	"github.com/hectorgimenez/d2go/pkg/data"
 )
 
@@ -30,6 +32,11 @@ func UsedPotion(be BaseEvent, pt data.PotionType, onMerc bool) UsedPotionEvent {
 	}
 }
 
+// UsedPotionAt rebuilds a potion event from its data, like the persisted history does, keeping when it occurred
+func UsedPotionAt(supervisor string, occurredAt time.Time, pt data.PotionType, onMerc bool) UsedPotionEvent {
+	return UsedPotion(BaseEvent{occurredAt: occurredAt, supervisor: supervisor}, pt, onMerc)
+}
+
 type GameCreatedEvent struct {
 	BaseEvent
 	Name     string
@@ -181,3 +188,94 @@ func ResetCompanionGameInfo(be BaseEvent, leader string) ResetCompanionGameInfoE
 		Leader:    leader,
 	}
 }
+
+// ConfigChange is a value changed in a character config, Path uses the config.yaml keys or "pickit" for the rules
+type ConfigChange struct {
+	Path string
+	Old  string
+	New  string
+}
+
+// ConfigChangedEvent is sent when a character config changes on disk. Error is set when the new config was rejected,
+// the previous one is still in use then.
+type ConfigChangedEvent struct {
+	BaseEvent
+	Changes []ConfigChange
+	Error   string
+}
+
+func ConfigChanged(be BaseEvent, changes []ConfigChange, err error) ConfigChangedEvent {
+	e := ConfigChangedEvent{
+		BaseEvent: be,
+		Changes:   changes,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
+
+// DecisionStage is the part of the bot deciding what to do with an item
+type DecisionStage string
+
+const (
+	StagePickup DecisionStage = "pickup"
+	StageStash  DecisionStage = "stash"
+	StageSell   DecisionStage = "sell"
+	StageDrop   DecisionStage = "drop"
+)
+
+// DecisionAction is what is done with the item
+type DecisionAction string
+
+const (
+	ActionPickup DecisionAction = "pickup"
+	ActionIgnore DecisionAction = "ignore"
+	ActionStash  DecisionAction = "stash"
+	ActionKeep   DecisionAction = "keep"
+	ActionSell   DecisionAction = "sell"
+	ActionDrop   DecisionAction = "drop"
+)
+
+// DecisionReason explains the action, Rule is set too when it's based on a pickit rule
+type DecisionReason string
+
+const (
+	ReasonRuleMatch     DecisionReason = "rule_match"
+	ReasonRulePartial   DecisionReason = "rule_partial_match"
+	ReasonNoRuleMatch   DecisionReason = "no_rule_match"
+	ReasonMaxQuantity   DecisionReason = "max_quantity"
+	ReasonFirstRun      DecisionReason = "first_run"
+	ReasonRuneword      DecisionReason = "runeword"
+	ReasonRecipe        DecisionReason = "recipe"
+	ReasonQuestItem     DecisionReason = "quest_item"
+	ReasonProtectedItem DecisionReason = "protected_item"
+	ReasonLockedSlot    DecisionReason = "locked_slot"
+	ReasonPotion        DecisionReason = "potion"
+	ReasonLowGold       DecisionReason = "low_gold"
+	ReasonGoldFull      DecisionReason = "gold_full"
+	ReasonExcessKeys    DecisionReason = "excess_keys"
+)
+
+// ItemDecision is the reasoning behind what the bot does with an item, Item is the item as it was evaluated
+type ItemDecision struct {
+	Stage    DecisionStage
+	Action   DecisionAction
+	Reason   DecisionReason
+	Rule     string `json:",omitempty"`
+	RuleFile string `json:",omitempty"`
+	Item     data.Item
+}
+
+type ItemDecisionEvent struct {
+	BaseEvent
+	Decision ItemDecision
+}
+
+func ItemDecided(be BaseEvent, decision ItemDecision) ItemDecisionEvent {
+	return ItemDecisionEvent{
+		BaseEvent: be,
+		Decision:  decision,
+	}
+}
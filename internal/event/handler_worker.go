@@ -0,0 +1,339 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes the dispatcher wait until the handler has room in its queue
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropOldest discards the oldest queued event to make room for the new one
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowSpill writes the events to disk while the queue is full, they are handled once the queue drains
+	OverflowSpill OverflowPolicy = "spill"
+)
+
+type HandlerOptions struct {
+	Name      string
+	QueueSize int
+	Overflow  OverflowPolicy
+	SpillDir  string
+}
+
+type HandlerOption func(*HandlerOptions)
+
+func WithName(name string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Name = name
+	}
+}
+
+func WithQueueSize(size int) HandlerOption {
+	return func(o *HandlerOptions) {
+		if size > 0 {
+			o.QueueSize = size
+		}
+	}
+}
+
+func WithOverflow(policy OverflowPolicy) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Overflow = policy
+	}
+}
+
+// WithSpill enables the spill-to-disk overflow policy storing the pending events in dir
+func WithSpill(dir string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Overflow = OverflowSpill
+		o.SpillDir = dir
+	}
+}
+
+// HandlerStats contains the queue and latency metrics of a single handler
+type HandlerStats struct {
+	Name        string
+	Overflow    OverflowPolicy
+	Queued      int
+	Spilled     int
+	Processed   uint64
+	Dropped     uint64
+	Errors      uint64
+	AvgLatency  time.Duration
+	MaxLatency  time.Duration
+	LastLatency time.Duration
+	AvgWait     time.Duration
+}
+
+type queuedEvent struct {
+	e        Event
+	queuedAt time.Time
+}
+
+type handlerWorker struct {
+	handler Handler
+	opts    HandlerOptions
+	queue   chan queuedEvent
+	wakeup  chan struct{}
+	stopped chan struct{}
+	logger  *slog.Logger
+
+	mu           sync.Mutex
+	spilled      []string
+	spillSeq     uint64
+	pending      int
+	processed    uint64
+	dropped      uint64
+	errors       uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+	lastLatency  time.Duration
+	totalWait    time.Duration
+}
+
+func newHandlerWorker(h Handler, opts HandlerOptions, logger *slog.Logger) (*handlerWorker, error) {
+	w := &handlerWorker{
+		handler: h,
+		opts:    opts,
+		queue:   make(chan queuedEvent, opts.QueueSize),
+		wakeup:  make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+
+	if opts.Overflow == OverflowSpill {
+		if opts.SpillDir == "" {
+			return nil, fmt.Errorf("spill directory is required for the spill overflow policy")
+		}
+		if err := os.MkdirAll(opts.SpillDir, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating spill directory %s: %w", opts.SpillDir, err)
+		}
+
+		// Pick up events spilled by a previous session
+		files, err := filepath.Glob(filepath.Join(opts.SpillDir, "*.event"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		w.spilled = files
+		w.pending = len(files)
+	}
+
+	return w, nil
+}
+
+// push enqueues the event following the overflow policy, only OverflowBlock can block the caller
+func (w *handlerWorker) push(ctx context.Context, e Event) {
+	qe := queuedEvent{e: e, queuedAt: time.Now()}
+	w.addPending(1)
+
+	switch w.opts.Overflow {
+	case OverflowSpill:
+		// Once spilling started, keep spilling until the worker catches up so events are handled in order
+		if w.spilledCount() == 0 {
+			select {
+			case w.queue <- qe:
+				return
+			default:
+			}
+		}
+		if err := w.spill(e); err != nil {
+			w.logger.Error("error spilling event to disk, dropping it", slog.String("handler", w.opts.Name), slog.Any("error", err))
+			w.countDropped()
+			w.addPending(-1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case w.queue <- qe:
+				return
+			default:
+			}
+			select {
+			case <-w.queue:
+				w.countDropped()
+				w.addPending(-1)
+			default:
+			}
+		}
+	default:
+		select {
+		case w.queue <- qe:
+		case <-ctx.Done():
+			w.addPending(-1)
+		}
+	}
+}
+
+func (w *handlerWorker) run(ctx context.Context) {
+	for {
+		select {
+		case qe := <-w.queue:
+			w.handle(ctx, qe)
+			continue
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// In-memory queue is empty, continue with the events spilled to disk
+		if qe, ok := w.unspill(); ok {
+			w.handle(ctx, qe)
+			continue
+		}
+
+		select {
+		case qe := <-w.queue:
+			w.handle(ctx, qe)
+		case <-w.wakeup:
+		case <-w.stopped:
+			// Nothing left in memory nor on disk, the worker was removed from the listener
+			if len(w.queue) == 0 {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop makes the worker exit once its queued and spilled events are handled, it must be called once
+func (w *handlerWorker) stop() {
+	close(w.stopped)
+}
+
+func (w *handlerWorker) handle(ctx context.Context, qe queuedEvent) {
+	start := time.Now()
+	err := w.handler(ctx, qe.e)
+	latency := time.Since(start)
+
+	w.mu.Lock()
+	w.processed++
+	w.pending--
+	w.totalLatency += latency
+	w.lastLatency = latency
+	w.maxLatency = max(w.maxLatency, latency)
+	w.totalWait += start.Sub(qe.queuedAt)
+	if err != nil {
+		w.errors++
+	}
+	w.mu.Unlock()
+
+	if err != nil && qe.e.Message() != "" {
+		w.logger.Error("error running event handler", slog.String("handler", w.opts.Name), slog.Any("error", err))
+	}
+}
+
+func (w *handlerWorker) spill(e Event) error {
+	b, err := Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.spillSeq++
+	// Zero padded names keep lexical order equal to insertion order, also across restarts
+	file := filepath.Join(w.opts.SpillDir, fmt.Sprintf("%020d-%08d.event", time.Now().UnixNano(), w.spillSeq%100000000))
+	w.mu.Unlock()
+
+	if err = os.WriteFile(file, b, 0o644); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.spilled = append(w.spilled, file)
+	w.mu.Unlock()
+
+	select {
+	case w.wakeup <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (w *handlerWorker) unspill() (queuedEvent, bool) {
+	for {
+		w.mu.Lock()
+		if len(w.spilled) == 0 {
+			w.mu.Unlock()
+			return queuedEvent{}, false
+		}
+		file := w.spilled[0]
+		w.spilled = w.spilled[1:]
+		w.mu.Unlock()
+
+		b, err := os.ReadFile(file)
+		_ = os.Remove(file)
+		if err == nil {
+			var e Event
+			if e, err = Unmarshal(b); err == nil {
+				// The file creation time is lost, queue wait is measured from the time it was read back
+				return queuedEvent{e: e, queuedAt: time.Now()}, true
+			}
+		}
+
+		w.logger.Error("error reading spilled event, skipping it", slog.String("handler", w.opts.Name), slog.String("file", file), slog.Any("error", err))
+		w.countDropped()
+		w.addPending(-1)
+	}
+}
+
+func (w *handlerWorker) spilledCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.spilled)
+}
+
+// pendingCount returns the events queued, spilled or being handled right now
+func (w *handlerWorker) pendingCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.pending
+}
+
+func (w *handlerWorker) addPending(n int) {
+	w.mu.Lock()
+	w.pending += n
+	w.mu.Unlock()
+}
+
+func (w *handlerWorker) countDropped() {
+	w.mu.Lock()
+	w.dropped++
+	w.mu.Unlock()
+}
+
+func (w *handlerWorker) stats() HandlerStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := HandlerStats{
+		Name:        w.opts.Name,
+		Overflow:    w.opts.Overflow,
+		Queued:      len(w.queue),
+		Spilled:     len(w.spilled),
+		Processed:   w.processed,
+		Dropped:     w.dropped,
+		Errors:      w.errors,
+		MaxLatency:  w.maxLatency,
+		LastLatency: w.lastLatency,
+	}
+	if w.processed > 0 {
+		s.AvgLatency = w.totalLatency / time.Duration(w.processed)
+		s.AvgWait = w.totalWait / time.Duration(w.processed)
+	}
+
+	return s
+}
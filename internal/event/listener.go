@@ -4,94 +4,283 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"math"
-	"math/rand"
 	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hectorgimenez/koolo/internal/config"
 	"github.com/hectorgimenez/koolo/internal/utils"
 )
 
-var events = make(chan Event)
+const (
+	// eventsBufferSize keeps Send from blocking the bot goroutines while events are dispatched to the handler queues
+	eventsBufferSize = 1024
+	defaultQueueSize = 256
+	// unregisterTimeout is how long Unregister waits for room in a full buffer
+	unregisterTimeout = time.Second
+)
+
+var (
+	events = make(chan Event, eventsBufferSize)
+	// sendDropped counts the events dropped by Send while the buffer was full, they are reported by the dispatcher
+	sendDropped atomic.Uint64
+	// sendDroppedTotal is never reset, it's exported on /metrics
+	sendDroppedTotal atomic.Uint64
+)
 
 type Listener struct {
-	handlers         []Handler
-	deliveryHandlers map[int]Handler
-	logger           *slog.Logger
+	mu          sync.RWMutex
+	workers     []*handlerWorker
+	subscribers map[uint64]chan Event
+	nextSubID   uint64
+	logger      *slog.Logger
+	ctx         context.Context
+	cancel      context.CancelFunc
 }
 
 type Handler func(ctx context.Context, e Event) error
 
+// unregisterEvent is queued by Unregister behind the events already sent, it's never handed to the handlers
+type unregisterEvent struct {
+	BaseEvent
+	worker *handlerWorker
+}
+
 func NewListener(logger *slog.Logger) *Listener {
-	return &Listener{
-		logger:           logger,
-		deliveryHandlers: make(map[int]Handler),
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listener{
+		logger:      logger,
+		subscribers: make(map[uint64]chan Event),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
+
+	// Saving screenshots hits the disk, it gets its own queue like any other handler
+	l.Register(l.saveScreenshot, WithName("screenshots"), WithOverflow(OverflowDropOldest))
+
+	return l
 }
 
-func (l *Listener) Register(h Handler) {
-	l.handlers = append(l.handlers, h)
+// Register adds a handler with its own bounded queue and worker, so a slow handler doesn't delay the rest of them.
+// By default the oldest queued event is dropped when the queue is full, handlers that can't lose events use WithSpill,
+// or WithOverflow(OverflowBlock) to make the dispatcher wait when they are fast enough to keep up, like the stats.
+func (l *Listener) Register(h Handler, opts ...HandlerOption) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	options := HandlerOptions{
+		Name:      fmt.Sprintf("handler-%d", len(l.workers)),
+		QueueSize: defaultQueueSize,
+		Overflow:  OverflowDropOldest,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	w, err := newHandlerWorker(h, options, l.logger)
+	if err != nil {
+		l.logger.Error("error creating event handler queue, falling back to drop-oldest", slog.String("handler", options.Name), slog.Any("error", err))
+		options.Overflow = OverflowDropOldest
+		w, _ = newHandlerWorker(h, options, l.logger)
+	}
+
+	// A handler registered again under the same name replaces the previous one, e.g. when a supervisor is restarted
+	for i, old := range l.workers {
+		if old.opts.Name == options.Name {
+			old.stop()
+			l.workers = without(l.workers, i)
+			break
+		}
+	}
+
+	l.workers = append(l.workers, w)
+	go w.run(l.ctx)
+}
+
+// Unregister removes the handler once the events sent before the call are dispatched, so it still gets the last
+// events of a stopping supervisor. The handler finishes its queued events before its worker exits.
+func (l *Listener) Unregister(name string) {
+	l.mu.RLock()
+	var w *handlerWorker
+	for _, candidate := range l.workers {
+		if candidate.opts.Name == name {
+			w = candidate
+			break
+		}
+	}
+	l.mu.RUnlock()
+	if w == nil {
+		return
+	}
+
+	select {
+	case events <- unregisterEvent{BaseEvent: Text("", ""), worker: w}:
+	case <-l.ctx.Done():
+	case <-time.After(unregisterTimeout):
+		// Buffer is full, the handler is removed right away
+		l.remove(w)
+	}
+}
+
+// remove stops the worker, it's a no-op if the worker was already replaced or removed
+func (l *Listener) remove(w *handlerWorker) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if i := slices.Index(l.workers, w); i >= 0 {
+		w.stop()
+		l.workers = without(l.workers, i)
+	}
+}
+
+// without returns a copy of the workers without the i-th one, dispatch iterates the previous slice without holding the lock
+func without(workers []*handlerWorker, i int) []*handlerWorker {
+	return slices.Concat(workers[:i], workers[i+1:])
 }
 
 func (l *Listener) Listen(ctx context.Context) error {
+	defer l.cancel()
+
 	for {
 		select {
 		case e := <-events:
-			if _, err := os.Stat("screenshots"); os.IsNotExist(err) {
-				err = os.MkdirAll("screenshots", os.ModePerm)
-				if err != nil {
-					l.logger.Error("error creating screenshots directory", slog.Any("error", err))
-				}
-			}
-
-			if e.Image() != nil && config.Koolo.Debug.Screenshots {
-				fileName := fmt.Sprintf("screenshots/error-%s.jpeg", time.Now().Format("2006-01-02 15_04_05"))
-				err := utils.SaveImageJPEG(e.Image(), fileName)
-				if err != nil {
-					l.logger.Error("error saving screenshot", slog.Any("error", err))
-				}
-			}
-
-			for _, h := range l.handlers {
-				if err := h(ctx, e); err != nil && e.Message() != "" {
-					l.logger.Error("error running event handler", slog.Any("error", err))
-				}
-			}
-			for _, h := range l.deliveryHandlers {
-				if err := h(ctx, e); err != nil {
-					l.logger.Error("error running event delivery handler", slog.Any("error", err))
-				}
-			}
-
+			l.dispatch(ctx, e)
 		case <-ctx.Done():
 			return nil
 		}
 	}
 }
 
-func (l *Listener) WaitForEvent(ctx context.Context) Event {
-	evtChan := make(chan Event)
-	idx := rand.Intn(math.MaxInt64)
-	l.deliveryHandlers[idx] = func(ctx context.Context, e Event) error {
-		evtChan <- e
-		return nil
+func (l *Listener) dispatch(ctx context.Context, e Event) {
+	if u, ok := e.(unregisterEvent); ok {
+		l.remove(u.worker)
+		return
 	}
-	// Clean up the handler when we're done
-	defer func() {
-		delete(l.deliveryHandlers, idx)
-	}()
+
+	if dropped := sendDropped.Swap(0); dropped > 0 {
+		l.logger.Warn("Event buffer was full, events were dropped", slog.Uint64("dropped", dropped), slog.Uint64("total", DroppedEvents()))
+	}
+
+	l.mu.RLock()
+	workers := l.workers
+	l.mu.RUnlock()
+
+	for _, w := range workers {
+		w.push(ctx, e)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, sub := range l.subscribers {
+		select {
+		case sub <- e:
+		default:
+			// Subscriber already got an event and is about to return
+		}
+	}
+}
+
+// Dispatch hands the event straight to the handlers without going through Send, it's used to replay recorded events
+func (l *Listener) Dispatch(ctx context.Context, e Event) {
+	l.dispatch(ctx, e)
+}
+
+// Drain blocks until every handler finished with its queued and spilled events, or the context is done
+func (l *Listener) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
 
 	for {
+		l.mu.RLock()
+		pending := 0
+		for _, w := range l.workers {
+			pending += w.pendingCount()
+		}
+		l.mu.RUnlock()
+
+		if pending == 0 {
+			return nil
+		}
+
 		select {
-		case e := <-evtChan:
-			return e
+		case <-ticker.C:
 		case <-ctx.Done():
-			return nil
+			return ctx.Err()
 		}
 	}
 }
 
+func (l *Listener) saveScreenshot(_ context.Context, e Event) error {
+	if e.Image() == nil || !config.Koolo.Debug.Screenshots {
+		return nil
+	}
+
+	if _, err := os.Stat("screenshots"); os.IsNotExist(err) {
+		if err = os.MkdirAll("screenshots", os.ModePerm); err != nil {
+			return fmt.Errorf("error creating screenshots directory: %w", err)
+		}
+	}
+
+	fileName := fmt.Sprintf("screenshots/error-%s.jpeg", e.OccurredAt().Format("2006-01-02 15_04_05"))
+	if err := utils.SaveImageJPEG(e.Image(), fileName); err != nil {
+		return fmt.Errorf("error saving screenshot: %w", err)
+	}
+
+	return nil
+}
+
+// WaitForEvent blocks until the next event is dispatched or the context is done
+func (l *Listener) WaitForEvent(ctx context.Context) Event {
+	evtChan := make(chan Event, 1)
+
+	l.mu.Lock()
+	id := l.nextSubID
+	l.nextSubID++
+	l.subscribers[id] = evtChan
+	l.mu.Unlock()
+
+	// Clean up the subscription when we're done
+	defer func() {
+		l.mu.Lock()
+		delete(l.subscribers, id)
+		l.mu.Unlock()
+	}()
+
+	select {
+	case e := <-evtChan:
+		return e
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// HandlerStats returns the queue and latency metrics of every registered handler
+func (l *Listener) HandlerStats() []HandlerStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := make([]HandlerStats, 0, len(l.workers))
+	for _, w := range l.workers {
+		stats = append(stats, w.stats())
+	}
+
+	return stats
+}
+
+// Send hands the event to the dispatcher, it never blocks the bot: when the buffer is full the event is dropped and
+// counted in DroppedEvents
 func Send(e Event) {
-	events <- e
+	select {
+	case events <- e:
+	default:
+		sendDropped.Add(1)
+		sendDroppedTotal.Add(1)
+	}
+}
+
+// DroppedEvents returns how many events Send dropped since Koolo started because the dispatcher buffer was full
+func DroppedEvents() uint64 {
+	return sendDroppedTotal.Load()
 }
@@ -0,0 +1,147 @@
+package event
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendNeverBlocks(t *testing.T) {
+	// Nobody is dispatching, the buffer fills up and the rest of the events are dropped
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < eventsBufferSize+10; i++ {
+			Send(Text("test", "event"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked with a full buffer")
+	}
+	if dropped := sendDropped.Swap(0); dropped != 10 {
+		t.Errorf("expected 10 dropped events, got %d", dropped)
+	}
+	if total := DroppedEvents(); total < 10 {
+		t.Errorf("expected the dropped events in the total, got %d", total)
+	}
+
+	for len(events) > 0 {
+		<-events
+	}
+}
+
+func TestRegisterDefaultsToDropOldest(t *testing.T) {
+	l := NewListener(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer l.cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	l.Register(func(ctx context.Context, e Event) error {
+		<-release
+		return nil
+	}, WithName("stalled"), WithQueueSize(2))
+
+	// The handler is stuck on the first event, the dispatcher must not wait for it
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			l.Dispatch(context.Background(), Text("test", "event"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatcher blocked by a stalled handler")
+	}
+
+	for _, s := range l.HandlerStats() {
+		if s.Name == "stalled" && (s.Overflow != OverflowDropOldest || s.Dropped == 0) {
+			t.Errorf("expected the oldest events to be dropped, got %+v", s)
+		}
+	}
+}
+
+func countingHandler(count *atomic.Int32) Handler {
+	return func(ctx context.Context, e Event) error {
+		count.Add(1)
+		return nil
+	}
+}
+
+func TestRegisterReplacesHandlerWithSameName(t *testing.T) {
+	l := NewListener(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer l.cancel()
+
+	var first, second atomic.Int32
+	l.Register(countingHandler(&first), WithName("stats-char"))
+	l.Register(countingHandler(&second), WithName("stats-char"))
+
+	l.Dispatch(context.Background(), Text("char", "event"))
+	if err := l.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Load() != 0 || second.Load() != 1 {
+		t.Errorf("expected only the last handler to get the event, got %d and %d", first.Load(), second.Load())
+	}
+	handlers := 0
+	for _, s := range l.HandlerStats() {
+		if s.Name == "stats-char" {
+			handlers++
+		}
+	}
+	if handlers != 1 {
+		t.Errorf("expected a single stats-char handler, got %d", handlers)
+	}
+}
+
+func TestUnregisterAfterSentEvents(t *testing.T) {
+	l := NewListener(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Listen(ctx)
+
+	var handled atomic.Int32
+	l.Register(countingHandler(&handled), WithName("stats-char"))
+
+	// Sent before Unregister, the handler still gets them
+	for i := 0; i < 3; i++ {
+		Send(Text("char", "event"))
+	}
+	l.Unregister("stats-char")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for registered(l, "stats-char") || handled.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("handler not removed, %d events handled", handled.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	Send(Text("char", "event"))
+	if err := l.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if handled.Load() != 3 {
+		t.Errorf("expected 3 handled events, got %d", handled.Load())
+	}
+}
+
+func registered(l *Listener, name string) bool {
+	for _, s := range l.HandlerStats() {
+		if s.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
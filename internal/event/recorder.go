@@ -0,0 +1,189 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	recordingPrefix    = "events-"
+	recordingExtension = ".jsonl"
+
+	DefaultRecordingMaxSize  = 100 * 1024 * 1024
+	DefaultRecordingMaxFiles = 10
+)
+
+// Recorder writes every event to a rotating JSONL file, one event encoded with Marshal per line
+type Recorder struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// NewRecorder creates the recorder, a new file is started when the current one reaches maxSize bytes
+// and only the newest maxFiles recordings are kept
+func NewRecorder(dir string, maxSize int64, maxFiles int) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating recordings directory %s: %w", dir, err)
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultRecordingMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultRecordingMaxFiles
+	}
+
+	return &Recorder{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}, nil
+}
+
+func (r *Recorder) Handle(_ context.Context, e Event) error {
+	b, err := Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil || r.size+int64(len(b)) > r.maxSize {
+		if err = r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing event recording: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
+
+func (r *Recorder) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return fmt.Errorf("error closing event recording: %w", err)
+		}
+		r.file = nil
+	}
+
+	name := recordingPrefix + time.Now().Format("2006-01-02-150405.000") + recordingExtension
+	f, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating event recording: %w", err)
+	}
+	r.file = f
+	r.size = 0
+
+	files, err := Recordings(r.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > r.maxFiles {
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// Recordings returns the recording files stored in dir, oldest first
+func Recordings(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, recordingPrefix+"*"+recordingExtension))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// ReadRecording decodes the events stored in path in the same order they were recorded, path can be a single
+// recording or a directory, in that case every recording in it is read oldest first.
+func ReadRecording(path string, fn func(Event) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = Recordings(path); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		if err = readRecordingFile(file, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readRecordingFile(file string, fn func(Event) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Lines can be big when the event contains a screenshot, bufio.Reader has no line length limit
+	reader := bufio.NewReader(f)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			e, err := Unmarshal(line)
+			switch {
+			case err != nil && errors.Is(readErr, io.EOF):
+				// Last line was cut in the middle of a write, usually because Koolo crashed
+				return nil
+			case err != nil:
+				return fmt.Errorf("%s:%d: %w", file, lineNumber, err)
+			}
+			if err = fn(e); err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package game
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/area"
+)
+
+// AreaFixtureVersion is increased every time the fixture format changes in a non compatible way
+const AreaFixtureVersion = 1
+
+// AreaFixture is a snapshot of the map data around the player, dumped from the debug page, so the pathing can be tested
+// offline without the game.
+//
+// Fixtures are stored as gzip compressed JSON (.json.gz). The first area is the one the player was in, followed by its
+// adjacent areas. Every collision grid row is stored as a string with one character per cell, see fixtureCells. Grids
+// are stored as they are in AreaData, with the low priority cells close to the walls already set, so they are not
+// processed again when loaded.
+type AreaFixture struct {
+	Version    int
+	PlayerArea area.ID
+	Player     data.Position
+	Monsters   data.Monsters
+	Areas      []AreaSnapshot
+}
+
+// AreaSnapshot is the AreaData of a single area, positions are absolute like in the game
+type AreaSnapshot struct {
+	Area           area.ID
+	Name           string
+	OffsetX        int
+	OffsetY        int
+	CollisionGrid  []string
+	AdjacentLevels []data.Level
+	Rooms          []data.Room
+	Objects        []data.Object
+	NPCs           data.NPCs
+}
+
+// fixtureCells are the characters used for every collision type in the fixture grids
+var fixtureCells = map[CollisionType]byte{
+	CollisionTypeNonWalkable: '#',
+	CollisionTypeWalkable:    '.',
+	CollisionTypeLowPriority: '-',
+	CollisionTypeMonster:     'm',
+	CollisionTypeObject:      'o',
+}
+
+// NewAreaFixture takes the snapshot of the current area and the adjacent ones
+func NewAreaFixture(d *Data) (AreaFixture, error) {
+	if d.AreaData.Grid == nil {
+		return AreaFixture{}, fmt.Errorf("no map data loaded for area %d", d.PlayerUnit.Area)
+	}
+
+	f := AreaFixture{
+		Version:    AreaFixtureVersion,
+		PlayerArea: d.PlayerUnit.Area,
+		Player:     d.PlayerUnit.Position,
+		Monsters:   d.Monsters,
+		Areas:      []AreaSnapshot{newAreaSnapshot(d.AreaData)},
+	}
+	for _, lvl := range d.AreaData.AdjacentLevels {
+		if adjacent, found := d.Areas[lvl.Area]; found && adjacent.Grid != nil {
+			f.Areas = append(f.Areas, newAreaSnapshot(adjacent))
+		}
+	}
+
+	return f, nil
+}
+
+func newAreaSnapshot(a AreaData) AreaSnapshot {
+	rows := make([]string, 0, a.Height)
+	row := make([]byte, a.Width)
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			row[x] = fixtureCells[a.CollisionGrid[y][x]]
+		}
+		rows = append(rows, string(row))
+	}
+
+	return AreaSnapshot{
+		Area:           a.Area,
+		Name:           a.Name,
+		OffsetX:        a.OffsetX,
+		OffsetY:        a.OffsetY,
+		CollisionGrid:  rows,
+		AdjacentLevels: a.AdjacentLevels,
+		Rooms:          a.Rooms,
+		Objects:        a.Objects,
+		NPCs:           a.NPCs,
+	}
+}
+
+// Data returns the game data with the fixture loaded, like it was read from the game
+func (f AreaFixture) Data() (*Data, error) {
+	if len(f.Areas) == 0 {
+		return nil, fmt.Errorf("fixture without areas")
+	}
+
+	d := &Data{Areas: make(map[area.ID]AreaData, len(f.Areas))}
+	for _, s := range f.Areas {
+		a, err := s.areaData()
+		if err != nil {
+			return nil, fmt.Errorf("error loading area %s: %w", s.Name, err)
+		}
+		d.Areas[a.Area] = a
+	}
+
+	current := d.Areas[f.Areas[0].Area]
+	d.AreaData = current
+	d.AreaOrigin = data.Position{X: current.OffsetX, Y: current.OffsetY}
+	d.AdjacentLevels = current.AdjacentLevels
+	d.Rooms = current.Rooms
+	d.NPCs = current.NPCs
+	d.Objects = current.Objects
+	d.Monsters = f.Monsters
+	d.PlayerUnit.Area = f.PlayerArea
+	d.PlayerUnit.Position = f.Player
+
+	return d, nil
+}
+
+func (s AreaSnapshot) areaData() (AreaData, error) {
+	cells := make(map[byte]CollisionType, len(fixtureCells))
+	for t, c := range fixtureCells {
+		cells[c] = t
+	}
+
+	if len(s.CollisionGrid) == 0 {
+		return AreaData{}, fmt.Errorf("empty collision grid")
+	}
+
+	width := len(s.CollisionGrid[0])
+	cg := make([][]CollisionType, len(s.CollisionGrid))
+	for y, row := range s.CollisionGrid {
+		if len(row) != width {
+			return AreaData{}, fmt.Errorf("row %d has %d cells, expected %d", y, len(row), width)
+		}
+		cg[y] = make([]CollisionType, width)
+		for x := 0; x < width; x++ {
+			t, found := cells[row[x]]
+			if !found {
+				return AreaData{}, fmt.Errorf("unknown cell %q at %d,%d", row[x], x, y)
+			}
+			cg[y][x] = t
+		}
+	}
+
+	return AreaData{
+		Area:           s.Area,
+		Name:           s.Name,
+		NPCs:           s.NPCs,
+		AdjacentLevels: s.AdjacentLevels,
+		Objects:        s.Objects,
+		Rooms:          s.Rooms,
+		Grid: &Grid{
+			OffsetX:       s.OffsetX,
+			OffsetY:       s.OffsetY,
+			Width:         width,
+			Height:        len(cg),
+			CollisionGrid: cg,
+		},
+	}, nil
+}
+
+// WriteAreaFixture writes the fixture with the format expected by ReadAreaFixture
+func WriteAreaFixture(w io.Writer, f AreaFixture) error {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("error encoding fixture: %w", err)
+	}
+
+	return gz.Close()
+}
+
+func ReadAreaFixture(r io.Reader) (AreaFixture, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return AreaFixture{}, fmt.Errorf("error reading fixture: %w", err)
+	}
+	defer gz.Close()
+
+	var f AreaFixture
+	if err := json.NewDecoder(gz).Decode(&f); err != nil {
+		return AreaFixture{}, fmt.Errorf("error decoding fixture: %w", err)
+	}
+	if f.Version != AreaFixtureVersion {
+		return AreaFixture{}, fmt.Errorf("fixture version %d not supported, expected %d", f.Version, AreaFixtureVersion)
+	}
+
+	return f, nil
+}
+
+// FixtureName returns a file name for the fixture, based on the area
+func (f AreaFixture) FixtureName() string {
+	name := "fixture"
+	if len(f.Areas) > 0 {
+		name = strings.ToLower(strings.Join(strings.Fields(f.Areas[0].Name), "_"))
+	}
+
+	return name + ".json.gz"
+}
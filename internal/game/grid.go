@@ -18,6 +18,9 @@ type Grid struct {
 	Width         int
 	Height        int
 	CollisionGrid [][]CollisionType
+	// DangerGrid is the extra cost of walking through every cell, like close to monsters. It's nil until some danger
+	// is set, a cell with 0 has no extra cost.
+	DangerGrid [][]uint16
 }
 
 func NewGrid(rawCollisionGrid [][]CollisionType, offsetX, offsetY int) *Grid {
@@ -65,6 +68,29 @@ func (g *Grid) IsWalkable(p data.Position) bool {
 	return p.X >= 0 && p.X < g.Width && p.Y >= 0 && p.Y < g.Height && g.CollisionGrid[p.Y][p.X] != CollisionTypeNonWalkable
 }
 
+// Danger returns the extra cost of walking through the cell, p is relative to the grid
+func (g *Grid) Danger(p data.Position) int {
+	if g.DangerGrid == nil {
+		return 0
+	}
+
+	return int(g.DangerGrid[p.Y][p.X])
+}
+
+// SetDanger sets the extra cost of walking through the cell, p is relative to the grid
+func (g *Grid) SetDanger(p data.Position, cost uint16) {
+	if g.DangerGrid == nil {
+		if cost == 0 {
+			return
+		}
+		g.DangerGrid = make([][]uint16, g.Height)
+		for y := range g.DangerGrid {
+			g.DangerGrid[y] = make([]uint16, g.Width)
+		}
+	}
+	g.DangerGrid[p.Y][p.X] = cost
+}
+
 func (g *Grid) Copy() *Grid {
 	cg := make([][]CollisionType, g.Height)
 	for y := 0; y < g.Height; y++ {
@@ -72,11 +98,21 @@ func (g *Grid) Copy() *Grid {
 		copy(cg[y], g.CollisionGrid[y])
 	}
 
+	var dg [][]uint16
+	if g.DangerGrid != nil {
+		dg = make([][]uint16, g.Height)
+		for y := 0; y < g.Height; y++ {
+			dg[y] = make([]uint16, g.Width)
+			copy(dg[y], g.DangerGrid[y])
+		}
+	}
+
 	return &Grid{
 		OffsetX:       g.OffsetX,
 		OffsetY:       g.OffsetY,
 		Width:         g.Width,
 		Height:        g.Height,
 		CollisionGrid: cg,
+		DangerGrid:    dg,
 	}
 }
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package game
+
+import "github.com/hectorgimenez/d2go/pkg/data"
+
+// The game can only be read and controlled on Windows. On other systems these types let the packages working with the
+// game data, like the pather and its map fixtures, build and run their tests offline.
+
+type MemoryReader struct {
+	GameAreaSizeX int
+	GameAreaSizeY int
+}
+
+type HID struct{}
+
+type MouseButton uint
+
+const (
+	RightButton MouseButton = 0x0002
+	LeftButton  MouseButton = 0x0001
+)
+
+func (hid *HID) MovePointer(x, y int) {}
+
+func (hid *HID) Click(btn MouseButton, x, y int) {}
+
+func (hid *HID) PressKeyBinding(kb data.KeyBinding) {}
@@ -0,0 +1,286 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/event"
+)
+
+type RecordType string
+
+const (
+	GameCreated  RecordType = "game_created"
+	GameFinished RecordType = "game_finished"
+	RunStarted   RecordType = "run_started"
+	RunFinished  RecordType = "run_finished"
+	PotionUsed   RecordType = "potion_used"
+	ItemStashed  RecordType = "item_stashed"
+	ItemDecision RecordType = "item_decision"
+
+	filePrefix = "history-"
+	fileSuffix = ".jsonl"
+	dayLayout  = "2006-01-02"
+)
+
+// persistedDecisions are the item decisions stored in the history, the rest of them are only sent as events
+var persistedDecisions = []event.DecisionAction{event.ActionPickup, event.ActionStash, event.ActionSell, event.ActionDrop}
+
+// Record is a single persisted entry of the run/game history. Only the fields relevant to the Type are populated.
+type Record struct {
+	Time       time.Time           `json:"time"`
+	Supervisor string              `json:"supervisor"`
+	Type       RecordType          `json:"type"`
+	GameName   string              `json:"gameName,omitempty"`
+	RunName    string              `json:"runName,omitempty"`
+	Reason     event.FinishReason  `json:"reason,omitempty"`
+	PotionType data.PotionType     `json:"potionType,omitempty"`
+	OnMerc     bool                `json:"onMerc,omitempty"`
+	Drop       *data.Drop          `json:"drop,omitempty"`
+	Decision   *event.ItemDecision `json:"decision,omitempty"`
+}
+
+// Query filters history records, empty fields are ignored.
+type Query struct {
+	Supervisor string
+	RunName    string
+	Reason     event.FinishReason
+	Types      []RecordType
+	From       time.Time
+	To         time.Time
+	Limit      int
+}
+
+// Store is an append-only history, persisted as daily rotated JSONL files inside dir. Files older than retentionDays
+// are deleted, 0 keeps all of them.
+type Store struct {
+	mu            sync.Mutex
+	dir           string
+	retentionDays int
+	prunedAt      string // Day of the last pruning, it runs once a day
+}
+
+func NewStore(dir string, retentionDays int) *Store {
+	return &Store{dir: dir, retentionDays: retentionDays}
+}
+
+// FromEvent converts the event into a history record, returns false for events that are not tracked.
+func FromEvent(e event.Event) (Record, bool) {
+	rec := Record{
+		Time:       e.OccurredAt(),
+		Supervisor: e.Supervisor(),
+	}
+
+	switch evt := e.(type) {
+	case event.GameCreatedEvent:
+		rec.Type = GameCreated
+		rec.GameName = evt.Name
+	case event.GameFinishedEvent:
+		rec.Type = GameFinished
+		rec.Reason = evt.Reason
+	case event.RunStartedEvent:
+		rec.Type = RunStarted
+		rec.RunName = evt.RunName
+	case event.RunFinishedEvent:
+		rec.Type = RunFinished
+		rec.RunName = evt.RunName
+		rec.Reason = evt.Reason
+	case event.UsedPotionEvent:
+		rec.Type = PotionUsed
+		rec.PotionType = evt.PotionType
+		rec.OnMerc = evt.OnMerc
+	case event.ItemStashedEvent:
+		rec.Type = ItemStashed
+		drop := evt.Item
+		rec.Drop = &drop
+	case event.ItemDecisionEvent:
+		// Items ignored or kept are evaluated again in every game, only the decisions moving an item are kept
+		if !slices.Contains(persistedDecisions, evt.Decision.Action) {
+			return Record{}, false
+		}
+		rec.Type = ItemDecision
+		decision := evt.Decision
+		rec.Decision = &decision
+	default:
+		return Record{}, false
+	}
+
+	return rec, true
+}
+
+// Append persists the record in the file matching the day it occurred.
+func (s *Store) Append(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating history directory %s: %w", s.dir, err)
+	}
+
+	file := filepath.Join(s.dir, filePrefix+rec.Time.Format(dayLayout)+fileSuffix)
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening history file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	enc, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error encoding history record: %w", err)
+	}
+	if _, err = f.Write(append(enc, '\n')); err != nil {
+		return fmt.Errorf("error writing history record: %w", err)
+	}
+
+	if today := time.Now().Format(dayLayout); s.retentionDays > 0 && s.prunedAt != today {
+		s.prunedAt = today
+		return s.prune(time.Now())
+	}
+
+	return nil
+}
+
+// prune deletes the daily files older than the retention, s.mu must be held
+func (s *Store) prune(now time.Time) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return err
+	}
+
+	oldest := now.AddDate(0, 0, -s.retentionDays).Format(dayLayout)
+	for _, file := range files {
+		// Day names sort like the dates they are
+		if fileDay(file) < oldest {
+			if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error deleting old history file %s: %w", file, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func fileDay(file string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), filePrefix), fileSuffix)
+}
+
+// Query returns the records matching q sorted by time ascending. When q.Limit is set, only the newest q.Limit records are returned.
+// It doesn't lock the store, so appending is never delayed by a query: files are only appended to, a line still being
+// written is skipped, and a file deleted by the pruning is skipped too.
+func (s *Store) Query(q Query) ([]Record, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	out := make([]Record, 0)
+	for _, file := range files {
+		if !q.includesDay(fileDay(file)) {
+			continue
+		}
+
+		records, err := readFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			if q.matches(rec) {
+				out = append(out, rec)
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+
+	return out, nil
+}
+
+func readFile(file string) ([]Record, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening history file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	var records []Record
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec Record
+		// Skip partially written lines instead of failing the whole query
+		if err := json.Unmarshal([]byte(line), &rec); err == nil {
+			records = append(records, rec)
+		}
+	}
+
+	return records, sc.Err()
+}
+
+// includesDay discards whole files outside the queried date range without parsing them
+func (q Query) includesDay(day string) bool {
+	d, err := time.ParseInLocation(dayLayout, day, time.Local)
+	if err != nil {
+		return false
+	}
+	if !q.From.IsZero() && d.AddDate(0, 0, 1).Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && d.After(q.To) {
+		return false
+	}
+
+	return true
+}
+
+func (q Query) matches(rec Record) bool {
+	if q.Supervisor != "" && !strings.EqualFold(q.Supervisor, rec.Supervisor) {
+		return false
+	}
+	if q.RunName != "" && !strings.EqualFold(q.RunName, rec.RunName) {
+		return false
+	}
+	if q.Reason != "" && q.Reason != rec.Reason {
+		return false
+	}
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			if t == rec.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.From.IsZero() && rec.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && rec.Time.After(q.To) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/event"
+)
+
+func TestFromEventItemDecisions(t *testing.T) {
+	for action, persisted := range map[event.DecisionAction]bool{
+		event.ActionPickup: true,
+		event.ActionStash:  true,
+		event.ActionSell:   true,
+		event.ActionDrop:   true,
+		event.ActionIgnore: false,
+		event.ActionKeep:   false,
+	} {
+		rec, ok := FromEvent(event.ItemDecided(event.Text("char", string(action)), event.ItemDecision{Action: action}))
+		if ok != persisted {
+			t.Errorf("%s decision: expected persisted %v, got %v", action, persisted, ok)
+		}
+		if ok && (rec.Type != ItemDecision || rec.Decision == nil || rec.Decision.Action != action) {
+			t.Errorf("%s decision: unexpected record %+v", action, rec)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := NewStore(t.TempDir(), 0)
+	day := time.Date(2026, 10, 10, 12, 0, 0, 0, time.Local)
+	records := []Record{
+		{Time: day, Supervisor: "sorc", Type: GameCreated, GameName: "game-1"},
+		{Time: day.Add(time.Minute), Supervisor: "sorc", Type: RunFinished, RunName: "pindleskin", Reason: event.FinishedOK},
+		{Time: day.Add(2 * time.Minute), Supervisor: "sorc", Type: RunFinished, RunName: "mephisto", Reason: event.FinishedChicken},
+		{Time: day.Add(3 * time.Minute), Supervisor: "pala", Type: RunFinished, RunName: "pindleskin", Reason: event.FinishedOK},
+		{Time: day.AddDate(0, 0, 1), Supervisor: "sorc", Type: RunFinished, RunName: "pindleskin", Reason: event.FinishedOK},
+		{Time: day.AddDate(0, 0, 2), Supervisor: "sorc", Type: PotionUsed},
+	}
+	// Appended out of order, results are sorted by time
+	for i := len(records) - 1; i >= 0; i-- {
+		if err := s.Append(records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []int
+	}{
+		{name: "all", query: Query{}, expected: []int{0, 1, 2, 3, 4, 5}},
+		{name: "supervisor", query: Query{Supervisor: "PALA"}, expected: []int{3}},
+		{name: "run name", query: Query{RunName: "pindleskin"}, expected: []int{1, 3, 4}},
+		{name: "reason", query: Query{Reason: event.FinishedChicken}, expected: []int{2}},
+		{name: "types", query: Query{Types: []RecordType{GameCreated, PotionUsed}}, expected: []int{0, 5}},
+		{name: "from", query: Query{From: day.Add(90 * time.Second)}, expected: []int{2, 3, 4, 5}},
+		{name: "to", query: Query{To: day.Add(time.Minute)}, expected: []int{0, 1}},
+		{name: "range", query: Query{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 1)}, expected: []int{4}},
+		{name: "limit keeps the newest", query: Query{Supervisor: "sorc", Limit: 2}, expected: []int{4, 5}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.Query(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %d records, got %d: %+v", len(tc.expected), len(got), got)
+			}
+			for i, idx := range tc.expected {
+				if !got[i].Time.Equal(records[idx].Time) || got[i].Type != records[idx].Type || got[i].Supervisor != records[idx].Supervisor {
+					t.Errorf("record %d: expected %+v, got %+v", i, records[idx], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuerySkipsPartialLines(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, 0)
+	now := time.Now()
+	if err := s.Append(Record{Time: now, Supervisor: "sorc", Type: GameCreated}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, filePrefix+now.Format(dayLayout)+fileSuffix)
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2026-10-10T12:00:00Z","supervi`)
+	f.Close()
+
+	got, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("expected the partial line to be skipped, got %+v", got)
+	}
+}
+
+func TestIncludesDay(t *testing.T) {
+	from := time.Date(2026, 10, 10, 18, 0, 0, 0, time.Local)
+	to := time.Date(2026, 10, 12, 6, 0, 0, 0, time.Local)
+	q := Query{From: from, To: to}
+
+	for day, expected := range map[string]bool{
+		"2026-10-09": false,
+		"2026-10-10": true, // Starts before From, but has records after it
+		"2026-10-11": true,
+		"2026-10-12": true,
+		"2026-10-13": false,
+		"not-a-day":  false,
+	} {
+		if got := q.includesDay(day); got != expected {
+			t.Errorf("includesDay(%s) = %v, expected %v", day, got, expected)
+		}
+	}
+
+	if !(Query{}).includesDay("2000-01-01") {
+		t.Error("every day is included without a range")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, 30)
+	now := time.Now()
+	for _, days := range []int{40, 31, 29, 1} {
+		file := filepath.Join(dir, filePrefix+now.AddDate(0, 0, -days).Format(dayLayout)+fileSuffix)
+		if err := os.WriteFile(file, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first append of the day prunes the old files
+	if err := s.Append(Record{Time: now, Supervisor: "sorc", Type: GameCreated}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if len(files) != 3 {
+		t.Fatalf("expected the files of the last 30 days, got %v", files)
+	}
+	for _, file := range files {
+		if fileDay(file) < now.AddDate(0, 0, -30).Format(dayLayout) {
+			t.Errorf("%s should be deleted", file)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hectorgimenez/koolo/internal/event"
+)
+
+// ContentType is the Prometheus text exposition format served by the /metrics endpoint
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector keeps the counters derived from the event bus, it must be registered on the event.Listener
+type Collector struct {
+	mu           sync.Mutex
+	listener     *event.Listener
+	gamesStarted *counter
+	runsFinished *counter
+	potionsUsed  *counter
+	itemsStashed *counter
+}
+
+func NewCollector(listener *event.Listener) *Collector {
+	return &Collector{
+		listener:     listener,
+		gamesStarted: newCounter("koolo_games_started_total", "Games created by the supervisor.", "supervisor"),
+		runsFinished: newCounter("koolo_runs_finished_total", "Runs finished, by run name and finish reason.", "supervisor", "run", "reason"),
+		potionsUsed:  newCounter("koolo_potions_used_total", "Potions drunk, by potion type and target.", "supervisor", "type", "target"),
+		itemsStashed: newCounter("koolo_items_stashed_total", "Items stashed, by item quality.", "supervisor", "quality"),
+	}
+}
+
+func (c *Collector) Handle(_ context.Context, e event.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch evt := e.(type) {
+	case event.GameCreatedEvent:
+		c.gamesStarted.inc(evt.Supervisor())
+	case event.RunFinishedEvent:
+		c.runsFinished.inc(evt.Supervisor(), evt.RunName, string(evt.Reason))
+	case event.UsedPotionEvent:
+		target := "player"
+		if evt.OnMerc {
+			target = "merc"
+		}
+		c.potionsUsed.inc(evt.Supervisor(), string(evt.PotionType), target)
+	case event.ItemStashedEvent:
+		c.itemsStashed.inc(evt.Supervisor(), evt.Item.Item.Quality.ToString())
+	}
+
+	return nil
+}
+
+// Write writes all the counters using the text exposition format
+func (c *Collector) Write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cnt := range []*counter{c.gamesStarted, c.runsFinished, c.potionsUsed, c.itemsStashed} {
+		if err := cnt.write(w); err != nil {
+			return err
+		}
+	}
+
+	busDropped := NewFamily("koolo_event_bus_dropped_total", "Events dropped before reaching any handler because the event buffer was full.", "counter")
+	busDropped.Add(float64(event.DroppedEvents()), "")
+	if err := busDropped.Write(w); err != nil {
+		return err
+	}
+
+	return c.writeHandlerStats(w)
+}
+
+// writeHandlerStats exposes the queue and latency metrics of every event bus handler
+func (c *Collector) writeHandlerStats(w io.Writer) error {
+	if c.listener == nil {
+		return nil
+	}
+
+	processed := NewFamily("koolo_event_handler_processed_total", "Events processed by the handler.", "counter")
+	dropped := NewFamily("koolo_event_handler_dropped_total", "Events dropped because the handler queue was full.", "counter")
+	errors := NewFamily("koolo_event_handler_errors_total", "Events the handler returned an error for.", "counter")
+	queued := NewFamily("koolo_event_handler_queue_length", "Events waiting in the handler in-memory queue.", "gauge")
+	spilled := NewFamily("koolo_event_handler_spilled_events", "Events waiting in the handler disk queue.", "gauge")
+	avgLatency := NewFamily("koolo_event_handler_latency_avg_seconds", "Average time spent by the handler per event.", "gauge")
+	maxLatency := NewFamily("koolo_event_handler_latency_max_seconds", "Maximum time spent by the handler on a single event.", "gauge")
+	avgWait := NewFamily("koolo_event_handler_wait_avg_seconds", "Average time events waited in the queue before being handled.", "gauge")
+
+	for _, st := range c.listener.HandlerStats() {
+		labels := Labels([]string{"handler", "overflow"}, []string{st.Name, string(st.Overflow)})
+		processed.Add(float64(st.Processed), labels)
+		dropped.Add(float64(st.Dropped), labels)
+		errors.Add(float64(st.Errors), labels)
+		queued.Add(float64(st.Queued), labels)
+		spilled.Add(float64(st.Spilled), labels)
+		avgLatency.Add(st.AvgLatency.Seconds(), labels)
+		maxLatency.Add(st.MaxLatency.Seconds(), labels)
+		avgWait.Add(st.AvgWait.Seconds(), labels)
+	}
+
+	for _, f := range []*Family{processed, dropped, errors, queued, spilled, avgLatency, maxLatency, avgWait} {
+		if err := f.Write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type counter struct {
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+	series map[string][]string
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+		series: make(map[string][]string),
+	}
+}
+
+func (c *counter) inc(labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	if _, found := c.series[key]; !found {
+		c.series[key] = labelValues
+	}
+	c.values[key]++
+}
+
+func (c *counter) write(w io.Writer) error {
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	family := NewFamily(c.name, c.help, "counter")
+	for _, k := range keys {
+		family.Add(c.values[k], Labels(c.labels, c.series[k]))
+	}
+
+	return family.Write(w)
+}
+
+// Family is a single metric family with its samples, used for gauges computed at scrape time
+type Family struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+func NewFamily(name, help, typ string) *Family {
+	return &Family{name: name, help: help, typ: typ}
+}
+
+// Add appends a sample, labels must be built with Labels
+func (f *Family) Add(value float64, labels string) {
+	f.samples = append(f.samples, f.name+labels+" "+strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (f *Family) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ); err != nil {
+		return err
+	}
+	for _, s := range f.samples {
+		if _, err := io.WriteString(w, s+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Labels renders the label set, names and values are matched by position
+func Labels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+`="`+escapeLabel(value)+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -69,7 +69,7 @@ func CalculatePath(g *game.Grid, start, goal data.Position) ([]data.Position, in
 		updateNeighbors(g, current, &neighbors)
 
 		for _, neighbor := range neighbors {
-			newCost := costSoFar[current.X][current.Y] + getCost(g.CollisionGrid[neighbor.Y][neighbor.X])
+			newCost := costSoFar[current.X][current.Y] + tileCost(g, neighbor)
 
 			// Handicap for changing direction, this prevents zig-zagging around obstacles
 			//curDirX, curDirY := direction(cameFrom[current.X][current.Y], current.Position)
@@ -139,6 +139,16 @@ func getCost(tileType game.CollisionType) int {
 	}
 }
 
+// tileCost is the cost of moving into p, including the danger around it
+func tileCost(g *game.Grid, p data.Position) int {
+	cost := getCost(g.CollisionGrid[p.Y][p.X])
+	if cost == math.MaxInt32 {
+		return cost
+	}
+
+	return cost + g.Danger(p)
+}
+
 func heuristic(a, b data.Position) int {
 	dx := math.Abs(float64(a.X - b.X))
 	dy := math.Abs(float64(a.Y - b.Y))
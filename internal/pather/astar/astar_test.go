@@ -21,6 +21,61 @@ func BenchmarkAstar(b *testing.B) {
 	}
 }
 
+// BenchmarkAstarReplanning compares planning from scratch with the incremental planner in the situations found while
+// moving: the player advances along the path and monsters move around it
+func BenchmarkAstarReplanning(b *testing.B) {
+	start := data.Position{X: 336, Y: 701}
+	goal := data.Position{X: 11, Y: 330}
+	path, _, _ := CalculatePath(loadGrid(), start, goal)
+
+	// Every iteration advances one tile and moves a few monsters placed on the path ahead of the player
+	step := func(grid *game.Grid, i int) (data.Position, []data.Position) {
+		from := path[i%(len(path)/2)]
+		changed := make([]data.Position, 0, 6)
+		for _, offset := range []int{40, 120, 200} {
+			previous := path[(i+offset-1)%len(path)]
+			next := path[(i+offset)%len(path)]
+			grid.CollisionGrid[previous.Y][previous.X] = game.CollisionTypeWalkable
+			grid.CollisionGrid[next.Y][next.X] = game.CollisionTypeMonster
+			changed = append(changed, previous, next)
+		}
+		return from, changed
+	}
+
+	b.Run("scratch", func(b *testing.B) {
+		grid := loadGrid()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			from, _ := step(grid, i)
+			// Like PathFinder did, the grid with the monsters was copied before every search
+			CalculatePath(grid.Copy(), from, goal)
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		grid := loadGrid()
+		planner := NewDStarLite(grid, start, goal)
+		planner.Path()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			from, changed := step(grid, i)
+			for _, p := range changed {
+				planner.UpdateCell(p)
+			}
+			planner.MoveStart(from)
+			planner.Path()
+		}
+	})
+
+	b.Run("incremental first search", func(b *testing.B) {
+		grid := loadGrid()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			NewDStarLite(grid, start, goal).Path()
+		}
+	})
+}
+
 func TestAstar(t *testing.T) {
 	grid := loadGrid()
 
@@ -39,6 +94,125 @@ func TestAstar(t *testing.T) {
 	}
 }
 
+func TestTeleportPath(t *testing.T) {
+	grid := loadGrid()
+
+	start := data.Position{X: 336, Y: 701}
+	goal := data.Position{X: 11, Y: 330}
+
+	p, _, found := CalculateTeleportPath(grid, start, goal, 25, nil)
+	if !found {
+		t.Fatalf("Expected teleport path to be found")
+	}
+	if p[0] != start || p[len(p)-1] != goal {
+		t.Errorf("Expected path from %v to %v, got from %v to %v", start, goal, p[0], p[len(p)-1])
+	}
+	if len(p) > 30 {
+		t.Errorf("Expected at most 30 landings, got %d", len(p))
+	}
+	for i := 1; i < len(p); i++ {
+		if hop := euclidean(p[i-1], p[i]); hop > 26 {
+			t.Errorf("Hop from %v to %v is %.1f tiles long", p[i-1], p[i], hop)
+		}
+		if grid.CollisionGrid[p[i].Y][p[i].X] == game.CollisionTypeNonWalkable {
+			t.Errorf("Landing %v is not walkable", p[i])
+		}
+	}
+}
+
+func TestDStarLite(t *testing.T) {
+	grid := loadGrid()
+
+	start := data.Position{X: 336, Y: 701}
+	goal := data.Position{X: 11, Y: 330}
+
+	planner := NewDStarLite(grid, start, goal)
+	expected, _, _ := CalculatePath(grid, start, goal)
+	p, dist, found := planner.Path()
+	if !found {
+		t.Fatalf("Expected path to be found")
+	}
+	if pathCost(grid, p) != pathCost(grid, expected) || dist != len(p) {
+		t.Errorf("Expected path cost %d, got %d", pathCost(grid, expected), pathCost(grid, p))
+	}
+
+	// Block the path ahead and move the start, the repaired path has to be as good as a new one
+	for _, blocked := range []data.Position{expected[100], expected[101], expected[300]} {
+		grid.CollisionGrid[blocked.Y][blocked.X] = game.CollisionTypeNonWalkable
+		planner.UpdateCell(blocked)
+	}
+	start = expected[20]
+	planner.MoveStart(start)
+
+	expected, _, _ = CalculatePath(grid, start, goal)
+	p, _, found = planner.Path()
+	if !found {
+		t.Fatalf("Expected repaired path to be found")
+	}
+	if p[0] != start || p[len(p)-1] != goal {
+		t.Errorf("Expected path from %v to %v, got from %v to %v", start, goal, p[0], p[len(p)-1])
+	}
+	if pathCost(grid, p) != pathCost(grid, expected) {
+		t.Errorf("Expected repaired path cost %d, got %d", pathCost(grid, expected), pathCost(grid, p))
+	}
+}
+
+func TestDangerAvoidance(t *testing.T) {
+	cg := make([][]game.CollisionType, 40)
+	for y := range cg {
+		cg[y] = make([]game.CollisionType, 40)
+		for x := range cg[y] {
+			cg[y][x] = game.CollisionTypeWalkable
+		}
+	}
+	grid := &game.Grid{Width: 40, Height: 40, CollisionGrid: cg}
+
+	start := data.Position{X: 2, Y: 20}
+	goal := data.Position{X: 37, Y: 20}
+
+	// A pack in the middle of the straight line
+	for y := 14; y <= 26; y++ {
+		for x := 15; x <= 25; x++ {
+			grid.SetDanger(data.Position{X: x, Y: y}, 50)
+		}
+	}
+
+	p, _, found := CalculatePath(grid, start, goal)
+	if !found {
+		t.Fatalf("Expected path to be found")
+	}
+	for _, pos := range p {
+		if grid.Danger(pos) > 0 {
+			t.Fatalf("Expected path to go around the danger, it goes through %v", pos)
+		}
+	}
+
+	planner := NewDStarLite(grid, start, goal)
+	if _, _, found := planner.Path(); !found {
+		t.Fatalf("Expected D* Lite path to be found")
+	}
+	// The danger is gone, the straight line is the cheapest again
+	for y := 14; y <= 26; y++ {
+		for x := 15; x <= 25; x++ {
+			grid.SetDanger(data.Position{X: x, Y: y}, 0)
+			planner.UpdateCell(data.Position{X: x, Y: y})
+		}
+	}
+	p, _, _ = planner.Path()
+	if len(p) != goal.X-start.X+1 {
+		t.Errorf("Expected straight path of %d tiles after clearing the danger, got %d", goal.X-start.X+1, len(p))
+	}
+}
+
+func pathCost(grid *game.Grid, path []data.Position) int {
+	cost := 0
+	for _, p := range path[1:] {
+		cost += getCost(grid.CollisionGrid[p.Y][p.X])
+	}
+
+	return cost
+}
+
 func loadGrid() *game.Grid {
 	var grid game.Grid
 	file, err := os.Open("durance_of_hate_grid.bin")
@@ -0,0 +1,300 @@
+package astar
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/game"
+)
+
+const dStarInfinity = math.MaxInt32 / 2
+
+// DStarLite is an incremental planner (D* Lite), it searches from the goal to the start so the search can be repaired
+// when the start moves or some cells change, instead of planning again from scratch like CalculatePath. It's meant to
+// be reused while the goal is the same.
+type DStarLite struct {
+	grid      *game.Grid
+	start     data.Position
+	last      data.Position
+	goal      data.Position
+	km        int
+	g         []int
+	rhs       []int
+	open      []bool
+	keys      []dStarKey
+	queue     dStarQueue
+	neighbors []data.Position
+	preds     []data.Position
+}
+
+type dStarKey [2]int
+
+func (k dStarKey) less(other dStarKey) bool {
+	return k[0] < other[0] || (k[0] == other[0] && k[1] < other[1])
+}
+
+type dStarEntry struct {
+	index int
+	key   dStarKey
+}
+
+// NewDStarLite creates the planner for grid, the grid is not copied: call UpdateCell after changing any of its cells
+func NewDStarLite(grid *game.Grid, start, goal data.Position) *DStarLite {
+	size := grid.Width * grid.Height
+	d := &DStarLite{
+		grid:      grid,
+		start:     start,
+		last:      start,
+		goal:      goal,
+		g:         make([]int, size),
+		rhs:       make([]int, size),
+		open:      make([]bool, size),
+		keys:      make([]dStarKey, size),
+		queue:     make(dStarQueue, 0),
+		neighbors: make([]data.Position, 0, 8),
+		preds:     make([]data.Position, 0, 9),
+	}
+	for i := range d.g {
+		d.g[i] = dStarInfinity
+		d.rhs[i] = dStarInfinity
+	}
+	d.rhs[d.index(goal)] = 0
+	d.push(goal)
+
+	return d
+}
+
+func (d *DStarLite) Goal() data.Position {
+	return d.goal
+}
+
+// MoveStart moves the start of the path, usually to the current player position
+func (d *DStarLite) MoveStart(start data.Position) {
+	if start == d.start {
+		return
+	}
+	d.km += chebyshev(d.last, start)
+	d.last = start
+	d.start = start
+}
+
+// UpdateCell has to be called after changing the collision type or the danger of the cell at p
+func (d *DStarLite) UpdateCell(p data.Position) {
+	for y := p.Y - 1; y <= p.Y+1; y++ {
+		for x := p.X - 1; x <= p.X+1; x++ {
+			s := data.Position{X: x, Y: y}
+			if !d.inside(s) || s == d.goal {
+				continue
+			}
+			d.rhs[d.index(s)] = d.minSuccessor(s)
+			d.updateVertex(s)
+		}
+	}
+}
+
+// Path repairs the search and returns the path from the start to the goal, with the same format as CalculatePath
+func (d *DStarLite) Path() ([]data.Position, int, bool) {
+	if !d.inside(d.start) || !d.inside(d.goal) {
+		return nil, 0, false
+	}
+
+	// The start can be left overconsistent, its rhs is the cost of the path
+	d.computeShortestPath()
+	if d.rhs[d.index(d.start)] >= dStarInfinity {
+		return nil, 0, false
+	}
+
+	path := []data.Position{d.start}
+	for p := d.start; p != d.goal; {
+		// Follow the cheapest successor, a path can't be longer than the number of cells
+		if len(path) > len(d.g) {
+			return nil, 0, false
+		}
+
+		best, bestCost := p, dStarInfinity
+		updateNeighbors(d.grid, &Node{Position: p}, &d.neighbors)
+		for _, s := range d.neighbors {
+			if cost := d.cost(s) + d.g[d.index(s)]; cost < bestCost {
+				best, bestCost = s, cost
+			}
+		}
+		if bestCost >= dStarInfinity {
+			return nil, 0, false
+		}
+
+		p = best
+		path = append(path, p)
+	}
+
+	return path, len(path), true
+}
+
+func (d *DStarLite) computeShortestPath() {
+	startIndex := d.index(d.start)
+	for {
+		e, found := d.top()
+		if !found {
+			return
+		}
+		if !e.key.less(d.calculateKey(d.start)) && d.rhs[startIndex] <= d.g[startIndex] {
+			return
+		}
+
+		heap.Pop(&d.queue)
+		d.open[e.index] = false
+		u := d.position(e.index)
+		if newKey := d.calculateKey(u); e.key.less(newKey) {
+			d.push(u)
+			continue
+		}
+
+		if d.g[e.index] > d.rhs[e.index] {
+			d.g[e.index] = d.rhs[e.index]
+			for _, s := range d.predecessors(u) {
+				if s == d.goal {
+					continue
+				}
+				si := d.index(s)
+				d.rhs[si] = min(d.rhs[si], d.cost(u)+d.g[e.index])
+				d.updateVertex(s)
+			}
+			continue
+		}
+
+		oldG := d.g[e.index]
+		d.g[e.index] = dStarInfinity
+		for _, s := range append(d.predecessors(u), u) {
+			if s == d.goal {
+				continue
+			}
+			si := d.index(s)
+			if s == u || d.rhs[si] == d.cost(u)+oldG {
+				d.rhs[si] = d.minSuccessor(s)
+			}
+			d.updateVertex(s)
+		}
+	}
+}
+
+// predecessors are the cells with u as a walkable neighbor, corners are checked the same way in both directions. The
+// returned slice is reused by the next call.
+func (d *DStarLite) predecessors(u data.Position) []data.Position {
+	preds := d.preds[:0]
+	if d.grid.CollisionGrid[u.Y][u.X] == game.CollisionTypeNonWalkable {
+		return preds
+	}
+
+	for _, dir := range directions {
+		s := data.Position{X: u.X - dir.X, Y: u.Y - dir.Y}
+		if !d.inside(s) {
+			continue
+		}
+		if dir.X != 0 && dir.Y != 0 && (d.blocked(data.Position{X: u.X, Y: s.Y}) || d.blocked(data.Position{X: s.X, Y: u.Y})) {
+			continue
+		}
+		preds = append(preds, s)
+	}
+
+	return preds
+}
+
+func (d *DStarLite) minSuccessor(s data.Position) int {
+	best := dStarInfinity
+	updateNeighbors(d.grid, &Node{Position: s}, &d.neighbors)
+	for _, n := range d.neighbors {
+		best = min(best, d.cost(n)+d.g[d.index(n)])
+	}
+
+	return best
+}
+
+func (d *DStarLite) updateVertex(p data.Position) {
+	i := d.index(p)
+	if d.g[i] != d.rhs[i] {
+		d.push(p)
+		return
+	}
+	d.open[i] = false
+}
+
+// push adds p to the queue, the previous entry of p becomes stale and is skipped when it reaches the top
+func (d *DStarLite) push(p data.Position) {
+	i := d.index(p)
+	key := d.calculateKey(p)
+	if d.open[i] && d.keys[i] == key {
+		return
+	}
+	d.keys[i] = key
+	d.open[i] = true
+	heap.Push(&d.queue, dStarEntry{index: i, key: d.keys[i]})
+}
+
+func (d *DStarLite) top() (dStarEntry, bool) {
+	for d.queue.Len() > 0 {
+		e := d.queue[0]
+		if d.open[e.index] && d.keys[e.index] == e.key {
+			return e, true
+		}
+		heap.Pop(&d.queue)
+	}
+
+	return dStarEntry{}, false
+}
+
+func (d *DStarLite) calculateKey(p data.Position) dStarKey {
+	i := d.index(p)
+	m := min(d.g[i], d.rhs[i])
+
+	return dStarKey{m + chebyshev(d.start, p) + d.km, m}
+}
+
+// cost of moving into p, same costs as CalculatePath
+func (d *DStarLite) cost(p data.Position) int {
+	return min(tileCost(d.grid, p), dStarInfinity)
+}
+
+func (d *DStarLite) blocked(p data.Position) bool {
+	return !d.inside(p) || d.grid.CollisionGrid[p.Y][p.X] == game.CollisionTypeNonWalkable
+}
+
+func (d *DStarLite) inside(p data.Position) bool {
+	return p.X >= 0 && p.X < d.grid.Width && p.Y >= 0 && p.Y < d.grid.Height
+}
+
+func (d *DStarLite) index(p data.Position) int {
+	return p.Y*d.grid.Width + p.X
+}
+
+func (d *DStarLite) position(i int) data.Position {
+	return data.Position{X: i % d.grid.Width, Y: i / d.grid.Width}
+}
+
+// chebyshev is the heuristic of the planner, diagonal moves cost the same as straight ones
+func chebyshev(a, b data.Position) int {
+	return max(abs(a.X-b.X), abs(a.Y-b.Y))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+type dStarQueue []dStarEntry
+
+func (q dStarQueue) Len() int           { return len(q) }
+func (q dStarQueue) Less(i, j int) bool { return q[i].key.less(q[j].key) }
+func (q dStarQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *dStarQueue) Push(x interface{}) {
+	*q = append(*q, x.(dStarEntry))
+}
+func (q *dStarQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	*q = old[0 : n-1]
+	return e
+}
@@ -0,0 +1,146 @@
+package astar
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/game"
+)
+
+const (
+	hopCost = 100 // Cost of a single teleport, way higher than the distance so the hop count is minimized first
+	// Landing close to monsters is penalized per monster tile found within monsterRadius of the landing tile
+	monsterLandingCost = 40
+	monsterRadius      = 4
+	// Overestimating the heuristic a bit makes the search way faster, at the price of an extra hop on long paths
+	hopHeuristicWeight = 1.2
+)
+
+// hopRings are the landing candidates around the current position, as a fraction of the max hop radius and the number
+// of angles sampled, inner rings help to find landings in narrow corridors
+var hopRings = []struct {
+	fraction float64
+	angles   int
+}{
+	{1, 32},
+	{0.75, 24},
+	{0.5, 16},
+	{0.25, 8},
+}
+
+// CalculateTeleportPath plans a path made of teleport landings instead of walkable tiles, every hop is at most maxHop
+// tiles long and walls between landings are ignored, only the landing tile must be walkable. When reachable is set, it
+// filters the hops that can't be done, like the ones landing out of the screen. The path starts with start and the
+// returned distance is the sum of the hop lengths.
+func CalculateTeleportPath(g *game.Grid, start, goal data.Position, maxHop int, reachable func(from, to data.Position) bool) ([]data.Position, int, bool) {
+	if maxHop <= 0 || !isLanding(g, goal) {
+		return nil, 0, false
+	}
+
+	pq := make(PriorityQueue, 0)
+	heap.Init(&pq)
+
+	costSoFar := make([][]int, g.Width)
+	cameFrom := make([][]data.Position, g.Width)
+	for i := range costSoFar {
+		costSoFar[i] = make([]int, g.Height)
+		cameFrom[i] = make([]data.Position, g.Height)
+		for j := range costSoFar[i] {
+			costSoFar[i][j] = math.MaxInt32
+		}
+	}
+
+	heap.Push(&pq, &Node{Position: start, Cost: 0, Priority: hopHeuristic(start, goal, maxHop)})
+	costSoFar[start.X][start.Y] = 0
+
+	landings := make([]data.Position, 0, 81)
+
+	for pq.Len() > 0 {
+		current := heap.Pop(&pq).(*Node)
+		if current.Cost > costSoFar[current.X][current.Y] {
+			continue
+		}
+
+		if current.Position == goal {
+			path := []data.Position{goal}
+			distance := 0.0
+			for p := goal; p != start; p = cameFrom[p.X][p.Y] {
+				distance += euclidean(p, cameFrom[p.X][p.Y])
+				path = append(path, cameFrom[p.X][p.Y])
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+
+			return path, int(math.Round(distance)), true
+		}
+
+		updateLandings(g, current.Position, goal, maxHop, reachable, &landings)
+
+		for _, landing := range landings {
+			hop := euclidean(current.Position, landing)
+			newCost := current.Cost + hopCost + int(math.Ceil(hop)) + monstersAround(g, landing)*monsterLandingCost
+			if newCost < costSoFar[landing.X][landing.Y] {
+				costSoFar[landing.X][landing.Y] = newCost
+				cameFrom[landing.X][landing.Y] = current.Position
+				heap.Push(&pq, &Node{Position: landing, Cost: newCost, Priority: newCost + int(hopHeuristicWeight*float64(hopHeuristic(landing, goal, maxHop)))})
+			}
+		}
+	}
+
+	return nil, 0, false
+}
+
+// updateLandings samples the walkable tiles reachable with a single teleport from p, the goal is always a candidate
+// when it's in range
+func updateLandings(g *game.Grid, p, goal data.Position, maxHop int, reachable func(from, to data.Position) bool, landings *[]data.Position) {
+	*landings = (*landings)[:0]
+
+	if euclidean(p, goal) <= float64(maxHop) && (reachable == nil || reachable(p, goal)) {
+		*landings = append(*landings, goal)
+	}
+
+	for _, ring := range hopRings {
+		radius := float64(maxHop) * ring.fraction
+		for i := 0; i < ring.angles; i++ {
+			angle := 2 * math.Pi * float64(i) / float64(ring.angles)
+			landing := data.Position{
+				X: p.X + int(math.Round(radius*math.Cos(angle))),
+				Y: p.Y + int(math.Round(radius*math.Sin(angle))),
+			}
+			if landing != p && isLanding(g, landing) && (reachable == nil || reachable(p, landing)) {
+				*landings = append(*landings, landing)
+			}
+		}
+	}
+}
+
+func isLanding(g *game.Grid, p data.Position) bool {
+	return p.X >= 0 && p.X < g.Width && p.Y >= 0 && p.Y < g.Height && g.CollisionGrid[p.Y][p.X] != game.CollisionTypeNonWalkable
+}
+
+func monstersAround(g *game.Grid, p data.Position) int {
+	monsters := 0
+	for y := max(p.Y-monsterRadius, 0); y <= min(p.Y+monsterRadius, g.Height-1); y++ {
+		for x := max(p.X-monsterRadius, 0); x <= min(p.X+monsterRadius, g.Width-1); x++ {
+			if g.CollisionGrid[y][x] == game.CollisionTypeMonster {
+				monsters++
+			}
+		}
+	}
+
+	return monsters
+}
+
+// hopHeuristic never overestimates: at least ceil(distance/maxHop) hops are needed, and they can't be shorter than the
+// straight line. Landings are rounded to tiles, so a hop can be slightly longer than maxHop.
+func hopHeuristic(a, b data.Position, maxHop int) int {
+	d := euclidean(a, b)
+
+	return int(math.Ceil(d/float64(maxHop+1)))*hopCost + int(d)
+}
+
+func euclidean(a, b data.Position) float64 {
+	return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+}
@@ -0,0 +1,165 @@
+package pather
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/npc"
+	"github.com/hectorgimenez/koolo/internal/game"
+)
+
+// threat is the danger radiating from a monster, the cost is the highest on the monster and decreases with the
+// distance until the radius
+type threat struct {
+	radius int
+	cost   int
+}
+
+var (
+	normalThreat = threat{radius: 3, cost: 2}
+	eliteThreat  = threat{radius: 5, cost: 4}
+	bossThreat   = threat{radius: 8, cost: 6}
+)
+
+// immuneThreatMultiplier applies to monsters immune to our damage, we can't kill them so it's better to not wake them up
+const immuneThreatMultiplier = 2
+
+// hazardLifetime is how long a hazard zone is kept after the monster is gone, souls fly in and out of sight and
+// dolls explode when they die
+const hazardLifetime = 5 * time.Second
+
+// monsterMoveThreshold is how far a monster moves, in tiles, before its obstacle and danger follow it. Monsters shuffle
+// around all the time, replanning on every step would throw away the cached paths for no real gain.
+const monsterMoveThreshold = 2
+
+var bosses = []npc.ID{npc.Andariel, npc.Duriel, npc.Mephisto, npc.Diablo, npc.BaalCrab, npc.Nihlathak, npc.Izual}
+
+// hazards are known nasty mechanics, their zones are avoided even some time after the monster is gone
+var hazards = map[npc.ID]threat{
+	// Souls lightning hits from far away
+	npc.BlackSoul:    {radius: 10, cost: 8},
+	npc.BlackSoul2:   {radius: 10, cost: 8},
+	npc.BurningSoul:  {radius: 10, cost: 8},
+	npc.BurningSoul2: {radius: 10, cost: 8},
+	npc.Gloam:        {radius: 10, cost: 8},
+	npc.Gloam2:       {radius: 10, cost: 8},
+	// Dolls explode when they die
+	npc.StygianDoll:        {radius: 4, cost: 10},
+	npc.StygianDoll2:       {radius: 4, cost: 10},
+	npc.StygianDoll3:       {radius: 4, cost: 10},
+	npc.StygianDoll4:       {radius: 4, cost: 10},
+	npc.UndeadStygianDoll:  {radius: 4, cost: 10},
+	npc.UndeadStygianDoll2: {radius: 4, cost: 10},
+	npc.UndeadSoulKiller:   {radius: 4, cost: 10},
+	npc.UndeadSoulKiller2:  {radius: 4, cost: 10},
+	// Iron Maiden and other curses
+	npc.OblivionKnight:  {radius: 8, cost: 6},
+	npc.OblivionKnight2: {radius: 8, cost: 6},
+	npc.OblivionKnight3: {radius: 8, cost: 6},
+}
+
+type hazardZone struct {
+	position data.Position
+	threat   threat
+	seen     time.Time
+}
+
+// dangerField returns the extra cost of walking close to monsters and hazard zones, by position relative to the grid
+func (pf *PathFinder) dangerField(grid *game.Grid, monsters data.Monsters) map[data.Position]uint16 {
+	field := make(map[data.Position]int)
+	add := func(center data.Position, t threat) {
+		center = grid.RelativePosition(center)
+		for y := max(center.Y-t.radius, 0); y <= min(center.Y+t.radius, grid.Height-1); y++ {
+			for x := max(center.X-t.radius, 0); x <= min(center.X+t.radius, grid.Width-1); x++ {
+				distance := max(abs(x-center.X), abs(y-center.Y))
+				field[data.Position{X: x, Y: y}] += t.cost * (t.radius - distance + 1)
+			}
+		}
+	}
+
+	for _, m := range monsters {
+		if m.IsPet() || m.IsMerc() || m.IsGoodNPC() || m.IsSkip() {
+			continue
+		}
+		if _, isHazard := hazards[m.Name]; isHazard {
+			continue
+		}
+		add(m.Position, pf.monsterThreat(m))
+	}
+
+	for _, z := range pf.updateHazards(monsters) {
+		add(z.position, z.threat)
+	}
+
+	danger := make(map[data.Position]uint16, len(field))
+	for p, cost := range field {
+		danger[p] = uint16(min(cost, math.MaxUint16))
+	}
+
+	return danger
+}
+
+func (pf *PathFinder) monsterThreat(m data.Monster) threat {
+	t := normalThreat
+	switch {
+	case m.Type == data.MonsterTypeSuperUnique || slices.Contains(bosses, m.Name):
+		t = bossThreat
+	case m.IsElite():
+		t = eliteThreat
+	}
+
+	if immunities := pf.cfg.Immunities(); len(immunities) > 0 {
+		immune := true
+		for _, resist := range immunities {
+			immune = immune && m.IsImmune(resist)
+		}
+		if immune {
+			t.cost *= immuneThreatMultiplier
+		}
+	}
+
+	return t
+}
+
+// updateHazards refreshes the hazard zones with the monsters in sight and drops the expired ones, the zone of a dead
+// monster is kept until it expires
+func (pf *PathFinder) updateHazards(monsters data.Monsters) map[data.UnitID]hazardZone {
+	if pf.hazards == nil || pf.hazardArea != pf.data.PlayerUnit.Area {
+		pf.hazards = make(map[data.UnitID]hazardZone)
+		pf.hazardArea = pf.data.PlayerUnit.Area
+	}
+
+	now := time.Now()
+	for _, m := range monsters {
+		if t, found := hazards[m.Name]; found {
+			pf.hazards[m.UnitID] = hazardZone{position: m.Position, threat: t, seen: now}
+		}
+	}
+
+	for id, z := range pf.hazards {
+		if now.Sub(z.seen) > hazardLifetime {
+			delete(pf.hazards, id)
+		}
+	}
+
+	return pf.hazards
+}
+
+// stableMonsters returns the monsters at the position last used on the plan, unless they moved farther than
+// monsterMoveThreshold from it
+func (p *plan) stableMonsters(monsters data.Monsters) data.Monsters {
+	stable := make(data.Monsters, 0, len(monsters))
+	positions := make(map[data.UnitID]data.Position, len(monsters))
+	for _, m := range monsters {
+		if last, found := p.monsters[m.UnitID]; found && max(abs(m.Position.X-last.X), abs(m.Position.Y-last.Y)) <= monsterMoveThreshold {
+			m.Position = last
+		}
+		positions[m.UnitID] = m.Position
+		stable = append(stable, m)
+	}
+	p.monsters = positions
+
+	return stable
+}
@@ -0,0 +1,193 @@
+package pather
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/game"
+)
+
+var updateGolden = flag.Bool("update", false, "write the golden files of the fixture tests with the current results")
+
+const (
+	maxFixtureObjects = 20
+	// Walkable positions sampled on every fixture, in a grid of fixtureSamples x fixtureSamples
+	fixtureSamples = 4
+)
+
+// fixtureResult is the golden output of a PathFinder operation, paths are stored as a hash to keep the files small
+type fixtureResult struct {
+	Operation   string
+	To          *data.Position `json:",omitempty"`
+	Found       bool           `json:",omitempty"`
+	Distance    int            `json:",omitempty"`
+	End         *data.Position `json:",omitempty"`
+	PathHash    string         `json:",omitempty"`
+	LineOfSight bool           `json:",omitempty"`
+	Rooms       []data.Room    `json:",omitempty"`
+}
+
+func (r fixtureResult) String() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// TestFixtures runs the PathFinder operations against every fixture in testdata, comparing them with the golden files.
+// Run it with -update to write the golden files after an intended change.
+func TestFixtures(t *testing.T) {
+	if config.Koolo == nil {
+		config.Koolo = &config.KooloCfg{}
+	}
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Skip("no fixtures found")
+	}
+
+	for _, file := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(file), ".json.gz")
+		t.Run(name, func(t *testing.T) {
+			results := runFixture(t, file)
+			goldenFile := filepath.Join("testdata", name+".golden.json")
+
+			if *updateGolden {
+				content, err := json.MarshalIndent(results, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(goldenFile, append(content, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			content, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("Missing golden file, run the test with -update to create it: %v", err)
+			}
+			var expected []fixtureResult
+			if err = json.Unmarshal(content, &expected); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(expected) != len(results) {
+				t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+			}
+			for i := range expected {
+				if expected[i].String() != results[i].String() {
+					t.Errorf("Result %d changed\nexpected: %s\ngot:      %s", i, expected[i], results[i])
+				}
+			}
+		})
+	}
+}
+
+func loadFixture(t *testing.T, file string) *game.Data {
+	t.Helper()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fixture, err := game.ReadAreaFixture(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := fixture.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func runFixture(t *testing.T, file string) []fixtureResult {
+	d := loadFixture(t, file)
+
+	// Every operation uses a new PathFinder, so the results don't depend on the paths cached by the previous ones
+	newPathFinder := func() *PathFinder {
+		return NewPathFinder(nil, d, nil, &config.CharacterCfg{})
+	}
+
+	results := make([]fixtureResult, 0)
+	for _, to := range fixtureTargets(d) {
+		path, distance, found := newPathFinder().GetPath(to)
+		results = append(results, pathResult("GetPath", to, path, distance, found))
+
+		path, distance, found = newPathFinder().GetClosestWalkablePath(to)
+		results = append(results, pathResult("GetClosestWalkablePath", to, path, distance, found))
+
+		results = append(results, fixtureResult{
+			Operation:   "LineOfSight",
+			To:          &to,
+			LineOfSight: newPathFinder().LineOfSight(d.PlayerUnit.Position, to),
+		})
+	}
+	results = append(results, fixtureResult{Operation: "OptimizeRoomsTraverseOrder", Rooms: newPathFinder().OptimizeRoomsTraverseOrder()})
+
+	return results
+}
+
+func pathResult(operation string, to data.Position, path Path, distance int, found bool) fixtureResult {
+	r := fixtureResult{Operation: operation, To: &to, Found: found, Distance: distance}
+	if len(path) > 0 {
+		h := fnv.New64a()
+		for _, p := range path {
+			fmt.Fprintf(h, "%d,%d;", p.X, p.Y)
+		}
+		r.End = &path[len(path)-1]
+		r.PathHash = fmt.Sprintf("%x", h.Sum64())
+	}
+
+	return r
+}
+
+// fixtureTargets are the destinations tested on every fixture: the adjacent levels, objects, rooms and some walkable
+// positions spread over the area
+func fixtureTargets(d *game.Data) []data.Position {
+	targets := make([]data.Position, 0)
+	for _, lvl := range d.AdjacentLevels {
+		targets = append(targets, lvl.Position)
+	}
+	for i, o := range d.Objects {
+		if i >= maxFixtureObjects {
+			break
+		}
+		targets = append(targets, o.Position)
+	}
+	for _, r := range d.Rooms {
+		targets = append(targets, r.GetCenter())
+	}
+
+	a := d.AreaData
+	for sy := 0; sy < fixtureSamples; sy++ {
+		for sx := 0; sx < fixtureSamples; sx++ {
+			// The first walkable cell of every sample cell
+			cx, cy := sx*a.Width/fixtureSamples, sy*a.Height/fixtureSamples
+		scan:
+			for y := cy; y < (sy+1)*a.Height/fixtureSamples; y++ {
+				for x := cx; x < (sx+1)*a.Width/fixtureSamples; x++ {
+					if a.CollisionGrid[y][x] == game.CollisionTypeWalkable {
+						targets = append(targets, data.Position{X: x + a.OffsetX, Y: y + a.OffsetY})
+						break scan
+					}
+				}
+			}
+		}
+	}
+
+	return targets
+}
@@ -0,0 +1,170 @@
+package pather
+
+import (
+	"maps"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/area"
+	"github.com/hectorgimenez/koolo/internal/game"
+	"github.com/hectorgimenez/koolo/internal/pather/astar"
+)
+
+const maxCachedPaths = 128
+
+// plan keeps the grid used for planning between calls, so it's not copied every time, together with the paths found
+// on it. The generation increases every time monsters, objects or the danger around them change, invalidating the
+// cached paths. Monsters moving a couple of tiles are ignored, see monsterMoveThreshold.
+type plan struct {
+	key        gridKey
+	base       *game.Grid
+	grid       *game.Grid
+	overlay    map[data.Position]game.CollisionType
+	danger     map[data.Position]uint16
+	monsters   map[data.UnitID]data.Position // Monster positions used for the overlay and the danger, see stableMonsters
+	generation int
+	paths      map[pathKey]cachedPath
+	// replanner is the incremental search for lastGoal, it's updated with the obstacle changes
+	replanner *astar.DStarLite
+	lastGoal  data.Position
+}
+
+// gridKey identifies the base grid, a new one is built when any of the fields changes
+type gridKey struct {
+	area     area.ID
+	grid     *game.Grid
+	merged   *game.Grid // Adjacent area grid merged with the current one, when the destination is outside
+	teleport bool
+	arcane   bool
+}
+
+type pathKey struct {
+	area       area.ID
+	from       data.Position
+	to         data.Position
+	generation int
+}
+
+type cachedPath struct {
+	path     Path
+	distance int
+	found    bool
+}
+
+// updatePlan returns the plan for the destination with the current obstacles on its grid, and from/to relative to
+// the grid
+func (pf *PathFinder) updatePlan(current **plan, from, to data.Position, teleportPlanner bool) (*plan, data.Position, data.Position, bool) {
+	a := pf.data.AreaData
+
+	// Lut Gholein map is a bit bugged, we should close this fake path to avoid pathing issues
+	if a.Area == area.LutGholein {
+		a.CollisionGrid[13][210] = game.CollisionTypeNonWalkable
+	}
+
+	key := gridKey{
+		area:     a.Area,
+		grid:     a.Grid,
+		teleport: teleportPlanner,
+		arcane:   pf.data.PlayerUnit.Area == area.ArcaneSanctuary && pf.data.CanTeleport(),
+	}
+	if !a.IsInside(to) {
+		merged, found := pf.adjacentGrid(to)
+		if !found {
+			return nil, data.Position{}, data.Position{}, false
+		}
+		key.merged = merged
+	}
+
+	p := *current
+	if p == nil || p.key != key {
+		base, err := pf.baseGrid(to, teleportPlanner)
+		if err != nil {
+			return nil, data.Position{}, data.Position{}, false
+		}
+		p = &plan{
+			key:     key,
+			base:    base,
+			grid:    base.Copy(),
+			overlay: make(map[data.Position]game.CollisionType),
+			danger:  make(map[data.Position]uint16),
+			paths:   make(map[pathKey]cachedPath),
+		}
+		*current = p
+	}
+
+	// Walking characters avoid the danger around monsters, teleport landings are already kept away from them
+	monsters := p.stableMonsters(pf.data.Monsters)
+	overlay, danger := pf.obstacles(p.base, monsters), make(map[data.Position]uint16)
+	if !teleportPlanner {
+		danger = pf.dangerField(p.base, monsters)
+	}
+	if !maps.Equal(overlay, p.overlay) || !maps.Equal(danger, p.danger) {
+		p.applyOverlay(overlay, danger)
+	}
+
+	return p, p.grid.RelativePosition(from), p.grid.RelativePosition(to), true
+}
+
+// applyOverlay replaces the obstacles and the danger on the grid, only the changed cells are updated in the
+// incremental search
+func (p *plan) applyOverlay(overlay map[data.Position]game.CollisionType, danger map[data.Position]uint16) {
+	changed := make([]data.Position, 0)
+	for pos := range p.overlay {
+		if _, found := overlay[pos]; !found {
+			p.grid.CollisionGrid[pos.Y][pos.X] = p.base.CollisionGrid[pos.Y][pos.X]
+			changed = append(changed, pos)
+		}
+	}
+	for pos, t := range overlay {
+		if p.grid.CollisionGrid[pos.Y][pos.X] != t {
+			p.grid.CollisionGrid[pos.Y][pos.X] = t
+			changed = append(changed, pos)
+		}
+	}
+
+	for pos := range p.danger {
+		if _, found := danger[pos]; !found {
+			p.grid.SetDanger(pos, 0)
+			changed = append(changed, pos)
+		}
+	}
+	for pos, cost := range danger {
+		if p.grid.Danger(pos) != int(cost) {
+			p.grid.SetDanger(pos, cost)
+			changed = append(changed, pos)
+		}
+	}
+
+	if p.replanner != nil {
+		for _, pos := range changed {
+			p.replanner.UpdateCell(pos)
+		}
+	}
+
+	p.overlay = overlay
+	p.danger = danger
+	p.generation++
+	clear(p.paths)
+}
+
+func (p *plan) pathKey(from, to data.Position) pathKey {
+	return pathKey{area: p.key.area, from: from, to: to, generation: p.generation}
+}
+
+func (p *plan) store(key pathKey, path Path, distance int, found bool) {
+	if len(p.paths) >= maxCachedPaths {
+		clear(p.paths)
+	}
+	p.paths[key] = cachedPath{path: path, distance: distance, found: found}
+}
+
+// adjacentGrid returns the grid of the adjacent area containing the position
+func (pf *PathFinder) adjacentGrid(to data.Position) (*game.Grid, bool) {
+	for _, a := range pf.data.AreaData.AdjacentLevels {
+		destination := pf.data.Areas[a.Area]
+		if destination.IsInside(to) {
+			return destination.Grid, true
+		}
+	}
+
+	return nil, false
+}
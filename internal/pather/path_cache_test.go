@@ -0,0 +1,42 @@
+package pather
+
+import (
+	"testing"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/npc"
+)
+
+func TestPathCacheSurvivesMonsterJitter(t *testing.T) {
+	d, pf := routeFixture(t)
+
+	// The other side of the room, with a monster on the way
+	to := data.Position{X: 17822, Y: 7192}
+	monster := data.Monster{UnitID: 1, Name: npc.Zombie, Position: data.Position{X: 17829, Y: 7197}}
+	d.Monsters = data.Monsters{monster}
+
+	path, _, found := pf.GetPathFrom(d.PlayerUnit.Position, to)
+	if !found {
+		t.Fatal("expected a path across the room")
+	}
+	generation := pf.walkPlan.generation
+
+	// Shuffling around its position keeps the plan and the cached path
+	for _, offset := range []data.Position{{X: 1}, {X: 1, Y: 1}, {X: -1, Y: 2}, {X: -2, Y: -2}} {
+		d.Monsters[0].Position = data.Position{X: monster.Position.X + offset.X, Y: monster.Position.Y + offset.Y}
+		jittered, _, _ := pf.GetPathFrom(d.PlayerUnit.Position, to)
+		if pf.walkPlan.generation != generation {
+			t.Fatalf("monster moved by %v, the plan changed", offset)
+		}
+		if len(jittered) != len(path) {
+			t.Errorf("monster moved by %v, expected the cached path", offset)
+		}
+	}
+
+	// Moving away updates the plan
+	d.Monsters[0].Position = data.Position{X: monster.Position.X + monsterMoveThreshold + 1, Y: monster.Position.Y}
+	pf.GetPathFrom(d.PlayerUnit.Position, to)
+	if pf.walkPlan.generation == generation {
+		t.Error("expected the plan to follow the monster")
+	}
+}
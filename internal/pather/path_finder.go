@@ -3,6 +3,7 @@ package pather
 import (
 	"fmt"
 	"math"
+	"sync"
 
 	"github.com/hectorgimenez/d2go/pkg/data"
 	"github.com/hectorgimenez/d2go/pkg/data/area"
@@ -17,6 +18,14 @@ type PathFinder struct {
 	data *game.Data
 	hid  *game.HID
 	cfg  *config.CharacterCfg
+
+	// Grids, paths and searches kept between calls, they are reused until the area or the obstacles change
+	mu           sync.Mutex
+	walkPlan     *plan
+	teleportPlan *plan
+	hazards      map[data.UnitID]hazardZone
+	hazardArea   area.ID
+	waypoints    []area.ID // Last waypoints read from the waypoint menu, see knownWaypoints
 }
 
 func NewPathFinder(gr *game.MemoryReader, data *game.Data, hid *game.HID, cfg *config.CharacterCfg) *PathFinder {
@@ -43,13 +52,59 @@ func (pf *PathFinder) GetPath(to data.Position) (Path, int, bool) {
 }
 
 func (pf *PathFinder) GetPathFrom(from, to data.Position) (Path, int, bool) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	plan, from, to, ok := pf.updatePlan(&pf.walkPlan, from, to, false)
+	if !ok {
+		return nil, 0, false
+	}
+
+	key := plan.pathKey(from, to)
+	if cached, found := plan.paths[key]; found {
+		return cached.path, cached.distance, cached.found
+	}
+
+	var path Path
+	var distance int
+	var found bool
+	switch {
+	case plan.replanner != nil && plan.replanner.Goal() == to:
+		// Same destination as before, the previous search is repaired
+		plan.replanner.MoveStart(from)
+		path, distance, found = plan.replanner.Path()
+	case plan.lastGoal == to:
+		// The destination was requested again, usually by MoveTo, it's worth to keep the search for the next calls
+		plan.replanner = astar.NewDStarLite(plan.grid, from, to)
+		path, distance, found = plan.replanner.Path()
+	default:
+		plan.replanner = nil
+		path, distance, found = astar.CalculatePath(plan.grid, from, to)
+	}
+	plan.lastGoal = to
+	plan.store(key, path, distance, found)
+
+	if config.Koolo.Debug.RenderMap {
+		pf.renderMap(plan.grid, from, to, path)
+	}
+
+	return path, distance, found
+}
+
+// baseGrid returns a copy of the collision grid used to plan a path to the destination, without monsters and objects.
+// Walls are kept as they are for the teleport planner, it ignores them anyway.
+func (pf *PathFinder) baseGrid(to data.Position, teleportPlanner bool) (*game.Grid, error) {
 	a := pf.data.AreaData
 
+	if !a.IsInside(to) {
+		return pf.mergeGrids(to)
+	}
+
 	// We don't want to modify the original grid
 	grid := a.Grid.Copy()
 
 	// Special handling for Arcane Sanctuary (to allow pathing with platforms)
-	if pf.data.PlayerUnit.Area == area.ArcaneSanctuary && pf.data.CanTeleport() {
+	if pf.data.PlayerUnit.Area == area.ArcaneSanctuary && pf.data.CanTeleport() && !teleportPlanner {
 		// Make all non-walkable tiles into low priority tiles for teleport pathing
 		for y := 0; y < len(grid.CollisionGrid); y++ {
 			for x := 0; x < len(grid.CollisionGrid[y]); x++ {
@@ -59,29 +114,29 @@ func (pf *PathFinder) GetPathFrom(from, to data.Position) (Path, int, bool) {
 			}
 		}
 	}
-	// Lut Gholein map is a bit bugged, we should close this fake path to avoid pathing issues
-	if a.Area == area.LutGholein {
-		a.CollisionGrid[13][210] = game.CollisionTypeNonWalkable
-	}
 
-	if !a.IsInside(to) {
-		expandedGrid, err := pf.mergeGrids(to)
-		if err != nil {
-			return nil, 0, false
+	return grid, nil
+}
+
+// obstacles returns the objects, monsters and barricade towers to add on top of the base grid, by position relative
+// to the grid
+func (pf *PathFinder) obstacles(grid *game.Grid, monsters data.Monsters) map[data.Position]game.CollisionType {
+	a := pf.data.AreaData
+	overlay := make(map[data.Position]game.CollisionType)
+	collisionType := func(p data.Position) game.CollisionType {
+		if t, found := overlay[p]; found {
+			return t
 		}
-		grid = expandedGrid
+		return grid.CollisionGrid[p.Y][p.X]
 	}
 
-	from = grid.RelativePosition(from)
-	to = grid.RelativePosition(to)
-
 	// Add objects to the collision grid as obstacles
-	for _, o := range pf.data.AreaData.Objects {
+	for _, o := range a.Objects {
 		if !grid.IsWalkable(o.Position) {
 			continue
 		}
 		relativePos := grid.RelativePosition(o.Position)
-		grid.CollisionGrid[relativePos.Y][relativePos.X] = game.CollisionTypeObject
+		overlay[relativePos] = game.CollisionTypeObject
 		for i := -2; i <= 2; i++ {
 			for j := -2; j <= 2; j++ {
 				if i == 0 && j == 0 {
@@ -90,26 +145,24 @@ func (pf *PathFinder) GetPathFrom(from, to data.Position) (Path, int, bool) {
 				if relativePos.Y+i < 0 || relativePos.Y+i >= len(grid.CollisionGrid) || relativePos.X+j < 0 || relativePos.X+j >= len(grid.CollisionGrid[relativePos.Y]) {
 					continue
 				}
-				if grid.CollisionGrid[relativePos.Y+i][relativePos.X+j] == game.CollisionTypeWalkable {
-					grid.CollisionGrid[relativePos.Y+i][relativePos.X+j] = game.CollisionTypeLowPriority
-
+				p := data.Position{X: relativePos.X + j, Y: relativePos.Y + i}
+				if collisionType(p) == game.CollisionTypeWalkable {
+					overlay[p] = game.CollisionTypeLowPriority
 				}
 			}
 		}
 	}
 
 	// Add monsters to the collision grid as obstacles
-	for _, m := range pf.data.Monsters {
+	for _, m := range monsters {
 		if !grid.IsWalkable(m.Position) {
 			continue
 		}
-		relativePos := grid.RelativePosition(m.Position)
-		grid.CollisionGrid[relativePos.Y][relativePos.X] = game.CollisionTypeMonster
+		overlay[grid.RelativePosition(m.Position)] = game.CollisionTypeMonster
 	}
 
 	// set barricade tower as non walkable in act 5
 	if a.Area == area.FrigidHighlands || a.Area == area.FrozenTundra || a.Area == area.ArreatPlateau {
-		towerCount := 0
 		for _, n := range pf.data.NPCs {
 			if n.ID != npc.BarricadeTower {
 				continue
@@ -117,12 +170,9 @@ func (pf *PathFinder) GetPathFrom(from, to data.Position) (Path, int, bool) {
 			if len(n.Positions) == 0 {
 				continue
 			}
-			npcPos := n.Positions[0]
-			relativePos := grid.RelativePosition(npcPos)
-			towerCount++
+			relativePos := grid.RelativePosition(n.Positions[0])
 
 			// Set a 5x5 area around the barricade tower as non-walkable
-			blockedCells := 0
 			for dy := -2; dy <= 2; dy++ {
 				for dx := -2; dx <= 2; dx++ {
 					towerY := relativePos.Y + dy
@@ -131,20 +181,14 @@ func (pf *PathFinder) GetPathFrom(from, to data.Position) (Path, int, bool) {
 					// Bounds checking to prevent array index out of bounds
 					if towerY >= 0 && towerY < len(grid.CollisionGrid) &&
 						towerX >= 0 && towerX < len(grid.CollisionGrid[towerY]) {
-						grid.CollisionGrid[towerY][towerX] = game.CollisionTypeNonWalkable
-						blockedCells++
+						overlay[data.Position{X: towerX, Y: towerY}] = game.CollisionTypeNonWalkable
 					}
 				}
 			}
 		}
 	}
-	path, distance, found := astar.CalculatePath(grid, from, to)
 
-	if config.Koolo.Debug.RenderMap {
-		pf.renderMap(grid, from, to, path)
-	}
-
-	return path, distance, found
+	return overlay
 }
 
 func (pf *PathFinder) mergeGrids(to data.Position) (*game.Grid, error) {
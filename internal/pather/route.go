@@ -0,0 +1,345 @@
+package pather
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/area"
+	"github.com/hectorgimenez/koolo/internal/game"
+	"github.com/hectorgimenez/koolo/internal/pather/astar"
+)
+
+const (
+	// Fixed costs of the area transitions, in tiles, they cover the clicks and the loading screens
+	entranceCost   = 30
+	waypointCost   = 60
+	townPortalCost = 80 // The waypoint is only used from town, a portal is needed first when we are outside
+
+	// Segments are planned with estimates first and refined on the grid, every refinement can change the route
+	maxRouteRefinements = 20
+	// Entrances and waypoints are usually not walkable, the closest walkable tile is used for the segments
+	routeWalkableRadius = 10
+)
+
+// RouteStepKind is how the next area of a route is reached
+type RouteStepKind int
+
+const (
+	// RouteWalk walks through the border shared with the next area
+	RouteWalk RouteStepKind = iota
+	// RouteEntrance clicks an entrance, like cave stairs
+	RouteEntrance
+	// RouteWaypoint travels with the waypoint, from town
+	RouteWaypoint
+)
+
+func (k RouteStepKind) String() string {
+	switch k {
+	case RouteEntrance:
+		return "entrance"
+	case RouteWaypoint:
+		return "waypoint"
+	default:
+		return "walk"
+	}
+}
+
+// RouteStep moves from one area to the next one, Position is where the step ends: the border or entrance of the next
+// area, or the waypoint when traveling with it
+type RouteStep struct {
+	Kind     RouteStepKind
+	From     area.ID
+	To       area.ID
+	Position data.Position
+	Cost     int
+}
+
+// Route is the cheapest way found to reach a position of any area, the last step leaves us in the destination area
+type Route struct {
+	Steps       []RouteStep
+	Area        area.ID
+	Destination data.Position
+	Cost        int
+
+	walks []routeSegment // Walks inside every area of the route, waypoints don't need any
+}
+
+func (r Route) String() string {
+	areas := make([]string, 0, len(r.Steps)+1)
+	for _, s := range r.Steps {
+		areas = append(areas, fmt.Sprintf("%s (%s)", s.From.Area().Name, s.Kind))
+	}
+	areas = append(areas, r.Area.Area().Name)
+
+	return strings.Join(areas, " -> ")
+}
+
+// routeNode is an area reached by the planner and the position where we arrive to it
+type routeNode struct {
+	area     area.ID
+	position data.Position
+	cost     int
+	previous *routeNode
+	step     RouteStep
+}
+
+// routeKey identifies the planner nodes, the same area can be reached at several positions and the cheapest arrival
+// is not always the one leading to the cheapest route
+type routeKey struct {
+	area     area.ID
+	position data.Position
+}
+
+// routeSegment is a walk inside an area, between the arrival position and the exit or the destination
+type routeSegment struct {
+	area     area.ID
+	from, to data.Position
+}
+
+// PlanRoute plans the cheapest route from the player position to a position in the destination area. Routes are
+// planned on a graph of areas linked by their borders, entrances and waypoints, first with estimated costs for the
+// walks inside every area, then the walks of the route are refined on the area grids until the route doesn't change.
+func (pf *PathFinder) PlanRoute(dst area.ID, to data.Position) (Route, bool) {
+	// Exact costs of the walks already planned on the grid, they are reused by the next refinements
+	exact := make(map[routeSegment]int)
+
+	for i := 0; i < maxRouteRefinements; i++ {
+		route, found := pf.planAreaRoute(dst, to, exact)
+		if !found {
+			return Route{}, false
+		}
+
+		refined := false
+		for _, s := range route.walks {
+			if _, known := exact[s]; !known {
+				exact[s] = pf.segmentCost(s)
+				refined = true
+			}
+		}
+		if !refined {
+			return route, true
+		}
+	}
+
+	// Too many refinements, the last route is good enough
+	return pf.planAreaRoute(dst, to, exact)
+}
+
+// planAreaRoute runs Dijkstra over the areas, using the exact costs of the walks when known
+func (pf *PathFinder) planAreaRoute(dst area.ID, to data.Position, exact map[routeSegment]int) (Route, bool) {
+	walkCost := func(s routeSegment) int {
+		if cost, found := exact[s]; found {
+			return cost
+		}
+		return DistanceFromPoint(s.from, s.to)
+	}
+
+	start := &routeNode{area: pf.data.PlayerUnit.Area, position: pf.data.PlayerUnit.Position}
+	open := []*routeNode{start}
+	best := map[routeKey]*routeNode{start.key(): start}
+	visited := make(map[routeKey]bool)
+	var goal *routeNode
+	goalCost := math.MaxInt
+
+	for len(open) > 0 {
+		slices.SortFunc(open, func(a, b *routeNode) int { return a.cost - b.cost })
+		current := open[0]
+		open = open[1:]
+		if visited[current.key()] || current.cost >= goalCost {
+			continue
+		}
+		visited[current.key()] = true
+
+		if current.area == dst {
+			cost := walkCost(routeSegment{area: dst, from: current.position, to: to})
+			if cost < math.MaxInt32 && current.cost+cost < goalCost {
+				goal, goalCost = current, current.cost+cost
+			}
+			continue
+		}
+
+		for _, step := range pf.routeSteps(current, current == start) {
+			stepCost := step.Cost
+			if step.Kind != RouteWaypoint {
+				walk := walkCost(routeSegment{area: current.area, from: current.position, to: step.Position})
+				if walk >= math.MaxInt32 {
+					continue
+				}
+				stepCost += walk
+			}
+
+			next := &routeNode{
+				area:     step.To,
+				position: pf.arrivalPosition(step),
+				cost:     current.cost + stepCost,
+				previous: current,
+				step:     step,
+			}
+			next.step.Cost = stepCost
+			if known, found := best[next.key()]; !found || next.cost < known.cost {
+				best[next.key()] = next
+				open = append(open, next)
+			}
+		}
+	}
+
+	if goal == nil {
+		return Route{}, false
+	}
+
+	route := Route{Area: dst, Destination: to, Cost: goalCost}
+	route.walks = append(route.walks, routeSegment{area: dst, from: goal.position, to: to})
+	for n := goal; n.previous != nil; n = n.previous {
+		route.Steps = append(route.Steps, n.step)
+		if n.step.Kind != RouteWaypoint {
+			route.walks = append(route.walks, routeSegment{area: n.previous.area, from: n.previous.position, to: n.step.Position})
+		}
+	}
+	slices.Reverse(route.Steps)
+
+	return route, true
+}
+
+func (n *routeNode) key() routeKey {
+	return routeKey{area: n.area, position: n.position}
+}
+
+// routeSteps returns the areas reachable from the node, waypoints are only taken at the beginning of the route since
+// the waypoint action travels from town
+func (pf *PathFinder) routeSteps(n *routeNode, first bool) []RouteStep {
+	steps := make([]RouteStep, 0)
+	for _, lvl := range pf.data.Areas[n.area].AdjacentLevels {
+		if lvl.Position.X == 0 && lvl.Position.Y == 0 {
+			continue
+		}
+		step := RouteStep{Kind: RouteWalk, From: n.area, To: lvl.Area, Position: lvl.Position}
+		if lvl.IsEntrance {
+			step.Kind = RouteEntrance
+			step.Cost = entranceCost
+		}
+		steps = append(steps, step)
+	}
+
+	if !first {
+		return steps
+	}
+
+	cost := waypointCost
+	if !n.area.IsTown() {
+		cost += townPortalCost
+	}
+	for _, wp := range pf.knownWaypoints() {
+		if wp == n.area {
+			continue
+		}
+		if position, found := pf.waypointPosition(wp); found {
+			steps = append(steps, RouteStep{Kind: RouteWaypoint, From: n.area, To: wp, Position: position, Cost: cost})
+		}
+	}
+
+	return steps
+}
+
+// knownWaypoints are the waypoints we can travel to. The player waypoints are only read while the waypoint menu is
+// open, the last ones read are used until then, and none of them before the menu is opened the first time.
+func (pf *PathFinder) knownWaypoints() []area.ID {
+	pf.RefreshWaypoints()
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	return pf.waypoints
+}
+
+// RefreshWaypoints keeps the waypoints discovered by the player, it must be called while the waypoint menu is open
+func (pf *PathFinder) RefreshWaypoints() {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if len(pf.data.PlayerUnit.AvailableWaypoints) > 0 {
+		pf.waypoints = slices.Clone(pf.data.PlayerUnit.AvailableWaypoints)
+	}
+}
+
+func (pf *PathFinder) waypointPosition(a area.ID) (data.Position, bool) {
+	for _, o := range pf.data.Areas[a].Objects {
+		if o.IsWaypoint() {
+			return o.Position, true
+		}
+	}
+
+	return data.Position{}, false
+}
+
+// arrivalPosition is where we appear in the next area, entrances take us to the level linking back to the previous
+// area, while borders and waypoints leave us on the step position
+func (pf *PathFinder) arrivalPosition(step RouteStep) data.Position {
+	if step.Kind != RouteEntrance {
+		return step.Position
+	}
+
+	for _, lvl := range pf.data.Areas[step.To].AdjacentLevels {
+		if lvl.Area == step.From && (lvl.Position.X != 0 || lvl.Position.Y != 0) {
+			return lvl.Position
+		}
+	}
+
+	return step.Position
+}
+
+// segmentCost plans the walk on the area grid, teleport characters only pay for the hops. It returns math.MaxInt32
+// when there is no path.
+func (pf *PathFinder) segmentCost(s routeSegment) int {
+	a, found := pf.data.Areas[s.area]
+	if !found || a.Grid == nil {
+		return DistanceFromPoint(s.from, s.to)
+	}
+
+	from, fromFound := nearestWalkable(a.Grid, s.from)
+	to, toFound := nearestWalkable(a.Grid, s.to)
+	if !fromFound || !toFound {
+		return math.MaxInt32
+	}
+
+	var distance int
+	if pf.Planner() == PlannerTeleport {
+		_, distance, found = astar.CalculateTeleportPath(a.Grid, from, to, maxTeleportHop, nil)
+	} else {
+		_, distance, found = astar.CalculatePath(a.Grid, from, to)
+	}
+	if !found {
+		return math.MaxInt32
+	}
+
+	return distance
+}
+
+// nearestWalkable returns the closest walkable tile to p, relative to the grid
+func nearestWalkable(g *game.Grid, p data.Position) (data.Position, bool) {
+	for radius := 0; radius <= routeWalkableRadius; radius++ {
+		for y := -radius; y <= radius; y++ {
+			for x := -radius; x <= radius; x++ {
+				if max(abs(x), abs(y)) != radius {
+					continue
+				}
+				pos := data.Position{X: p.X + x, Y: p.Y + y}
+				if g.IsWalkable(pos) {
+					return g.RelativePosition(pos), true
+				}
+			}
+		}
+	}
+
+	return data.Position{}, false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
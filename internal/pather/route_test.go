@@ -0,0 +1,136 @@
+package pather
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/area"
+	"github.com/hectorgimenez/d2go/pkg/data/object"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/game"
+)
+
+// routeFixture loads the Durance of Hate Level 2 fixture, the player is inside a room at 17836,7201
+func routeFixture(t *testing.T) (*game.Data, *PathFinder) {
+	t.Helper()
+
+	if config.Koolo == nil {
+		config.Koolo = &config.KooloCfg{}
+	}
+	d := loadFixture(t, filepath.Join("testdata", "durance_of_hate_level_2.json.gz"))
+
+	return d, NewPathFinder(nil, d, nil, &config.CharacterCfg{})
+}
+
+// testArea returns an area filled with walls, open are the walkable positions, in absolute coordinates
+func testArea(id area.ID, offset data.Position, width, height int, open ...data.Position) game.AreaData {
+	cg := make([][]game.CollisionType, height)
+	for y := range cg {
+		cg[y] = make([]game.CollisionType, width)
+		for x := range cg[y] {
+			cg[y][x] = game.CollisionTypeNonWalkable
+		}
+	}
+	for _, p := range open {
+		cg[p.Y-offset.Y][p.X-offset.X] = game.CollisionTypeWalkable
+	}
+
+	return game.AreaData{Area: id, Grid: game.NewGrid(cg, offset.X, offset.Y)}
+}
+
+func line(from, to data.Position) []data.Position {
+	positions := make([]data.Position, 0)
+	for y := from.Y; y <= to.Y; y++ {
+		for x := from.X; x <= to.X; x++ {
+			positions = append(positions, data.Position{X: x, Y: y})
+		}
+	}
+
+	return positions
+}
+
+func TestPlanRouteSameArea(t *testing.T) {
+	d, pf := routeFixture(t)
+
+	// The other side of the room
+	to := data.Position{X: 17822, Y: 7192}
+	route, found := pf.PlanRoute(d.PlayerUnit.Area, to)
+	if !found {
+		t.Fatal("expected a route inside the area")
+	}
+	if len(route.Steps) != 0 {
+		t.Errorf("expected no steps, got %s", route)
+	}
+	expected := pf.segmentCost(routeSegment{area: d.PlayerUnit.Area, from: d.PlayerUnit.Position, to: to})
+	if route.Cost != expected {
+		t.Errorf("expected the cost of the walk on the grid %d, got %d", expected, route.Cost)
+	}
+
+	// Surrounded by walls, far from any walkable tile
+	if _, found = pf.PlanRoute(d.PlayerUnit.Area, data.Position{X: 17505, Y: 6505}); found {
+		t.Error("expected no route to a non walkable position")
+	}
+}
+
+func TestPlanRouteArrivalPositions(t *testing.T) {
+	d, pf := routeFixture(t)
+
+	// Level 3 is reached through two borders. The closest one leads to a dead end, the destination is only reachable
+	// from the farthest one, below the room.
+	closest := data.Position{X: 17822, Y: 7192}
+	farthest := data.Position{X: 17830, Y: 7208}
+	to := data.Position{X: 17855, Y: 7208}
+
+	current := d.Areas[d.PlayerUnit.Area]
+	current.AdjacentLevels = []data.Level{
+		{Area: area.DuranceOfHateLevel3, Position: closest},
+		{Area: area.DuranceOfHateLevel3, Position: farthest},
+	}
+	d.Areas[d.PlayerUnit.Area] = current
+	d.Areas[area.DuranceOfHateLevel3] = testArea(area.DuranceOfHateLevel3, data.Position{X: 17800, Y: 7180}, 60, 50,
+		append(line(data.Position{X: 17821, Y: 7191}, data.Position{X: 17823, Y: 7193}), line(farthest, to)...)...)
+
+	route, found := pf.PlanRoute(area.DuranceOfHateLevel3, to)
+	if !found {
+		t.Fatal("expected a route through the farthest border")
+	}
+	if len(route.Steps) != 1 || route.Steps[0].Kind != RouteWalk || route.Steps[0].Position != farthest {
+		t.Errorf("expected to walk through the border at %v, got %s %+v", farthest, route, route.Steps)
+	}
+	if route.Cost >= math.MaxInt32 {
+		t.Errorf("unexpected route cost %d", route.Cost)
+	}
+}
+
+func TestPlanRouteWaypoints(t *testing.T) {
+	d, pf := routeFixture(t)
+
+	wp := data.Position{X: 5010, Y: 5010}
+	to := data.Position{X: 5015, Y: 5015}
+	town := testArea(area.ThePandemoniumFortress, data.Position{X: 5000, Y: 5000}, 20, 20, line(wp, to)...)
+	town.Objects = []data.Object{{Name: object.PandamoniumFortressWaypoint, Position: wp}}
+	d.Areas[area.ThePandemoniumFortress] = town
+
+	// The waypoint menu was never opened, no waypoint can be used
+	if route, found := pf.PlanRoute(area.ThePandemoniumFortress, to); found {
+		t.Fatalf("expected no route without known waypoints, got %s", route)
+	}
+
+	d.PlayerUnit.AvailableWaypoints = []area.ID{area.ThePandemoniumFortress}
+	route, found := pf.PlanRoute(area.ThePandemoniumFortress, to)
+	if !found || len(route.Steps) != 1 || route.Steps[0].Kind != RouteWaypoint {
+		t.Fatalf("expected to travel with the waypoint, got %s %+v", route, route.Steps)
+	}
+	// Outside of town, a portal is needed before taking the waypoint
+	if route.Steps[0].Cost != waypointCost+townPortalCost {
+		t.Errorf("expected the waypoint and portal cost, got %d", route.Steps[0].Cost)
+	}
+
+	// The menu is closed, the waypoints read last are still used
+	d.PlayerUnit.AvailableWaypoints = nil
+	if _, found = pf.PlanRoute(area.ThePandemoniumFortress, to); !found {
+		t.Error("expected the last known waypoints to be used")
+	}
+}
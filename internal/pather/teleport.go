@@ -0,0 +1,86 @@
+package pather
+
+import (
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/pather/astar"
+)
+
+// maxTeleportHop is the longest teleport planned in tiles, hops are limited by the screen too
+const maxTeleportHop = 25
+
+// Planner is how the movement paths are planned
+type Planner int
+
+const (
+	// PlannerWalk plans every tile of a walking path
+	PlannerWalk Planner = iota
+	// PlannerTeleport plans teleport landings, jumping over walls
+	PlannerTeleport
+)
+
+// Planner returns the planner used by GetMovementPath, teleport is used when the character can teleport
+func (pf *PathFinder) Planner() Planner {
+	if pf.data.CanTeleport() {
+		return PlannerTeleport
+	}
+
+	return PlannerWalk
+}
+
+// GetMovementPath returns the path to follow to reach the destination with the current planner, teleport paths only
+// contain the landings. It falls back to the walking path when no teleport path is found.
+func (pf *PathFinder) GetMovementPath(to data.Position) (Path, int, bool) {
+	if pf.Planner() == PlannerTeleport {
+		if path, distance, found := pf.GetTeleportPath(to); found {
+			return path, distance, true
+		}
+	}
+
+	return pf.GetPath(to)
+}
+
+func (pf *PathFinder) GetTeleportPath(to data.Position) (Path, int, bool) {
+	if path, distance, found := pf.GetTeleportPathFrom(pf.data.PlayerUnit.Position, to); found {
+		return path, distance, true
+	}
+
+	if walkableTo, found := pf.findNearbyWalkablePosition(to); found {
+		return pf.GetTeleportPathFrom(pf.data.PlayerUnit.Position, walkableTo)
+	}
+
+	return nil, 0, false
+}
+
+// GetTeleportPathFrom plans teleport landings from one position to another, minimizing the number of hops and avoiding
+// landings close to monsters
+func (pf *PathFinder) GetTeleportPathFrom(from, to data.Position) (Path, int, bool) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	plan, from, to, ok := pf.updatePlan(&pf.teleportPlan, from, to, true)
+	if !ok {
+		return nil, 0, false
+	}
+
+	key := plan.pathKey(from, to)
+	if cached, found := plan.paths[key]; found {
+		return cached.path, cached.distance, cached.found
+	}
+
+	path, distance, found := astar.CalculateTeleportPath(plan.grid, from, to, maxTeleportHop, pf.teleportReachable)
+	plan.store(key, path, distance, found)
+
+	if config.Koolo.Debug.RenderMap {
+		pf.renderMap(plan.grid, from, to, path)
+	}
+
+	return path, distance, found
+}
+
+// teleportReachable checks the landing can be clicked when standing on from, above the HUD and inside the window
+func (pf *PathFinder) teleportReachable(from, to data.Position) bool {
+	screenX, screenY := pf.gameCoordsToScreenCords(from.X, from.Y, to.X, to.Y)
+
+	return screenX >= 0 && screenY >= 0 && screenX <= pf.gr.GameAreaSizeX && screenY <= int(float32(pf.gr.GameAreaSizeY)/1.21)
+}
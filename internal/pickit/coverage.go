@@ -0,0 +1,126 @@
+package pickit
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+)
+
+// StashedDrop is a stashed item as recorded in the droplog
+type StashedDrop struct {
+	Time time.Time
+	Drop data.Drop
+}
+
+// RuleCoverage is how many stashed items a rule matched
+type RuleCoverage struct {
+	Rule      string
+	Origin    Origin
+	Matches   int
+	LastMatch time.Time
+	// Share is the percentage of the items stashed by a rule that this rule matched
+	Share float64
+}
+
+// ReasonCoverage counts the items stashed without a pickit rule, like the first run or recipe items
+type ReasonCoverage struct {
+	Reason  string
+	Matches int
+}
+
+// Coverage is the pickit rules usage over the stashed items
+type Coverage struct {
+	Stashed     int
+	RuleStashed int
+	// Rules are the loaded rules in evaluation order, Unused the ones among them that never matched
+	Rules  []RuleCoverage
+	Unused []RuleCoverage
+	// Removed are the rules recorded in the drops that are not loaded anymore
+	Removed []RuleCoverage
+	Reasons []ReasonCoverage
+}
+
+// Coverage attributes every stashed item to the rule recorded when it was stashed, rules are compared ignoring
+// comments, spacing and case so editing a file doesn't reset its counters
+func (s *RuleSet) Coverage(drops []StashedDrop) Coverage {
+	c := Coverage{Stashed: len(drops)}
+
+	c.Rules = make([]RuleCoverage, len(s.Rules))
+	for i, rule := range s.Rules {
+		c.Rules[i] = RuleCoverage{Rule: rule.RawLine, Origin: s.Origins[i]}
+	}
+
+	removed := make(map[string]*RuleCoverage)
+	reasons := make(map[string]int)
+	for _, d := range drops {
+		// Drops without a rule file were kept without a pickit rule, like first run or recipe items, Rule holds the reason
+		if d.Drop.RuleFile == "" {
+			reason := d.Drop.Rule
+			if reason == "" {
+				reason = "Unknown"
+			}
+			reasons[reason]++
+			continue
+		}
+
+		c.RuleStashed++
+		var rc *RuleCoverage
+		if i, found := s.keys[ruleKey(d.Drop.Rule)]; found {
+			rc = &c.Rules[i]
+		} else {
+			key := ruleKey(d.Drop.Rule)
+			if rc = removed[key]; rc == nil {
+				rc = &RuleCoverage{Rule: d.Drop.Rule, Origin: recordedOrigin(d.Drop.RuleFile)}
+				removed[key] = rc
+			}
+		}
+		rc.Matches++
+		if d.Time.After(rc.LastMatch) {
+			rc.LastMatch = d.Time
+		}
+	}
+
+	for _, rc := range removed {
+		c.Removed = append(c.Removed, *rc)
+	}
+	slices.SortFunc(c.Removed, func(a, b RuleCoverage) int { return cmp.Compare(b.Matches, a.Matches) })
+
+	for i := range c.Rules {
+		if c.RuleStashed > 0 {
+			c.Rules[i].Share = float64(c.Rules[i].Matches) * 100 / float64(c.RuleStashed)
+		}
+		if c.Rules[i].Matches == 0 {
+			c.Unused = append(c.Unused, c.Rules[i])
+		}
+	}
+	for i := range c.Removed {
+		c.Removed[i].Share = float64(c.Removed[i].Matches) * 100 / float64(c.RuleStashed)
+	}
+
+	for reason, matches := range reasons {
+		c.Reasons = append(c.Reasons, ReasonCoverage{Reason: reason, Matches: matches})
+	}
+	slices.SortFunc(c.Reasons, func(a, b ReasonCoverage) int {
+		return cmp.Or(cmp.Compare(b.Matches, a.Matches), strings.Compare(a.Reason, b.Reason))
+	})
+
+	return c
+}
+
+// recordedOrigin parses the file:line recorded in the droplog, the file can contain a drive letter
+func recordedOrigin(ruleFile string) Origin {
+	i := strings.LastIndex(ruleFile, ":")
+	if i < 0 {
+		return Origin{File: ruleFile}
+	}
+	line, err := strconv.Atoi(ruleFile[i+1:])
+	if err != nil {
+		return Origin{File: ruleFile}
+	}
+
+	return Origin{File: ruleFile[:i], Line: line}
+}
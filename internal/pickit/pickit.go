@@ -0,0 +1,182 @@
+package pickit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/data/item"
+	"github.com/hectorgimenez/d2go/pkg/nip"
+)
+
+// Layer is the kind of pickit folder a rule comes from, rules are evaluated in the order they are added, which is
+// the order of the layers below when loaded by the config
+type Layer string
+
+const (
+	LayerBase        Layer = "base"
+	LayerCentralized Layer = "centralized"
+	LayerLeveling    Layer = "leveling"
+	LayerClass       Layer = "class"
+	LayerQuest       Layer = "quest"
+	LayerMemory      Layer = "memory" // Rules not coming from a file, like the ones tested from the UI
+)
+
+// Origin is where a rule was loaded from
+type Origin struct {
+	Layer Layer
+	File  string
+	Line  int
+}
+
+func (o Origin) String() string {
+	if o.Layer == "" {
+		return fmt.Sprintf("%s:%d", o.File, o.Line)
+	}
+
+	return fmt.Sprintf("%s:%d (%s)", o.File, o.Line, o.Layer)
+}
+
+// Duplicate is a rule that was not added because an equivalent one was already loaded
+type Duplicate struct {
+	Origin
+	Of Origin
+}
+
+// RuleSet is an ordered list of rules from several files, every rule is added once
+type RuleSet struct {
+	Rules nip.Rules
+	// Origins[i] is where Rules[i] comes from
+	Origins    []Origin
+	Duplicates []Duplicate
+
+	keys      map[string]int
+	locations map[location]int
+}
+
+type location struct {
+	file string
+	line int
+}
+
+func NewRuleSet() *RuleSet {
+	return &RuleSet{
+		keys:      make(map[string]int),
+		locations: make(map[location]int),
+	}
+}
+
+// AddDir adds every .nip file of dir sorted by name, except the ones in exclude
+func (s *RuleSet) AddDir(layer Layer, dir string, exclude ...string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading pickit directory %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(name, ".nip") {
+			continue
+		}
+		if slices.ContainsFunc(exclude, func(e string) bool { return strings.EqualFold(e, name) }) {
+			continue
+		}
+		if err = s.AddFile(layer, filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddFile adds the rules of a single .nip file
+func (s *RuleSet) AddFile(layer Layer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error reading pickit file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	return s.AddRules(layer, file, f)
+}
+
+// AddRules adds the rules read from r, name is reported as their file
+func (s *RuleSet) AddRules(layer Layer, name string, r io.Reader) error {
+	rules, err := ParseRules(name, r)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		origin := Origin{Layer: layer, File: name, Line: rule.LineNumber}
+		key := ruleKey(rule.RawLine)
+		if i, found := s.keys[key]; found {
+			s.Duplicates = append(s.Duplicates, Duplicate{Origin: origin, Of: s.Origins[i]})
+			continue
+		}
+
+		s.keys[key] = len(s.Rules)
+		s.locations[location{file: name, line: rule.LineNumber}] = len(s.Rules)
+		s.Rules = append(s.Rules, rule)
+		s.Origins = append(s.Origins, origin)
+	}
+
+	return nil
+}
+
+// Origin returns where rule was loaded from, like the rule returned by nip.Rules.EvaluateAll
+func (s *RuleSet) Origin(rule nip.Rule) (Origin, bool) {
+	i, found := s.locations[location{file: rule.Filename, line: rule.LineNumber}]
+	if !found {
+		return Origin{}, false
+	}
+
+	return s.Origins[i], true
+}
+
+// ParseRules reads the rules of a .nip file, every rule is evaluated once so format errors are found at load time
+// instead of when an item is evaluated
+func ParseRules(name string, r io.Reader) (nip.Rules, error) {
+	dummyItem := data.Item{
+		ID:      516,
+		Name:    "healingpotion",
+		Quality: item.QualityNormal,
+	}
+
+	rules := make(nip.Rules, 0)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		rule, err := nip.NewRule(scanner.Text(), name, lineNumber)
+		if errors.Is(err, nip.ErrEmptyRule) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s file at line %d: %w", name, lineNumber, err)
+		}
+
+		if _, err = rule.Evaluate(dummyItem); err != nil {
+			return nil, fmt.Errorf("error testing rule on [%s:%d]: %w", name, lineNumber, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", name, err)
+	}
+
+	return rules, nil
+}
+
+// ruleKey is the rule without comments, spacing and case, two rules with the same key match the same items
+func ruleKey(rawLine string) string {
+	line, _, _ := strings.Cut(rawLine, "//")
+
+	return strings.ToLower(strings.Join(strings.Fields(line), " "))
+}
@@ -0,0 +1,122 @@
+package pickit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hectorgimenez/d2go/pkg/nip"
+)
+
+func writeNip(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("test.nip", strings.NewReader("// Comment\n\n[name] == ring && [quality] == unique\n   \n[type] == amulet // keep\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+	for i, line := range []int{3, 5} {
+		if rules[i].LineNumber != line || rules[i].Filename != "test.nip" {
+			t.Errorf("rule %d: expected test.nip:%d, got %s:%d", i, line, rules[i].Filename, rules[i].LineNumber)
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"syntax":               "[name] == ring\n[name] ==\n",
+		"unsupported property": "[name] == ring\n[color] == red\n",
+	} {
+		_, err := ParseRules("broken.nip", strings.NewReader(content))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), "broken.nip file at line 2") {
+			t.Errorf("%s: the error should point to broken.nip line 2, got %v", name, err)
+		}
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	base := t.TempDir()
+	writeNip(t, base, "b.nip", "[name] == ring\n[type] == amulet\n")
+	writeNip(t, base, "a.nip", "[name] == ring && [quality] == unique\n")
+	writeNip(t, base, "excluded.nip", "[type] == jewel\n")
+	writeNip(t, base, "notes.txt", "[type] == charm\n")
+	class := writeNip(t, t.TempDir(), "class.nip", "// Same rules as the base ones\n[NAME]   ==   RING // rings\n[type] == belt\n")
+
+	s := NewRuleSet()
+	if err := s.AddDir(LayerBase, base, "EXCLUDED.nip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFile(LayerClass, class); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddRules(LayerMemory, "ui", strings.NewReader("[type] == amulet\n[type] == shield\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Origin{
+		{Layer: LayerBase, File: filepath.Join(base, "a.nip"), Line: 1},
+		{Layer: LayerBase, File: filepath.Join(base, "b.nip"), Line: 1},
+		{Layer: LayerBase, File: filepath.Join(base, "b.nip"), Line: 2},
+		{Layer: LayerClass, File: class, Line: 3},
+		{Layer: LayerMemory, File: "ui", Line: 2},
+	}
+	if len(s.Rules) != len(expected) || len(s.Origins) != len(expected) {
+		t.Fatalf("expected %d rules, got %d: %v", len(expected), len(s.Rules), s.Origins)
+	}
+	for i, o := range expected {
+		if s.Origins[i] != o {
+			t.Errorf("rule %d: expected origin %s, got %s", i, o, s.Origins[i])
+		}
+		if got, found := s.Origin(s.Rules[i]); !found || got != o {
+			t.Errorf("rule %d: Origin returned %s, %v", i, got, found)
+		}
+	}
+
+	duplicates := []Duplicate{
+		{Origin: Origin{Layer: LayerClass, File: class, Line: 2}, Of: expected[1]},
+		{Origin: Origin{Layer: LayerMemory, File: "ui", Line: 1}, Of: expected[2]},
+	}
+	if len(s.Duplicates) != len(duplicates) {
+		t.Fatalf("expected %d duplicates, got %v", len(duplicates), s.Duplicates)
+	}
+	for i, d := range duplicates {
+		if s.Duplicates[i] != d {
+			t.Errorf("duplicate %d: expected %s of %s, got %s of %s", i, d.Origin, d.Of, s.Duplicates[i].Origin, s.Duplicates[i].Of)
+		}
+	}
+
+	if _, found := s.Origin(nip.Rule{Filename: "other.nip", LineNumber: 1}); found {
+		t.Error("expected no origin for a rule not in the set")
+	}
+}
+
+func TestRuleSetErrors(t *testing.T) {
+	s := NewRuleSet()
+	if err := s.AddDir(LayerBase, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+
+	dir := t.TempDir()
+	writeNip(t, dir, "broken.nip", "[name] ==\n")
+	if err := s.AddDir(LayerBase, dir); err == nil {
+		t.Error("expected an error for a broken file")
+	}
+}
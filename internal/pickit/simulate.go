@@ -0,0 +1,166 @@
+package pickit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/d2go/pkg/nip"
+)
+
+// Result is the evaluation of a single item, as the bot would do it when the item is on the ground
+type Result struct {
+	Item   data.Item
+	Result nip.RuleResult
+	// Rule and Origin are the matching rule, for partial matches it's the last partially matching one
+	Rule   string
+	Origin Origin
+	// MaxQuantity is the [maxquantity] of the matching rule, ExceedsQuantity is true when the stash already has that
+	// many items matching it and the item would be skipped
+	MaxQuantity     int
+	StashMatches    int
+	ExceedsQuantity bool
+}
+
+// ResultName returns a readable name of an evaluation result
+func ResultName(r nip.RuleResult) string {
+	switch r {
+	case nip.RuleResultFullMatch:
+		return "full match"
+	case nip.RuleResultPartial:
+		return "partial match"
+	default:
+		return "no match"
+	}
+}
+
+func (r Result) ResultName() string {
+	return ResultName(r.Result)
+}
+
+// ItemName is the identified name when the item has one
+func (r Result) ItemName() string {
+	if r.Item.IdentifiedName != "" {
+		return r.Item.IdentifiedName
+	}
+
+	return string(r.Item.Name)
+}
+
+// Evaluate runs EvaluateAll for it, stash are the items already stashed, used to check the rule [maxquantity]
+func (s *RuleSet) Evaluate(it data.Item, stash []data.Item) Result {
+	rule, res := s.Rules.EvaluateAll(it)
+	result := Result{Item: it, Result: res}
+	if res == nip.RuleResultNoMatch {
+		return result
+	}
+
+	result.Rule = rule.RawLine
+	result.Origin = Origin{Layer: LayerMemory, File: rule.Filename, Line: rule.LineNumber}
+	if origin, found := s.Origin(rule); found {
+		result.Origin = origin
+	}
+	if res == nip.RuleResultFullMatch {
+		result.MaxQuantity = rule.MaxQuantity()
+		result.StashMatches = stashMatches(rule, stash)
+		result.ExceedsQuantity = ExceedsQuantity(rule, stash)
+	}
+
+	return result
+}
+
+// ExceedsQuantity returns true when stash already has the [maxquantity] of items matching rule, rules without it
+// never exceed
+func ExceedsQuantity(rule nip.Rule, stash []data.Item) bool {
+	maxQuantity := rule.MaxQuantity()
+	if maxQuantity == 0 {
+		return false
+	}
+
+	return stashMatches(rule, stash) >= maxQuantity
+}
+
+func stashMatches(rule nip.Rule, stash []data.Item) int {
+	matches := 0
+	for _, stashItem := range stash {
+		if res, _ := rule.Evaluate(stashItem); res == nip.RuleResultFullMatch {
+			matches++
+		}
+	}
+
+	return matches
+}
+
+// ReadItems reads item fixtures, it accepts JSON dumps of data.Item, data.Drop or droplog records, as a single value,
+// an array or one value per line like the droplog files
+func ReadItems(r io.Reader) ([]data.Item, error) {
+	var items []data.Item
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid item fixture: %w", err)
+		}
+
+		fixtureItems, err := decodeFixture(raw)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, fixtureItems...)
+	}
+
+	return items, nil
+}
+
+func decodeFixture(raw json.RawMessage) ([]data.Item, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("invalid item fixture: %w", err)
+		}
+		var items []data.Item
+		for _, v := range values {
+			fixtureItems, err := decodeFixture(v)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, fixtureItems...)
+		}
+		return items, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("invalid item fixture, expected an object: %w", err)
+	}
+	for key, value := range fields {
+		switch strings.ToLower(key) {
+		case "drop":
+			// Droplog record
+			return decodeFixture(value)
+		case "item":
+			// data.Drop
+			var drop data.Drop
+			if err := json.Unmarshal(raw, &drop); err != nil {
+				return nil, fmt.Errorf("invalid drop fixture: %w", err)
+			}
+			return []data.Item{drop.Item}, nil
+		}
+	}
+
+	var it data.Item
+	if err := json.Unmarshal(raw, &it); err != nil {
+		return nil, fmt.Errorf("invalid item fixture: %w", err)
+	}
+
+	return []data.Item{it}, nil
+}
@@ -13,10 +13,17 @@ import (
 
 type Bot struct {
 	discordSession *discordgo.Session
+	messages       messageSender
 	channelID      string
 	manager        *bot.SupervisorManager
 }
 
+// messageSender is the subset of the Discord session used to publish the events
+type messageSender interface {
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
 func NewBot(token, channelID string, manager *bot.SupervisorManager) (*Bot, error) {
 	dg, err := discordgo.New("Bot " + token)
 	if err != nil {
@@ -25,6 +32,7 @@ func NewBot(token, channelID string, manager *bot.SupervisorManager) (*Bot, erro
 
 	return &Bot{
 		discordSession: dg,
+		messages:       dg,
 		channelID:      channelID,
 		manager:        manager,
 	}, nil
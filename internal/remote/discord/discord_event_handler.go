@@ -17,10 +17,10 @@ func (b *Bot) Handle(_ context.Context, e event.Event) error {
 		switch evt := e.(type) {
 		case event.GameCreatedEvent:
 			message := fmt.Sprintf("%s\nGame: %s\nPassword: %s", evt.Message(), evt.Name, evt.Password)
-			_, err := b.discordSession.ChannelMessageSend(b.channelID, message)
+			_, err := b.messages.ChannelMessageSend(b.channelID, message)
 			return err
 		case event.GameFinishedEvent, event.RunStartedEvent, event.RunFinishedEvent:
-			_, err := b.discordSession.ChannelMessageSend(b.channelID, e.Message())
+			_, err := b.messages.ChannelMessageSend(b.channelID, e.Message())
 			return err
 		default:
 			break
@@ -32,7 +32,7 @@ func (b *Bot) Handle(_ context.Context, e event.Event) error {
 			return err
 		}
 
-		_, err = b.discordSession.ChannelMessageSendComplex(b.channelID, &discordgo.MessageSend{
+		_, err = b.messages.ChannelMessageSendComplex(b.channelID, &discordgo.MessageSend{
 			File:    &discordgo.File{Name: "Screenshot.jpeg", ContentType: "image/jpeg", Reader: buf},
 			Content: e.Message(),
 		})
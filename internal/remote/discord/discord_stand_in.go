@@ -0,0 +1,37 @@
+package discord
+
+import (
+	"log/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// NewStandIn returns a Bot that logs the messages instead of sending them, the event filtering is the same as the
+// real bot. It can't be started, it's meant to replay recorded events without a Discord connection.
+func NewStandIn(logger *slog.Logger) *Bot {
+	return &Bot{
+		messages:  logSender{logger: logger},
+		channelID: "stand-in",
+	}
+}
+
+type logSender struct {
+	logger *slog.Logger
+}
+
+func (s logSender) ChannelMessageSend(channelID string, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.logger.Info("Discord message", slog.String("channel", channelID), slog.String("content", content))
+
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+func (s logSender) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	attachments := 0
+	if data.File != nil {
+		attachments++
+	}
+	attachments += len(data.Files)
+	s.logger.Info("Discord message", slog.String("channel", channelID), slog.String("content", data.Content), slog.Int("attachments", attachments))
+
+	return &discordgo.Message{ChannelID: channelID, Content: data.Content}, nil
+}
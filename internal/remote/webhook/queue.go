@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxQueuedFiles caps the payloads stored for an endpoint, and the rejected ones, the oldest ones are dropped
+	// when an endpoint is down for long enough
+	maxQueuedFiles = 5000
+	deadLetterDir  = "dead"
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// diskQueue stores undelivered payloads as one file per payload, so they survive endpoint outages and restarts.
+// Payloads rejected by the endpoint are kept in the dead letter folder to be checked manually.
+type diskQueue struct {
+	mu       sync.Mutex
+	dir      string
+	seq      int
+	maxFiles int
+}
+
+func newDiskQueue(baseDir, endpointName string) (*diskQueue, error) {
+	dir := filepath.Join(baseDir, unsafeNameChars.ReplaceAllString(endpointName, "_"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating webhook queue directory %s: %w", dir, err)
+	}
+
+	return &diskQueue{dir: dir, maxFiles: maxQueuedFiles}, nil
+}
+
+// push stores the payload, queuedAt is the time it was queued so the files are sorted in the order of the events
+func (q *diskQueue) push(body []byte, queuedAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.write(q.dir, body, queuedAt)
+}
+
+// peek returns the oldest stored payload, file is empty when the queue is empty
+func (q *diskQueue) peek() (string, []byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	files, err := sortedFiles(q.dir)
+	if err != nil || len(files) == 0 {
+		return "", nil, err
+	}
+
+	body, err := os.ReadFile(files[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return files[0], body, nil
+}
+
+func (q *diskQueue) empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	files, err := sortedFiles(q.dir)
+
+	return err == nil && len(files) == 0
+}
+
+func (q *diskQueue) remove(file string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_ = os.Remove(file)
+}
+
+// deadLetter stores a payload rejected by the endpoint
+func (q *diskQueue) deadLetter(body []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dir := filepath.Join(q.dir, deadLetterDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	return q.write(dir, body, time.Now())
+}
+
+// moveToDeadLetter moves a stored payload rejected by the endpoint out of the queue
+func (q *diskQueue) moveToDeadLetter(file string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dir := filepath.Join(q.dir, deadLetterDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := q.trim(dir); err != nil {
+		return err
+	}
+
+	return os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+}
+
+// write stores the payload in dir after making room for it, q.mu must be held
+func (q *diskQueue) write(dir string, body []byte, t time.Time) error {
+	if err := q.trim(dir); err != nil {
+		return err
+	}
+
+	q.seq++
+	// Zero padded names keep lexical order equal to insertion order
+	name := fmt.Sprintf("%020d-%06d.json", t.UnixNano(), q.seq%1000000)
+
+	return os.WriteFile(filepath.Join(dir, name), body, 0o644)
+}
+
+// trim drops the oldest payloads of dir until there is room for a new one, the recent ones are more relevant when
+// the endpoint comes back
+func (q *diskQueue) trim(dir string) error {
+	files, err := sortedFiles(dir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i <= len(files)-q.maxFiles; i++ {
+		if err = os.Remove(files[i]); err != nil {
+			return fmt.Errorf("error dropping the oldest webhook payload: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sortedFiles returns the payloads stored in dir, oldest first
+func sortedFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
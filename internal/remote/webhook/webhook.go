@@ -0,0 +1,331 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+)
+
+const (
+	defaultMaxRetries = 5
+	queueSize         = 256
+	initialBackoff    = time.Second
+	maxBackoff        = 30 * time.Second
+	queueRetryEvery   = 30 * time.Second
+	requestTimeout    = 10 * time.Second
+
+	SignatureHeader = "X-Koolo-Signature"
+	TimestampHeader = "X-Koolo-Timestamp"
+)
+
+// Payload is the JSON body POSTed to every endpoint
+type Payload struct {
+	event.Envelope
+	Screenshot string `json:"screenshot,omitempty"`
+}
+
+type Notifier struct {
+	endpoints []*endpoint
+	logger    *slog.Logger
+}
+
+type endpoint struct {
+	cfg    config.WebhookEndpoint
+	client *http.Client
+	queue  chan queuedPayload
+	disk   *diskQueue
+	logger *slog.Logger
+}
+
+// queuedPayload keeps the time the payload was queued, so payloads spilled to disk are delivered in the same order
+type queuedPayload struct {
+	body     []byte
+	queuedAt time.Time
+}
+
+// permanentError is a delivery rejected by the endpoint, retrying it would fail the same way
+type permanentError struct {
+	statusCode int
+}
+
+func (e permanentError) Error() string {
+	return fmt.Sprintf("payload rejected with status code %d", e.statusCode)
+}
+
+func NewNotifier(endpoints []config.WebhookEndpoint, queueDir string, logger *slog.Logger) (*Notifier, error) {
+	n := &Notifier{logger: logger}
+	for i, cfg := range endpoints {
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook endpoint %d has no url", i)
+		}
+		if cfg.Name == "" {
+			cfg.Name = "endpoint-" + strconv.Itoa(i)
+		}
+		if cfg.MaxRetries <= 0 {
+			cfg.MaxRetries = defaultMaxRetries
+		}
+
+		disk, err := newDiskQueue(queueDir, cfg.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		n.endpoints = append(n.endpoints, &endpoint{
+			cfg:    cfg,
+			client: &http.Client{Timeout: requestTimeout},
+			queue:  make(chan queuedPayload, queueSize),
+			disk:   disk,
+			logger: logger.With(slog.String("webhook", cfg.Name)),
+		})
+	}
+
+	return n, nil
+}
+
+// Start runs one delivery worker per endpoint until the context is done
+func (n *Notifier) Start(ctx context.Context) error {
+	for _, ep := range n.endpoints {
+		go ep.run(ctx)
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+// Handle builds the payload and queues it for every endpoint interested in the event, it never blocks the event bus
+func (n *Notifier) Handle(_ context.Context, e event.Event) error {
+	var plain, withScreenshot []byte
+	for _, ep := range n.endpoints {
+		if !ep.accepts(e) {
+			continue
+		}
+
+		var err error
+		body := plain
+		if ep.cfg.IncludeScreenshot && e.Image() != nil {
+			if withScreenshot == nil {
+				withScreenshot, err = buildPayload(e, true)
+			}
+			body = withScreenshot
+		} else if plain == nil {
+			plain, err = buildPayload(e, false)
+			body = plain
+		}
+		if err != nil {
+			return fmt.Errorf("error building webhook payload: %w", err)
+		}
+
+		ep.enqueue(body)
+	}
+
+	return nil
+}
+
+func buildPayload(e event.Event, includeScreenshot bool) ([]byte, error) {
+	p := Payload{Envelope: event.NewEnvelope(e)}
+
+	if includeScreenshot {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, e.Image(), &jpeg.Options{Quality: 80}); err != nil {
+			return nil, err
+		}
+		p.Screenshot = base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	return json.Marshal(p)
+}
+
+func (ep *endpoint) accepts(e event.Event) bool {
+	if len(ep.cfg.Supervisors) > 0 {
+		found := false
+		for _, sup := range ep.cfg.Supervisors {
+			if strings.EqualFold(sup, e.Supervisor()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	// Item decisions are sent for every item evaluated, they must be enabled explicitly
+	if len(ep.cfg.Events) == 0 {
+		_, isDecision := e.(event.ItemDecisionEvent)
+		return !isDecision
+	}
+	for _, name := range ep.cfg.Events {
+		if event.MatchesType(e, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enqueue hands the payload to the worker, spilling it to disk if the worker is lagging behind
+func (ep *endpoint) enqueue(body []byte) {
+	p := queuedPayload{body: body, queuedAt: time.Now()}
+	select {
+	case ep.queue <- p:
+	default:
+		if err := ep.disk.push(p.body, p.queuedAt); err != nil {
+			ep.logger.Error("Webhook queue is full and payload could not be stored on disk, dropping it", slog.Any("error", err))
+		}
+	}
+}
+
+func (ep *endpoint) run(ctx context.Context) {
+	ticker := time.NewTicker(queueRetryEvery)
+	defer ticker.Stop()
+
+	// Flush anything left from a previous session first
+	ep.flushDisk(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-ep.queue:
+			// While there is a backlog the payload is queued on disk too, it's sorted by its queue time and delivered
+			// in order with the rest of them
+			if !ep.disk.empty() {
+				ep.store(p)
+				ep.flushDisk(ctx)
+				continue
+			}
+
+			err := ep.deliverWithRetry(ctx, p.body)
+			var rejected permanentError
+			switch {
+			case errors.As(err, &rejected):
+				ep.logger.Error("Webhook payload rejected by the endpoint, moving it to the dead letter folder", slog.Any("error", err))
+				if err = ep.disk.deadLetter(p.body); err != nil {
+					ep.logger.Error("Failed to store rejected webhook payload", slog.Any("error", err))
+				}
+			case err != nil:
+				ep.logger.Warn("Webhook delivery failed, storing payload on disk", slog.Any("error", err))
+				ep.store(p)
+			}
+		case <-ticker.C:
+			ep.flushDisk(ctx)
+		}
+	}
+}
+
+func (ep *endpoint) store(p queuedPayload) {
+	if err := ep.disk.push(p.body, p.queuedAt); err != nil {
+		ep.logger.Error("Failed to store webhook payload on disk", slog.Any("error", err))
+	}
+}
+
+// flushDisk delivers stored payloads oldest first, it stops on the first failure to retry them later. Payloads
+// rejected by the endpoint are moved to the dead letter folder.
+func (ep *endpoint) flushDisk(ctx context.Context) {
+	for ctx.Err() == nil {
+		file, body, err := ep.disk.peek()
+		if err != nil {
+			ep.logger.Error("Failed to read webhook disk queue", slog.Any("error", err))
+			return
+		}
+		if file == "" {
+			return
+		}
+
+		err = ep.deliver(ctx, body)
+		var rejected permanentError
+		if errors.As(err, &rejected) {
+			ep.logger.Error("Stored webhook payload rejected by the endpoint, moving it to the dead letter folder", slog.String("file", file), slog.Any("error", err))
+			if err = ep.disk.moveToDeadLetter(file); err != nil {
+				ep.logger.Error("Failed to move rejected webhook payload", slog.Any("error", err))
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		ep.disk.remove(file)
+	}
+}
+
+func (ep *endpoint) deliverWithRetry(ctx context.Context, body []byte) error {
+	backoff := initialBackoff
+	var err error
+	for attempt := 0; attempt < ep.cfg.MaxRetries; attempt++ {
+		err = ep.deliver(ctx, body)
+		var rejected permanentError
+		if err == nil || errors.As(err, &rejected) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+
+	return err
+}
+
+func (ep *endpoint) deliver(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "koolo/"+config.Version)
+	for k, v := range ep.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if ep.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(ep.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := ep.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Client errors won't change by retrying, except timeouts and rate limits
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{statusCode: resp.StatusCode}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>", receivers should compare it with the
+// X-Koolo-Signature header and reject requests whose X-Koolo-Timestamp is too old, so they can't be replayed
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+)
+
+func newTestEndpoint(t *testing.T, handler http.HandlerFunc) *endpoint {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	n, err := NewNotifier([]config.WebhookEndpoint{{Name: "test", URL: server.URL, Secret: "secret"}}, t.TempDir(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n.endpoints[0]
+}
+
+func TestDeliverSignature(t *testing.T) {
+	var timestamp, signature string
+	var body []byte
+	ep := newTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		timestamp = r.Header.Get(TimestampHeader)
+		signature = r.Header.Get(SignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+	})
+
+	if err := ep.deliver(context.Background(), []byte(`{"type":"test"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Fatalf("invalid timestamp header %q", timestamp)
+	}
+	if signature != "sha256="+Sign("secret", timestamp, body) {
+		t.Errorf("signature %s doesn't match the body and timestamp", signature)
+	}
+	if signature == "sha256="+Sign("secret", "0", body) {
+		t.Error("signature doesn't depend on the timestamp")
+	}
+}
+
+func TestDeliverPermanentErrors(t *testing.T) {
+	for status, permanent := range map[int]bool{
+		http.StatusBadRequest:          true,
+		http.StatusUnauthorized:        true,
+		http.StatusNotFound:            true,
+		http.StatusRequestTimeout:      false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+		http.StatusBadGateway:          false,
+	} {
+		ep := newTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})
+
+		err := ep.deliver(context.Background(), []byte("{}"))
+		var rejected permanentError
+		if err == nil || errors.As(err, &rejected) != permanent {
+			t.Errorf("status %d: expected permanent %v, got %v", status, permanent, err)
+		}
+	}
+}
+
+func TestDeliverWithRetryStopsOnPermanentError(t *testing.T) {
+	requests := 0
+	ep := newTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+
+	if err := ep.deliverWithRetry(context.Background(), []byte("{}")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if requests != 1 {
+		t.Errorf("a rejected payload must not be retried, got %d requests", requests)
+	}
+}
+
+func TestFlushDisk(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	ep := newTestEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "rejected" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+	})
+
+	now := time.Now()
+	// Pushed out of order, they are delivered by queue time
+	for _, p := range []queuedPayload{
+		{body: []byte("third"), queuedAt: now.Add(3 * time.Second)},
+		{body: []byte("first"), queuedAt: now.Add(time.Second)},
+		{body: []byte("rejected"), queuedAt: now.Add(2 * time.Second)},
+	} {
+		if err := ep.disk.push(p.body, p.queuedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ep.flushDisk(context.Background())
+
+	if len(received) != 2 || received[0] != "first" || received[1] != "third" {
+		t.Errorf("unexpected delivery order %v", received)
+	}
+	if !ep.disk.empty() {
+		t.Error("the queue should be empty")
+	}
+	dead, _ := filepath.Glob(filepath.Join(ep.disk.dir, deadLetterDir, "*.json"))
+	if len(dead) != 1 {
+		t.Fatalf("expected the rejected payload in the dead letter folder, got %v", dead)
+	}
+	if body, _ := os.ReadFile(dead[0]); string(body) != "rejected" {
+		t.Errorf("unexpected dead letter %s", body)
+	}
+}
+
+func TestDiskQueueCap(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.maxFiles = 3
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if err = q.push([]byte(strconv.Itoa(i)), now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := sortedFiles(q.dir)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 payloads, got %d", len(files))
+	}
+	if _, body, _ := q.peek(); string(body) != "2" {
+		t.Errorf("the oldest payloads should be dropped first, oldest kept is %s", body)
+	}
+}
+
+func TestAcceptsDefaultEvents(t *testing.T) {
+	ep := &endpoint{}
+	if !ep.accepts(event.RunStarted(event.Text("char", "run started"), "pindleskin")) {
+		t.Error("events must be sent by default")
+	}
+	decision := event.ItemDecided(event.Text("char", "ignore"), event.ItemDecision{Action: event.ActionIgnore})
+	if ep.accepts(decision) {
+		t.Error("item decisions must not be sent by default")
+	}
+
+	ep.cfg.Events = []string{"ItemDecision"}
+	if !ep.accepts(decision) {
+		t.Error("item decisions must be sent when enabled")
+	}
+}
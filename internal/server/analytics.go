@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/bot"
+)
+
+// analyticsResponse wraps the computed analytics with the parameters used to compute them
+type analyticsResponse struct {
+	Supervisor string `json:"supervisor"`
+	Source     string `json:"source"`
+	bot.Analytics
+}
+
+// analytics computes per run efficiency metrics. By default it uses the in-memory stats of the current session,
+// source=history uses the persisted history instead, accepting the same from/to filters as /api/history.
+func (s *HttpServer) analytics(w http.ResponseWriter, r *http.Request) {
+	supervisor := strings.TrimSpace(r.URL.Query().Get("supervisor"))
+	source := strings.TrimSpace(r.URL.Query().Get("source"))
+	if source == "" {
+		source = "session"
+	}
+
+	var games []bot.GameStats
+	switch source {
+	case "session":
+		for _, sup := range s.manager.AvailableSupervisors() {
+			if supervisor != "" && !strings.EqualFold(supervisor, sup) {
+				continue
+			}
+			games = append(games, s.manager.Status(sup).Games...)
+		}
+	case "history":
+		q, err := parseHistoryQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Analytics need the whole game/run sequence, only supervisor and date range filters apply
+		q.RunName, q.Reason, q.Types, q.Limit = "", "", nil, 0
+
+		records, err := s.history.Query(q)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to query history: %v", err), http.StatusInternalServerError)
+			return
+		}
+		games = bot.GamesFromHistory(records)
+	default:
+		http.Error(w, "invalid source, expected session or history", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analyticsResponse{
+		Supervisor: supervisor,
+		Source:     source,
+		Analytics:  bot.AnalyzeGames(games, time.Now()),
+	})
+}
+
+func (s *HttpServer) analyticsPage(w http.ResponseWriter, r *http.Request) {
+	s.templates.ExecuteTemplate(w, "analytics.gohtml", AnalyticsData{
+		Supervisors: s.manager.AvailableSupervisors(),
+		Supervisor:  r.URL.Query().Get("supervisor"),
+	})
+}
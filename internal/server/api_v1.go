@@ -0,0 +1,460 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/bot"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+	"gopkg.in/yaml.v3"
+)
+
+// startErrorWindow is how long a start request waits for the supervisor to fail before answering 202 Accepted,
+// starting the game takes minutes and the supervisor keeps running in the background
+const startErrorWindow = 2 * time.Second
+
+// redactedSecret replaces the credentials in the config responses, sending it back in a patch keeps the current value
+const redactedSecret = "********"
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// apiSupervisor is the /api/v1 representation of a supervisor, field names are part of the API contract
+type apiSupervisor struct {
+	Name                string               `json:"name"`
+	Status              bot.SupervisorStatus `json:"status"`
+	StartedAt           *time.Time           `json:"startedAt,omitempty"`
+	Details             string               `json:"details,omitempty"`
+	IsCompanionFollower bool                 `json:"isCompanionFollower"`
+	Games               int                  `json:"games"`
+	Deaths              int                  `json:"deaths"`
+	Chickens            int                  `json:"chickens"`
+	Errors              int                  `json:"errors"`
+	Drops               int                  `json:"drops"`
+}
+
+type apiStats struct {
+	apiSupervisor
+	GameHistory []apiGame `json:"gameHistory"`
+}
+
+type apiGame struct {
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Reason     event.FinishReason `json:"reason,omitempty"`
+	Runs       []apiRun           `json:"runs"`
+}
+
+type apiRun struct {
+	Name        string             `json:"name"`
+	StartedAt   time.Time          `json:"startedAt"`
+	FinishedAt  *time.Time         `json:"finishedAt,omitempty"`
+	Reason      event.FinishReason `json:"reason,omitempty"`
+	Items       int                `json:"items"`
+	PotionsUsed int                `json:"potionsUsed"`
+}
+
+type apiCharacter struct {
+	Class           string `json:"class"`
+	Level           int    `json:"level"`
+	Experience      int    `json:"experience"`
+	LastExp         int    `json:"lastExperience"`
+	NextExp         int    `json:"nextExperience"`
+	Difficulty      string `json:"difficulty"`
+	Area            string `json:"area"`
+	Life            int    `json:"life"`
+	MaxLife         int    `json:"maxLife"`
+	Mana            int    `json:"mana"`
+	MaxMana         int    `json:"maxMana"`
+	MagicFind       int    `json:"magicFind"`
+	GoldFind        int    `json:"goldFind"`
+	FireResist      int    `json:"fireResist"`
+	ColdResist      int    `json:"coldResist"`
+	LightningResist int    `json:"lightningResist"`
+	PoisonResist    int    `json:"poisonResist"`
+	Gold            int    `json:"gold"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (s *HttpServer) registerAPIv1() {
+	http.HandleFunc("GET /api/v1/openapi.yaml", s.apiOpenAPI)
+	http.HandleFunc("GET /api/v1/supervisors", s.apiListSupervisors)
+	http.HandleFunc("GET /api/v1/supervisors/{name}", s.apiGetSupervisor)
+	http.HandleFunc("GET /api/v1/supervisors/{name}/stats", s.apiGetStats)
+	http.HandleFunc("GET /api/v1/supervisors/{name}/character", s.apiGetCharacter)
+	http.HandleFunc("GET /api/v1/supervisors/{name}/config", s.apiGetConfig)
+	http.HandleFunc("PATCH /api/v1/supervisors/{name}/config", s.apiPatchConfig)
+	http.HandleFunc("POST /api/v1/supervisors/{name}/start", s.apiStart)
+	http.HandleFunc("POST /api/v1/supervisors/{name}/stop", s.apiStop)
+	http.HandleFunc("POST /api/v1/supervisors/{name}/pause", s.apiPause)
+	http.HandleFunc("POST /api/v1/supervisors/{name}/resume", s.apiResume)
+}
+
+func (s *HttpServer) apiOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+func (s *HttpServer) apiListSupervisors(w http.ResponseWriter, _ *http.Request) {
+	status := s.getStatusData().Status
+
+	names := s.manager.AvailableSupervisors()
+	sort.Strings(names)
+	supervisors := make([]apiSupervisor, 0, len(names))
+	for _, name := range names {
+		supervisors = append(supervisors, newAPISupervisor(name, status[name]))
+	}
+
+	writeJSON(w, http.StatusOK, supervisors)
+}
+
+func (s *HttpServer) apiGetSupervisor(w http.ResponseWriter, r *http.Request) {
+	name, stats, ok := s.apiSupervisorStats(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPISupervisor(name, stats))
+}
+
+func (s *HttpServer) apiGetStats(w http.ResponseWriter, r *http.Request) {
+	name, stats, ok := s.apiSupervisorStats(w, r)
+	if !ok {
+		return
+	}
+
+	resp := apiStats{apiSupervisor: newAPISupervisor(name, stats), GameHistory: make([]apiGame, 0, len(stats.Games))}
+	for _, g := range stats.Games {
+		game := apiGame{StartedAt: g.StartedAt, FinishedAt: optionalTime(g.FinishedAt), Reason: g.Reason, Runs: make([]apiRun, 0, len(g.Runs))}
+		for _, run := range g.Runs {
+			game.Runs = append(game.Runs, apiRun{
+				Name:        run.Name,
+				StartedAt:   run.StartedAt,
+				FinishedAt:  optionalTime(run.FinishedAt),
+				Reason:      run.Reason,
+				Items:       len(run.Items),
+				PotionsUsed: len(run.UsedPotions),
+			})
+		}
+		resp.GameHistory = append(resp.GameHistory, game)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *HttpServer) apiGetCharacter(w http.ResponseWriter, r *http.Request) {
+	_, stats, ok := s.apiSupervisorStats(w, r)
+	if !ok {
+		return
+	}
+
+	// Character data is only available while the supervisor is running
+	if stats.SupervisorStatus == "" || stats.SupervisorStatus == bot.NotStarted {
+		writeAPIError(w, http.StatusConflict, errors.New("supervisor is not running"))
+		return
+	}
+
+	ui := stats.UI
+	writeJSON(w, http.StatusOK, apiCharacter{
+		Class:           ui.Class,
+		Level:           ui.Level,
+		Experience:      ui.Experience,
+		LastExp:         ui.LastExp,
+		NextExp:         ui.NextExp,
+		Difficulty:      ui.Difficulty,
+		Area:            ui.Area,
+		Life:            ui.Life,
+		MaxLife:         ui.MaxLife,
+		Mana:            ui.Mana,
+		MaxMana:         ui.MaxMana,
+		MagicFind:       ui.MagicFind,
+		GoldFind:        ui.GoldFind,
+		FireResist:      ui.FireResist,
+		ColdResist:      ui.ColdResist,
+		LightningResist: ui.LightningResist,
+		PoisonResist:    ui.PoisonResist,
+		Gold:            ui.Gold,
+	})
+}
+
+func (s *HttpServer) apiStart(w http.ResponseWriter, r *http.Request) {
+	name, stats, ok := s.apiSupervisorStats(w, r)
+	if !ok {
+		return
+	}
+
+	if isRunning(stats) {
+		writeAPIError(w, http.StatusConflict, fmt.Errorf("supervisor %s is already running", name))
+		return
+	}
+	if !s.canStart(name) {
+		writeAPIError(w, http.StatusConflict, errors.New("another supervisor using TokenAuth is starting, try again later"))
+		return
+	}
+
+	// Start blocks for the whole supervisor life, only errors happening right away are reported
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.manager.Start(name, false)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+	case <-time.After(startErrorWindow):
+	}
+
+	writeJSON(w, http.StatusAccepted, newAPISupervisor(name, s.manager.Status(name)))
+}
+
+func (s *HttpServer) apiStop(w http.ResponseWriter, r *http.Request) {
+	name, _, ok := s.apiSupervisorStats(w, r)
+	if !ok {
+		return
+	}
+
+	s.manager.Stop(name)
+	writeJSON(w, http.StatusOK, newAPISupervisor(name, s.manager.Status(name)))
+}
+
+func (s *HttpServer) apiPause(w http.ResponseWriter, r *http.Request) {
+	s.apiSetPaused(w, r, true)
+}
+
+func (s *HttpServer) apiResume(w http.ResponseWriter, r *http.Request) {
+	s.apiSetPaused(w, r, false)
+}
+
+// apiSetPaused is idempotent, the manager only exposes a toggle so it's only called when the state has to change
+func (s *HttpServer) apiSetPaused(w http.ResponseWriter, r *http.Request, pause bool) {
+	name, stats, ok := s.apiSupervisorStats(w, r)
+	if !ok {
+		return
+	}
+
+	if !isRunning(stats) {
+		writeAPIError(w, http.StatusConflict, fmt.Errorf("supervisor %s is not running", name))
+		return
+	}
+
+	if (stats.SupervisorStatus == bot.Paused) != pause {
+		s.manager.TogglePause(name)
+	}
+
+	writeJSON(w, http.StatusOK, newAPISupervisor(name, s.manager.Status(name)))
+}
+
+func (s *HttpServer) apiGetConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, ok := s.apiCharacterConfig(w, r)
+	if !ok {
+		return
+	}
+
+	doc, err := configDocument(cfg)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// apiPatchConfig applies a JSON merge patch (RFC 7396) using the same keys as config.yaml, the result is validated
+// by decoding it strictly into config.CharacterCfg before being saved. A running supervisor switches to the new config
+// at its next game boundary, the current game keeps the old one.
+func (s *HttpServer) apiPatchConfig(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	cfg, ok := s.apiCharacterConfig(w, r)
+	if !ok {
+		return
+	}
+
+	var patch map[string]any
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&patch); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON merge patch: %w", err))
+		return
+	}
+
+	doc, err := configDocument(cfg)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	mergePatch(doc, patch)
+
+	// Credentials still redacted were not changed by the patch, the stored values are kept
+	keepPassword := doc["password"] == redactedSecret
+	keepAuthToken := doc["authToken"] == redactedSecret
+	if keepPassword {
+		delete(doc, "password")
+	}
+	if keepAuthToken {
+		delete(doc, "authToken")
+	}
+	newCfg, err := decodeCharacterConfig(doc)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if keepPassword {
+		newCfg.Password = cfg.Password
+	}
+	if keepAuthToken {
+		newCfg.AuthToken = cfg.AuthToken
+	}
+
+	if err = s.manager.SaveSupervisorConfig(name, newCfg); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	saved, found := config.GetCharacter(name)
+	if !found {
+		writeAPIError(w, http.StatusInternalServerError, errors.New("config was saved but could not be loaded back"))
+		return
+	}
+	if doc, err = configDocument(saved); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.logger.Info("Supervisor config updated from the API", "supervisor", name)
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// apiSupervisorStats resolves the {name} path value, it writes a 404 if the supervisor doesn't exist
+func (s *HttpServer) apiSupervisorStats(w http.ResponseWriter, r *http.Request) (string, bot.Stats, bool) {
+	name := r.PathValue("name")
+	if !slices.Contains(s.manager.AvailableSupervisors(), name) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("supervisor %s not found", name))
+		return name, bot.Stats{}, false
+	}
+
+	return name, s.getStatusData().Status[name], true
+}
+
+func (s *HttpServer) apiCharacterConfig(w http.ResponseWriter, r *http.Request) (*config.CharacterCfg, bool) {
+	name := r.PathValue("name")
+	cfg, found := config.GetCharacter(name)
+	if !found || name == "template" {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("supervisor %s not found", name))
+		return nil, false
+	}
+
+	return cfg, true
+}
+
+func newAPISupervisor(name string, stats bot.Stats) apiSupervisor {
+	status := stats.SupervisorStatus
+	if status == "" {
+		status = bot.NotStarted
+	}
+
+	sup := apiSupervisor{
+		Name:                name,
+		Status:              status,
+		Details:             stats.Details,
+		IsCompanionFollower: stats.IsCompanionFollower,
+		Games:               stats.TotalGames(),
+		Deaths:              stats.TotalDeaths(),
+		Chickens:            stats.TotalChickens(),
+		Errors:              stats.TotalErrors(),
+		Drops:               len(stats.Drops),
+	}
+	sup.StartedAt = optionalTime(stats.StartedAt)
+
+	return sup
+}
+
+func isRunning(stats bot.Stats) bool {
+	return stats.SupervisorStatus != "" && stats.SupervisorStatus != bot.NotStarted && stats.SupervisorStatus != bot.Crashed
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// configDocument converts the config to a generic document keyed like config.yaml, credentials are redacted
+func configDocument(cfg *config.CharacterCfg) (map[string]any, error) {
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]any)
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"password", "authToken"} {
+		if v, ok := doc[key].(string); ok && v != "" {
+			doc[key] = redactedSecret
+		}
+	}
+
+	return doc, nil
+}
+
+func decodeCharacterConfig(doc map[string]any) (*config.CharacterCfg, error) {
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config.CharacterCfg{}
+	if err = config.DecodeCharacterConfig("config", b, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// mergePatch applies a JSON merge patch, null removes the key and objects are merged recursively
+func mergePatch(target, patch map[string]any) {
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+
+		if patchObj, ok := v.(map[string]any); ok {
+			targetObj, ok := target[k].(map[string]any)
+			if !ok {
+				targetObj = make(map[string]any)
+				target[k] = targetObj
+			}
+			mergePatch(targetObj, patchObj)
+			continue
+		}
+
+		target[k] = v
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
@@ -0,0 +1,36 @@
+// Adds the CSRF token to every request changing state, required when the web UI authentication is enabled
+(function () {
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)koolo_csrf=([^;]+)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    const originalFetch = window.fetch;
+    window.fetch = function (input, init) {
+        init = init || {};
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        const token = csrfToken();
+        if (token && !['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            headers.set('X-CSRF-Token', token);
+            init.headers = headers;
+        }
+        return originalFetch.call(this, input, init);
+    };
+
+    document.addEventListener('submit', function (e) {
+        const form = e.target;
+        const token = csrfToken();
+        if (!token || !(form instanceof HTMLFormElement) || form.method.toUpperCase() !== 'POST') {
+            return;
+        }
+        let input = form.querySelector('input[name="csrf_token"]');
+        if (!input) {
+            input = document.createElement('input');
+            input.type = 'hidden';
+            input.name = 'csrf_token';
+            form.appendChild(input);
+        }
+        input.value = token;
+    }, true);
+})();
@@ -223,7 +223,7 @@ function setupEventListeners(card, key) {
             } else { // Paused
                 action = 'togglePause';
             }
-            fetch(`/${action}?characterName=${key}`)
+            fetch(`/${action}?characterName=${key}`, { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     updateDashboard(data);
@@ -233,7 +233,7 @@ function setupEventListeners(card, key) {
     }
     if (stopBtn) {
         stopBtn.addEventListener('click', function () {
-            fetch(`/stop?characterName=${key}`).then(() => fetchInitialData());
+            fetch(`/stop?characterName=${key}`, { method: 'POST' }).then(() => fetchInitialData());
         });
     }
 }
@@ -867,7 +867,7 @@ async function reloadConfig() {
     icon.classList.add('rotate');
 
     try {
-        const response = await fetch('/api/reload-config');
+        const response = await fetch('/api/reload-config', { method: 'POST' });
         if (!response.ok) {
             throw new Error('Failed to reload config');
         }
@@ -316,8 +316,15 @@ function createCopyDataButton() {
     });
 }
 
+function downloadFixture() {
+    const urlParams = new URLSearchParams(window.location.search);
+    const characterName = urlParams.get('characterName') || 'nullref';
+    window.location.href = `/debug-fixture?characterName=${encodeURIComponent(characterName)}`;
+}
+
 // Event Listeners
 setIntervalBtn.addEventListener('click', setRefreshInterval);
+document.getElementById('download-fixture-btn').addEventListener('click', downloadFixture);
 expandAllBtn.addEventListener('click', toggleExpandAll);
 searchInput.addEventListener('input', () => performSearch());
 searchNextBtn.addEventListener('click', goToNextSearchResult);
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionCookie = "koolo_session"
+	// csrfCookie is readable from JS, assets/js/csrf.js sends it back in the X-CSRF-Token header or csrf_token field
+	csrfCookie = "koolo_csrf"
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+
+	defaultSessionHours = 24
+
+	// An address can't log in for loginBlockDuration after loginMaxFailures failed attempts in a row
+	loginMaxFailures   = 5
+	loginBlockDuration = 15 * time.Minute
+)
+
+type session struct {
+	csrf      string
+	expiresAt time.Time
+}
+
+// sessionStore keeps the UI sessions in memory, logging in again is required after a restart
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]session)}
+}
+
+func (ss *sessionStore) create(ttl time.Duration) (string, session, error) {
+	id, err := randomToken(32)
+	if err != nil {
+		return "", session{}, err
+	}
+	csrf, err := randomToken(32)
+	if err != nil {
+		return "", session{}, err
+	}
+
+	sess := session{csrf: csrf, expiresAt: time.Now().Add(ttl)}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.sessions[id] = sess
+	// Housekeeping, there are only a handful of sessions so it's cheap to do it here
+	for k, v := range ss.sessions {
+		if time.Now().After(v.expiresAt) {
+			delete(ss.sessions, k)
+		}
+	}
+
+	return id, sess, nil
+}
+
+func (ss *sessionStore) get(id string) (session, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	sess, found := ss.sessions[id]
+	if !found || time.Now().After(sess.expiresAt) {
+		delete(ss.sessions, id)
+		return session{}, false
+	}
+
+	return sess, true
+}
+
+func (ss *sessionStore) delete(id string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.sessions, id)
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// loginLimiter slows down password guessing, failed logins are counted by remote address
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]loginFailures
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: make(map[string]loginFailures)}
+}
+
+func (l *loginLimiter) allowed(addr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, found := l.failures[addr]
+	if found && time.Since(f.last) > loginBlockDuration {
+		delete(l.failures, addr)
+		return true
+	}
+
+	return f.count < loginMaxFailures
+}
+
+func (l *loginLimiter) failed(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f := l.failures[addr]
+	l.failures[addr] = loginFailures{count: f.count + 1, last: time.Now()}
+}
+
+func (l *loginLimiter) succeeded(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, addr)
+}
+
+// HashPassword returns the bcrypt hash to be stored in server.auth.passwordHash
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// NewAPIToken returns a random bearer token and the hash to be stored in server.auth.tokens
+func NewAPIToken() (token, hash string, err error) {
+	token, err = randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, HashAPIToken(token), nil
+}
+
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// requireAuth wraps every handler when server.auth is enabled. API clients authenticate with a bearer token, the UI
+// with a session cookie, and requests changing state from the UI must include the session CSRF token.
+func (s *HttpServer) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Koolo.Server.Auth.Enabled || isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if token, found := bearerToken(r); found {
+			if validAPIToken(token) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			s.logger.Warn("Rejected request with invalid API token", slog.String("path", r.URL.Path), slog.String("remote", remoteIP(r)))
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			s.unauthorized(w, r)
+			return
+		}
+		sess, found := s.sessions.get(cookie.Value)
+		if !found {
+			s.unauthorized(w, r)
+			return
+		}
+
+		if !isSafeMethod(r.Method) && !validCSRF(r, sess.csrf) {
+			s.logger.Warn("Rejected request with invalid CSRF token", slog.String("path", r.URL.Path), slog.String("remote", remoteIP(r)))
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unauthorized sends the browser to the login page, API and WebSocket clients get a plain 401
+func (s *HttpServer) unauthorized(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/ws") && r.URL.Path != "/metrics" {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="koolo"`)
+	http.Error(w, "authentication required", http.StatusUnauthorized)
+}
+
+func (s *HttpServer) login(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.Query().Get("next")
+	// Only local redirects, otherwise the login page could be used to send users anywhere
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+
+	if !config.Koolo.Server.Auth.Enabled {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		s.templates.ExecuteTemplate(w, "login.gohtml", LoginData{Next: next})
+		return
+	}
+
+	addr := remoteIP(r)
+	if !s.loginLimiter.allowed(addr) {
+		s.logger.Warn("Login blocked after too many failed attempts", slog.String("remote", addr))
+		w.WriteHeader(http.StatusTooManyRequests)
+		s.templates.ExecuteTemplate(w, "login.gohtml", LoginData{Next: next, ErrorMessage: "Too many failed attempts, try again later"})
+		return
+	}
+
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+	if !validCredentials(username, password) {
+		s.loginLimiter.failed(addr)
+		s.logger.Warn("Failed login attempt", slog.String("username", username), slog.String("remote", addr))
+		w.WriteHeader(http.StatusUnauthorized)
+		s.templates.ExecuteTemplate(w, "login.gohtml", LoginData{Next: next, ErrorMessage: "Invalid username or password"})
+		return
+	}
+	s.loginLimiter.succeeded(addr)
+
+	hours := config.Koolo.Server.Auth.SessionHours
+	if hours <= 0 {
+		hours = defaultSessionHours
+	}
+	ttl := time.Duration(hours) * time.Hour
+
+	id, sess, err := s.sessions.create(ttl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	secure := config.Koolo.Server.TLS.Enabled
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", MaxAge: int(ttl.Seconds()), HttpOnly: true, Secure: secure, SameSite: http.SameSiteStrictMode})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: sess.csrf, Path: "/", MaxAge: int(ttl.Seconds()), Secure: secure, SameSite: http.SameSiteStrictMode})
+
+	s.logger.Info("User logged in", slog.String("username", username), slog.String("remote", addr))
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (s *HttpServer) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		s.sessions.delete(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: "", Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func isPublicPath(path string) bool {
+	return path == "/login" || path == "/logout" || strings.HasPrefix(path, "/assets/")
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// postOnly rejects the requests to handlers changing state that are not POST, GET requests are not checked for CSRF
+func postOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+
+	return strings.TrimSpace(header[7:]), true
+}
+
+func validAPIToken(token string) bool {
+	hash := []byte(HashAPIToken(token))
+	valid := false
+	for _, t := range config.Koolo.Server.Auth.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(t.Hash))) == 1 {
+			valid = true
+		}
+	}
+
+	return valid
+}
+
+func validCredentials(username, password string) bool {
+	auth := config.Koolo.Server.Auth
+	if auth.PasswordHash == "" {
+		return false
+	}
+
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(auth.Username)) == 1
+	// Always run bcrypt so the response time doesn't tell if the username exists
+	passOK := bcrypt.CompareHashAndPassword([]byte(auth.PasswordHash), []byte(password)) == nil
+
+	return userOK && passOK
+}
+
+func validCSRF(r *http.Request, expected string) bool {
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
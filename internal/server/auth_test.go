@@ -0,0 +1,186 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+func useAuthConfig(t *testing.T, enabled bool, tokens ...config.APIToken) {
+	t.Helper()
+
+	previous := config.Koolo
+	t.Cleanup(func() { config.Koolo = previous })
+
+	config.Koolo = &config.KooloCfg{}
+	config.Koolo.Server.Auth.Enabled = enabled
+	config.Koolo.Server.Auth.Tokens = tokens
+}
+
+func TestSessionStore(t *testing.T) {
+	ss := newSessionStore()
+
+	id, sess, err := ss.create(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, found := ss.get(id); !found || got.csrf != sess.csrf || sess.csrf == "" {
+		t.Errorf("expected the session with its CSRF token, got %+v", got)
+	}
+
+	ss.delete(id)
+	if _, found := ss.get(id); found {
+		t.Error("deleted session still found")
+	}
+
+	expired, _, err := ss.create(-time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := ss.get(expired); found {
+		t.Error("expired session still found")
+	}
+}
+
+func TestValidAPIToken(t *testing.T) {
+	token, hash, err := NewAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useAuthConfig(t, true, config.APIToken{Name: "grafana", Hash: strings.ToUpper(hash)})
+
+	if !validAPIToken(token) {
+		t.Error("expected the token to be valid")
+	}
+	if validAPIToken(token + "x") {
+		t.Error("expected a different token to be rejected")
+	}
+	if validAPIToken(hash) {
+		t.Error("the hash must not be accepted as a token")
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	token, hash, err := NewAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &HttpServer{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), sessions: newSessionStore()}
+	sessionID, sess, err := s.sessions.create(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		disabled bool
+		method   string
+		path     string
+		token    string
+		session  string
+		csrf     string
+		form     url.Values
+		expected int
+	}{
+		{name: "auth disabled", disabled: true, method: http.MethodPost, path: "/start", expected: http.StatusOK},
+		{name: "public path", method: http.MethodGet, path: "/assets/js/csrf.js", expected: http.StatusOK},
+		{name: "valid token", method: http.MethodPost, path: "/api/v1/supervisors", token: token, expected: http.StatusOK},
+		{name: "invalid token", method: http.MethodGet, path: "/api/v1/supervisors", token: "wrong", expected: http.StatusUnauthorized},
+		{name: "page without session", method: http.MethodGet, path: "/", expected: http.StatusSeeOther},
+		{name: "api without session", method: http.MethodGet, path: "/api/history", expected: http.StatusUnauthorized},
+		{name: "unknown session", method: http.MethodGet, path: "/", session: "unknown", expected: http.StatusSeeOther},
+		{name: "session", method: http.MethodGet, path: "/", session: sessionID, expected: http.StatusOK},
+		{name: "post without csrf", method: http.MethodPost, path: "/start", session: sessionID, expected: http.StatusForbidden},
+		{name: "post with wrong csrf", method: http.MethodPost, path: "/start", session: sessionID, csrf: "wrong", expected: http.StatusForbidden},
+		{name: "post with csrf header", method: http.MethodPost, path: "/start", session: sessionID, csrf: sess.csrf, expected: http.StatusOK},
+		{name: "post with csrf field", method: http.MethodPost, path: "/config", session: sessionID, form: url.Values{csrfField: {sess.csrf}}, expected: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useAuthConfig(t, !tc.disabled, config.APIToken{Name: "test", Hash: hash})
+
+			var body io.Reader
+			if tc.form != nil {
+				body = strings.NewReader(tc.form.Encode())
+			}
+			r := httptest.NewRequest(tc.method, tc.path, body)
+			if tc.form != nil {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			if tc.session != "" {
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: tc.session})
+			}
+			if tc.csrf != "" {
+				r.Header.Set(csrfHeader, tc.csrf)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.expected {
+				t.Errorf("expected status %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestPostOnly(t *testing.T) {
+	handler := postOnly(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for method, expected := range map[string]int{
+		http.MethodGet:  http.StatusMethodNotAllowed,
+		http.MethodHead: http.StatusMethodNotAllowed,
+		http.MethodPost: http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, "/start?characterName=test", nil))
+		if w.Code != expected {
+			t.Errorf("%s: expected status %d, got %d", method, expected, w.Code)
+		}
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter()
+
+	for i := 0; i < loginMaxFailures; i++ {
+		if !l.allowed("10.0.0.1") {
+			t.Fatalf("blocked after %d failures", i)
+		}
+		l.failed("10.0.0.1")
+	}
+	if l.allowed("10.0.0.1") {
+		t.Error("expected the address to be blocked")
+	}
+	if !l.allowed("10.0.0.2") {
+		t.Error("other addresses must not be blocked")
+	}
+
+	// The block expires
+	l.failures["10.0.0.1"] = loginFailures{count: loginMaxFailures, last: time.Now().Add(-loginBlockDuration - time.Second)}
+	if !l.allowed("10.0.0.1") {
+		t.Error("expected the block to expire")
+	}
+
+	l.failed("10.0.0.2")
+	l.succeeded("10.0.0.2")
+	if _, found := l.failures["10.0.0.2"]; found {
+		t.Error("a successful login must reset the failures")
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+// exportBundle downloads the character config bundle, format is zip (default) or tar
+func (s *HttpServer) exportBundle(w http.ResponseWriter, r *http.Request) {
+	supervisor := r.URL.Query().Get("supervisor")
+	if _, found := config.GetCharacter(supervisor); !found {
+		http.Error(w, fmt.Sprintf("supervisor %s not found", supervisor), http.StatusNotFound)
+		return
+	}
+
+	format := config.BundleFormat(r.URL.Query().Get("format"))
+	ext := "zip"
+	contentType := "application/zip"
+	switch format {
+	case "", config.BundleZip:
+		format = config.BundleZip
+	case config.BundleTar:
+		ext = "tar.gz"
+		contentType = "application/gzip"
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %s", format), http.StatusBadRequest)
+		return
+	}
+
+	fileName := fmt.Sprintf("koolo-%s-%s.%s", supervisor, time.Now().Format("2006-01-02"), ext)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	if err := config.ExportBundle(supervisor, format, w); err != nil {
+		// Headers are already sent at this point, the download will be incomplete
+		s.logger.Error("Error exporting config bundle", slog.String("supervisor", supervisor), slog.Any("error", err))
+	}
+}
+
+// importBundle creates a new character from an uploaded bundle, the secrets stripped on export are taken from the form
+func (s *HttpServer) importBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file, _, err := r.FormFile("bundle")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("missing bundle file: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	name := r.FormValue("name")
+	opts := config.ImportOptions{
+		Secrets: config.BundleSecrets{
+			Password:  r.FormValue("password"),
+			AuthToken: r.FormValue("authToken"),
+		},
+		ReplaceSettings: r.FormValue("replaceSettings") != "",
+	}
+	// Kept out of the config file when the vault is available
+	if !config.DefaultVault.Locked() {
+		opts.SecretProvider = config.SecretProviderVault
+	}
+	manifest, err := config.ImportBundle(name, file, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.logger.Info("Config bundle imported", slog.String("supervisor", name), slog.String("from", manifest.Character))
+	http.Redirect(w, r, "/supervisorSettings?supervisor="+url.QueryEscape(name), http.StatusSeeOther)
+}
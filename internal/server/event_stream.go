@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+)
+
+// EventStream pushes every event from the event bus to the connected WebSocket clients as typed JSON
+type EventStream struct {
+	mu      sync.Mutex
+	clients map[*eventClient]bool
+	logger  *slog.Logger
+}
+
+type eventClient struct {
+	conn        *websocket.Conn
+	send        chan []byte
+	supervisors []string
+	types       []string
+}
+
+func NewEventStream(logger *slog.Logger) *EventStream {
+	return &EventStream{
+		clients: make(map[*eventClient]bool),
+		logger:  logger,
+	}
+}
+
+// Handle must be registered on the event.Listener, slow clients are disconnected instead of blocking the bus
+func (es *EventStream) Handle(_ context.Context, e event.Event) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if len(es.clients) == 0 {
+		return nil
+	}
+
+	msg, err := json.Marshal(event.NewEnvelope(e))
+	if err != nil {
+		return err
+	}
+
+	for client := range es.clients {
+		if !client.accepts(e) {
+			continue
+		}
+
+		select {
+		case client.send <- msg:
+		default:
+			es.logger.Warn("Event stream client is too slow, disconnecting it")
+			delete(es.clients, client)
+			close(client.send)
+		}
+	}
+
+	return nil
+}
+
+// eventStreamUpgrader only accepts the Koolo UI and the configured origins, the stream has the item, run and config
+// events and any page open in the browser could read it otherwise, even without auth
+var eventStreamUpgrader = websocket.Upgrader{CheckOrigin: allowedEventStreamOrigin}
+
+func allowedEventStreamOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	// Browsers always send it, other clients like scripts are not affected by the same-origin policy
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range config.Koolo.Server.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HandleWebSocket upgrades the connection, optional comma separated filters: supervisor and type (e.g. type=RunFinished,ItemStashed)
+func (es *EventStream) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := eventStreamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		es.logger.Error("Failed to upgrade connection to WebSocket", "error", err)
+		return
+	}
+
+	client := &eventClient{
+		conn:        conn,
+		send:        make(chan []byte, 256),
+		supervisors: splitFilter(r.URL.Query().Get("supervisor")),
+		types:       splitFilter(r.URL.Query().Get("type")),
+	}
+
+	es.mu.Lock()
+	es.clients[client] = true
+	es.mu.Unlock()
+
+	go es.writePump(client)
+	go es.readPump(client)
+}
+
+func (es *EventStream) writePump(client *eventClient) {
+	defer client.conn.Close()
+
+	for message := range client.send {
+		if err := client.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			es.unregister(client)
+			return
+		}
+	}
+
+	client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+}
+
+// readPump only detects disconnections, clients are not expected to send anything
+func (es *EventStream) readPump(client *eventClient) {
+	defer es.unregister(client)
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (es *EventStream) unregister(client *eventClient) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if _, ok := es.clients[client]; ok {
+		delete(es.clients, client)
+		close(client.send)
+	}
+}
+
+func (c *eventClient) accepts(e event.Event) bool {
+	if len(c.supervisors) > 0 {
+		found := false
+		for _, sup := range c.supervisors {
+			if strings.EqualFold(sup, e.Supervisor()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(c.types) == 0 {
+		return true
+	}
+	for _, t := range c.types {
+		if event.MatchesType(e, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitFilter(value string) []string {
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
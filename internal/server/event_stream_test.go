@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+func TestAllowedEventStreamOrigin(t *testing.T) {
+	useAuthConfig(t, false)
+	config.Koolo.Server.AllowedOrigins = []string{"http://grafana.local:3000/"}
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "not a browser", origin: "", allowed: true},
+		{name: "koolo UI", origin: "http://127.0.0.1:8087", allowed: true},
+		{name: "configured origin", origin: "http://grafana.local:3000", allowed: true},
+		{name: "other page", origin: "http://evil.example", allowed: false},
+		{name: "same host other port", origin: "http://127.0.0.1:8088", allowed: false},
+		{name: "configured host other scheme", origin: "https://grafana.local:3000", allowed: false},
+		{name: "invalid origin", origin: "http://%zz", allowed: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://127.0.0.1:8087/ws/events", nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if got := allowedEventStreamOrigin(r); got != tc.allowed {
+				t.Errorf("expected allowed %v, got %v", tc.allowed, got)
+			}
+		})
+	}
+}
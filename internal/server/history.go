@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+)
+
+// historyQuery returns persisted history records as JSON. Supported filters: supervisor, run, reason, type (comma separated),
+// from/to (RFC3339 or YYYY-MM-DD) and limit.
+func (s *HttpServer) historyQuery(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.history.Query(q)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to query history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"total": len(records), "records": records})
+}
+
+func parseHistoryQuery(r *http.Request) (history.Query, error) {
+	values := r.URL.Query()
+	q := history.Query{
+		Supervisor: strings.TrimSpace(values.Get("supervisor")),
+		RunName:    strings.TrimSpace(values.Get("run")),
+		Reason:     event.FinishReason(strings.TrimSpace(values.Get("reason"))),
+	}
+
+	if types := strings.TrimSpace(values.Get("type")); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			q.Types = append(q.Types, history.RecordType(strings.TrimSpace(t)))
+		}
+	}
+
+	var err error
+	if q.From, err = parseHistoryTime(values.Get("from"), false); err != nil {
+		return q, fmt.Errorf("invalid from: %w", err)
+	}
+	if q.To, err = parseHistoryTime(values.Get("to"), true); err != nil {
+		return q, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit: %s", limit)
+		}
+	}
+
+	return q, nil
+}
+
+// parseHistoryTime accepts RFC3339 timestamps or plain dates, plain "to" dates include the whole day
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	return t, nil
+}
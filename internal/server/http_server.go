@@ -34,6 +34,8 @@ import (
 	ctx "github.com/hectorgimenez/koolo/internal/context"
 	"github.com/hectorgimenez/koolo/internal/event"
 	"github.com/hectorgimenez/koolo/internal/game"
+	"github.com/hectorgimenez/koolo/internal/history"
+	"github.com/hectorgimenez/koolo/internal/metrics"
 	"github.com/hectorgimenez/koolo/internal/remote/droplog"
 	"github.com/hectorgimenez/koolo/internal/utils"
 	"github.com/hectorgimenez/koolo/internal/utils/winproc"
@@ -42,11 +44,16 @@ import (
 )
 
 type HttpServer struct {
-	logger    *slog.Logger
-	server    *http.Server
-	manager   *bot.SupervisorManager
-	templates *template.Template
-	wsServer  *WebSocketServer
+	logger           *slog.Logger
+	server           *http.Server
+	manager          *bot.SupervisorManager
+	templates        *template.Template
+	wsServer         *WebSocketServer
+	eventStream      *EventStream
+	history          *history.Store
+	metricsCollector *metrics.Collector
+	sessions         *sessionStore
+	loginLimiter     *loginLimiter
 }
 
 var (
@@ -183,7 +190,7 @@ func (s *HttpServer) BroadcastStatus() {
 	}
 }
 
-func New(logger *slog.Logger, manager *bot.SupervisorManager) (*HttpServer, error) {
+func New(logger *slog.Logger, manager *bot.SupervisorManager, historyStore *history.Store, metricsCollector *metrics.Collector) (*HttpServer, error) {
 	var templates *template.Template
 	helperFuncs := template.FuncMap{
 		"isInSlice": func(slice []stat.Resist, value string) bool {
@@ -219,9 +226,14 @@ func New(logger *slog.Logger, manager *bot.SupervisorManager) (*HttpServer, erro
 	}
 
 	return &HttpServer{
-		logger:    logger,
-		manager:   manager,
-		templates: templates,
+		logger:           logger,
+		manager:          manager,
+		templates:        templates,
+		eventStream:      NewEventStream(logger),
+		sessions:         newSessionStore(),
+		loginLimiter:     newLoginLimiter(),
+		history:          historyStore,
+		metricsCollector: metricsCollector,
 	}, nil
 }
 
@@ -556,46 +568,78 @@ func (s *HttpServer) getStatusData() IndexData {
 	}
 }
 
-func (s *HttpServer) Listen(port int) error {
+// Listen serves the UI on addr (host:port), an empty host listens on every interface
+func (s *HttpServer) Listen(addr string) error {
 	s.wsServer = NewWebSocketServer()
 	go s.wsServer.Run()
 	go s.BroadcastStatus()
 
 	http.HandleFunc("/", s.getRoot)
+	http.HandleFunc("/login", s.login)
+	http.HandleFunc("/logout", s.logout)
 	http.HandleFunc("/config", s.config)
 	http.HandleFunc("/supervisorSettings", s.characterSettings)
-	http.HandleFunc("/start", s.startSupervisor)
-	http.HandleFunc("/stop", s.stopSupervisor)
-	http.HandleFunc("/togglePause", s.togglePause)
+	http.HandleFunc("/start", postOnly(s.startSupervisor))
+	http.HandleFunc("/stop", postOnly(s.stopSupervisor))
+	http.HandleFunc("/togglePause", postOnly(s.togglePause))
 	http.HandleFunc("/debug", s.debugHandler)
 	http.HandleFunc("/debug-data", s.debugData)
+	http.HandleFunc("/debug-fixture", s.debugFixture)
 	http.HandleFunc("/drops", s.drops)
 	http.HandleFunc("/all-drops", s.allDrops)
+	http.HandleFunc("/pickit-coverage", s.pickitCoverage)
+	http.HandleFunc("/analytics", s.analyticsPage)
+	http.HandleFunc("/pickit-test", s.pickitTest)
+	http.HandleFunc("/item-decisions", s.itemDecisions)
 	http.HandleFunc("/export-drops", s.exportDrops)
-	http.HandleFunc("/open-droplogs", s.openDroplogs)
-	http.HandleFunc("/reset-droplogs", s.resetDroplogs)
+	http.HandleFunc("/open-droplogs", postOnly(s.openDroplogs))
+	http.HandleFunc("/reset-droplogs", postOnly(s.resetDroplogs))
 	http.HandleFunc("/process-list", s.getProcessList)
-	http.HandleFunc("/attach-process", s.attachProcess)
-	http.HandleFunc("/ws", s.wsServer.HandleWebSocket)      // Web socket
-	http.HandleFunc("/initial-data", s.initialData)         // Web socket data
-	http.HandleFunc("/api/reload-config", s.reloadConfig)   // New handler
-	http.HandleFunc("/api/companion-join", s.companionJoin) // Companion join handler
+	http.HandleFunc("/attach-process", postOnly(s.attachProcess))
+	http.HandleFunc("/ws", s.wsServer.HandleWebSocket)              // Web socket
+	http.HandleFunc("/ws/events", s.eventStream.HandleWebSocket)    // Typed event stream
+	http.HandleFunc("/initial-data", s.initialData)                 // Web socket data
+	http.HandleFunc("/api/reload-config", postOnly(s.reloadConfig)) // New handler
+	http.HandleFunc("/api/companion-join", s.companionJoin)         // Companion join handler
+	http.HandleFunc("/api/history", s.historyQuery)                 // Persistent run/game history
+	http.HandleFunc("/api/analytics", s.analytics)                  // Per run analytics
+	http.HandleFunc("/api/config/export", s.exportBundle)           // Character config bundle download
+	http.HandleFunc("/api/config/import", s.importBundle)           // Character config bundle upload
+	http.HandleFunc("/api/secrets/unlock", s.unlockVault)           // Unlocks the secrets vault
+	http.HandleFunc("/metrics", s.metrics)                          // Prometheus exporter
+	s.registerAPIv1()                                               // Versioned REST API, documented in openapi.yaml
 	//http.HandleFunc("/reset-muling", s.resetMuling)
 
 	assets, _ := fs.Sub(assetsFS, "assets")
 	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.FS(assets))))
 
 	s.server = &http.Server{
-		Addr: fmt.Sprintf(":%d", port),
+		Addr:    addr,
+		Handler: s.requireAuth(http.DefaultServeMux),
 	}
 
-	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if auth := config.Koolo.Server.Auth; auth.Enabled && auth.PasswordHash == "" && len(auth.Tokens) == 0 {
+		s.logger.Warn("Web UI authentication is enabled but no password or tokens are configured, nobody will be able to log in")
+	}
+
+	var err error
+	if tls := config.Koolo.Server.TLS; tls.Enabled {
+		err = s.server.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+	} else {
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
 	return nil
 }
 
+// HandleEvent forwards the events to the typed event stream, it must be registered on the event.Listener
+func (s *HttpServer) HandleEvent(ctx context.Context, e event.Event) error {
+	return s.eventStream.Handle(ctx, e)
+}
+
 func (s *HttpServer) reloadConfig(w http.ResponseWriter, r *http.Request) {
 	result := s.manager.ReloadConfig()
 	if result != nil {
@@ -660,22 +704,60 @@ func (s *HttpServer) debugHandler(w http.ResponseWriter, r *http.Request) {
 	s.templates.ExecuteTemplate(w, "debug.gohtml", nil)
 }
 
+// debugFixture downloads the map data around the character as a fixture for the offline pathing tests
+func (s *HttpServer) debugFixture(w http.ResponseWriter, r *http.Request) {
+	characterName := r.URL.Query().Get("characterName")
+	if characterName == "" {
+		http.Error(w, "Character name is required", http.StatusBadRequest)
+		return
+	}
+
+	context := s.manager.GetContext(characterName)
+	if context == nil || context.Data == nil {
+		http.Error(w, "Character is not running", http.StatusNotFound)
+		return
+	}
+
+	fixture, err := game.NewAreaFixture(context.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fixture.FixtureName()))
+	if err = game.WriteAreaFixture(w, fixture); err != nil {
+		s.logger.Error("Failed to write map fixture", "error", err)
+	}
+}
+
 func (s *HttpServer) startSupervisor(w http.ResponseWriter, r *http.Request) {
-	supervisorList := s.manager.AvailableSupervisors()
 	Supervisor := r.URL.Query().Get("characterName")
 
+	if !s.canStart(Supervisor) {
+		return
+	}
+
+	s.manager.Start(Supervisor, false)
+	s.initialData(w, r)
+}
+
+// canStart checks the supervisor config exists and no TokenAuth client would be starting at the same time
+func (s *HttpServer) canStart(supervisor string) bool {
+	supervisorList := s.manager.AvailableSupervisors()
+
 	// Get the current auth method for the supervisor we wanna start
-	supCfg, currFound := config.Characters[Supervisor]
+	supCfg, currFound := config.Characters[supervisor]
 	if !currFound {
 		// There's no config for the current supervisor. THIS SHOULDN'T HAPPEN
-		return
+		return false
 	}
 
 	// Prevent launching of other clients while there's a client with TokenAuth still starting
 	for _, sup := range supervisorList {
 
 		// If the current don't check against the one we're trying to launch
-		if sup == Supervisor {
+		if sup == supervisor {
 			continue
 		}
 
@@ -683,21 +765,20 @@ func (s *HttpServer) startSupervisor(w http.ResponseWriter, r *http.Request) {
 
 			// Prevent launching if we're using token auth & another client is starting (no matter what auth method)
 			if supCfg.AuthMethod == "TokenAuth" {
-				return
+				return false
 			}
 
 			// Prevent launching if another client that is using token auth is starting
 			sCfg, found := config.Characters[sup]
 			if found {
 				if sCfg.AuthMethod == "TokenAuth" {
-					return
+					return false
 				}
 			}
 		}
 	}
 
-	s.manager.Start(Supervisor, false)
-	s.initialData(w, r)
+	return true
 }
 
 func (s *HttpServer) stopSupervisor(w http.ResponseWriter, r *http.Request) {
@@ -730,9 +811,10 @@ func (s *HttpServer) index(w http.ResponseWriter) {
 	}
 
 	s.templates.ExecuteTemplate(w, "index.gohtml", IndexData{
-		Version:   config.Version,
-		Status:    status,
-		DropCount: drops,
+		Version:     config.Version,
+		Status:      status,
+		DropCount:   drops,
+		AuthEnabled: config.Koolo.Server.Auth.Enabled,
 	})
 }
 
@@ -938,6 +1020,7 @@ func (s *HttpServer) config(w http.ResponseWriter, r *http.Request) {
 		// Debug
 		newConfig.Debug.Log = r.Form.Get("debug_log") == "true"
 		newConfig.Debug.Screenshots = r.Form.Get("debug_screenshots") == "true"
+		newConfig.Debug.RecordEvents = r.Form.Get("debug_record_events") == "true"
 		// Discord
 		newConfig.Discord.Enabled = r.Form.Get("discord_enabled") == "true"
 		newConfig.Discord.EnableGameCreatedMessages = r.Form.Has("enable_game_created_messages")
@@ -1007,6 +1090,7 @@ func (s *HttpServer) characterSettings(w http.ResponseWriter, r *http.Request) {
 			cfg = config.Characters["template"]
 		}
 
+		cfg.Extends = r.Form.Get("extends")
 		cfg.MaxGameLength, _ = strconv.Atoi(r.Form.Get("maxGameLength"))
 		cfg.CharacterName = r.Form.Get("characterName")
 		cfg.CommandLineArgs = r.Form.Get("commandLineArgs")
@@ -1323,7 +1407,7 @@ func (s *HttpServer) characterSettings(w http.ResponseWriter, r *http.Request) {
 		//cfg.Muling.MuleProfiles = r.Form["mulingMuleProfiles[]"]
 		//cfg.Muling.ReturnTo = r.FormValue("mulingReturnTo")
 
-		config.SaveSupervisorConfig(supervisorName, cfg)
+		s.manager.SaveSupervisorConfig(supervisorName, cfg)
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
@@ -1372,6 +1456,7 @@ func (s *HttpServer) characterSettings(w http.ResponseWriter, r *http.Request) {
 		AvailableTZs:       availableTZs,
 		RecipeList:         config.AvailableRecipes,
 		RunewordRecipeList: config.AvailableRunewordRecipes,
+		Profiles:           config.Profiles(),
 	})
 }
 
@@ -1437,7 +1522,7 @@ func (s *HttpServer) resetMuling(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("Resetting muling index for character", "character", characterName)
 	//cfg.MulingState.CurrentMuleIndex = 0
 
-	err := config.SaveSupervisorConfig(characterName, cfg)
+	err := s.manager.SaveSupervisorConfig(characterName, cfg)
 	if err != nil {
 		http.Error(w, "Failed to save updated config", http.StatusInternalServerError)
 		return
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+)
+
+const (
+	decisionDays  = 7
+	decisionGames = 50
+)
+
+// itemDecisions shows why every item was picked up, stashed, sold or dropped during a game, the game is selected by
+// its start time and defaults to the last one
+func (s *HttpServer) itemDecisions(w http.ResponseWriter, r *http.Request) {
+	params := ItemDecisionsData{
+		Supervisors: s.manager.AvailableSupervisors(),
+		Supervisor:  r.URL.Query().Get("supervisor"),
+		Stage:       event.DecisionStage(r.URL.Query().Get("stage")),
+		Stages:      []event.DecisionStage{event.StagePickup, event.StageStash, event.StageSell, event.StageDrop},
+	}
+	if params.Supervisor == "" && len(params.Supervisors) > 0 {
+		params.Supervisor = params.Supervisors[0]
+	}
+	if params.Stage != "" && !slices.Contains(params.Stages, params.Stage) {
+		http.Error(w, fmt.Sprintf("invalid stage %s", params.Stage), http.StatusBadRequest)
+		return
+	}
+
+	var selected int64
+	if game := r.URL.Query().Get("game"); game != "" {
+		var err error
+		if selected, err = strconv.ParseInt(game, 10, 64); err != nil {
+			http.Error(w, "invalid game, expected its start time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, err := s.history.Query(history.Query{
+		Supervisor: params.Supervisor,
+		Types:      []history.RecordType{history.GameCreated, history.ItemDecision},
+		From:       time.Now().AddDate(0, 0, -decisionDays),
+	})
+	if err != nil {
+		params.ErrorMessage = fmt.Sprintf("failed to query history: %v", err)
+		s.templates.ExecuteTemplate(w, "item_decisions.gohtml", params)
+		return
+	}
+
+	params.Games = decisionsByGame(records)
+	if len(params.Games) > decisionGames {
+		params.Games = params.Games[:decisionGames]
+	}
+	for i := range params.Games {
+		if selected == 0 || params.Games[i].ID == selected {
+			params.Game = &params.Games[i]
+			break
+		}
+	}
+	if params.Game != nil && params.Stage != "" {
+		params.Game.Decisions = slices.DeleteFunc(slices.Clone(params.Game.Decisions), func(d DecisionRecord) bool {
+			return d.Stage != params.Stage
+		})
+	}
+
+	s.templates.ExecuteTemplate(w, "item_decisions.gohtml", params)
+}
+
+// decisionsByGame groups the decisions by the game they were taken in, newest game first. Decisions recorded before
+// the first game of the period are discarded.
+func decisionsByGame(records []history.Record) []DecisionGame {
+	var games []DecisionGame
+	for _, rec := range records {
+		switch {
+		case rec.Type == history.GameCreated:
+			games = append(games, DecisionGame{ID: rec.Time.Unix(), Name: rec.GameName, Started: rec.Time})
+		case rec.Decision != nil && len(games) > 0:
+			games[len(games)-1].Decisions = append(games[len(games)-1].Decisions, DecisionRecord{Time: rec.Time, ItemDecision: *rec.Decision})
+		}
+	}
+	slices.Reverse(games)
+
+	return games
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/hectorgimenez/koolo/internal/bot"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/metrics"
+)
+
+var supervisorStatuses = []bot.SupervisorStatus{bot.NotStarted, bot.Starting, bot.InGame, bot.Paused, bot.Crashed}
+
+// metrics serves the event bus counters plus supervisor and character gauges in Prometheus text format
+func (s *HttpServer) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+
+	if err := s.metricsCollector.Write(w); err != nil {
+		s.logger.Error("Failed to write metrics", "error", err)
+		return
+	}
+
+	status := s.getStatusData().Status
+	supervisors := make([]string, 0, len(status))
+	for name := range status {
+		supervisors = append(supervisors, name)
+	}
+	sort.Strings(supervisors)
+
+	info := metrics.NewFamily("koolo_build_info", "Koolo version.", "gauge")
+	info.Add(1, metrics.Labels([]string{"version"}, []string{config.Version}))
+
+	statusFamily := metrics.NewFamily("koolo_supervisor_status", "Current supervisor status, 1 for the active state.", "gauge")
+	gamesFamily := metrics.NewFamily("koolo_supervisor_games", "Games played since the supervisor started.", "gauge")
+	levelFamily := metrics.NewFamily("koolo_character_level", "Current character level.", "gauge")
+	expFamily := metrics.NewFamily("koolo_character_experience", "Current character experience.", "gauge")
+	nextExpFamily := metrics.NewFamily("koolo_character_next_level_experience", "Experience required for the next level.", "gauge")
+	goldFamily := metrics.NewFamily("koolo_character_gold", "Total character gold, inventory and stash.", "gauge")
+
+	for _, name := range supervisors {
+		stats := status[name]
+		current := stats.SupervisorStatus
+		if current == "" {
+			current = bot.NotStarted
+		}
+		for _, st := range supervisorStatuses {
+			value := 0.0
+			if st == current {
+				value = 1
+			}
+			statusFamily.Add(value, metrics.Labels([]string{"supervisor", "status"}, []string{name, string(st)}))
+		}
+		gamesFamily.Add(float64(stats.TotalGames()), metrics.Labels([]string{"supervisor"}, []string{name}))
+
+		// Character overview is only available while the supervisor is running
+		if stats.UI.Level == 0 {
+			continue
+		}
+		labels := metrics.Labels([]string{"supervisor", "class", "difficulty"}, []string{name, stats.UI.Class, stats.UI.Difficulty})
+		levelFamily.Add(float64(stats.UI.Level), labels)
+		expFamily.Add(float64(stats.UI.Experience), labels)
+		nextExpFamily.Add(float64(stats.UI.NextExp), labels)
+		goldFamily.Add(float64(stats.UI.Gold), labels)
+	}
+
+	for _, f := range []*metrics.Family{info, statusFamily, gamesFamily, levelFamily, expFamily, nextExpFamily, goldFamily} {
+		if err := f.Write(w); err != nil {
+			s.logger.Error("Failed to write metrics", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/history"
+	"github.com/hectorgimenez/koolo/internal/pickit"
+	"github.com/hectorgimenez/koolo/internal/remote/droplog"
+)
+
+const defaultCoverageDays = 30
+
+// pickitCoverage shows how many stashed items each pickit rule of a supervisor matched, using the droplog files and the
+// stash decisions for the items kept without a rule
+func (s *HttpServer) pickitCoverage(w http.ResponseWriter, r *http.Request) {
+	params := PickitCoverageData{
+		Supervisors: s.manager.AvailableSupervisors(),
+		Supervisor:  r.URL.Query().Get("supervisor"),
+		Days:        defaultCoverageDays,
+	}
+	if days := r.URL.Query().Get("days"); days != "" {
+		d, err := strconv.Atoi(days)
+		if err != nil || d < 0 {
+			http.Error(w, "invalid days, expected a positive number or 0 for every drop", http.StatusBadRequest)
+			return
+		}
+		params.Days = d
+	}
+	if params.Supervisor == "" && len(params.Supervisors) > 0 {
+		params.Supervisor = params.Supervisors[0]
+	}
+
+	cfg, found := config.GetCharacter(params.Supervisor)
+	if !found {
+		params.ErrorMessage = fmt.Sprintf("supervisor %s not found", params.Supervisor)
+		s.templates.ExecuteTemplate(w, "pickit_coverage.gohtml", params)
+		return
+	}
+
+	base := config.Koolo.LogSaveDirectory
+	if base == "" {
+		base = "logs"
+	}
+	records, err := droplog.ReadAll(filepath.Join(base, "droplogs"))
+	if err != nil {
+		params.ErrorMessage = err.Error()
+		s.templates.ExecuteTemplate(w, "pickit_coverage.gohtml", params)
+		return
+	}
+
+	var since time.Time
+	if params.Days > 0 {
+		since = time.Now().AddDate(0, 0, -params.Days)
+	}
+	var drops []pickit.StashedDrop
+	for _, rec := range records {
+		if rec.Supervisor != params.Supervisor || rec.Time.Before(since) {
+			continue
+		}
+		drops = append(drops, pickit.StashedDrop{Time: rec.Time, Drop: rec.Drop})
+	}
+
+	// The droplog only has the items stashed by a rule, the other ones come from the stash decisions
+	decisions, err := s.history.Query(history.Query{
+		Supervisor: params.Supervisor,
+		Types:      []history.RecordType{history.ItemDecision},
+		From:       since,
+	})
+	if err != nil {
+		params.ErrorMessage = fmt.Sprintf("failed to query history: %v", err)
+	}
+	for _, rec := range decisions {
+		d := rec.Decision
+		if d == nil || d.Stage != event.StageStash || d.Action != event.ActionStash || d.RuleFile != "" {
+			continue
+		}
+		drops = append(drops, pickit.StashedDrop{Time: rec.Time, Drop: data.Drop{Item: d.Item, Rule: string(d.Reason)}})
+	}
+
+	params.Coverage = cfg.Runtime.Pickit.Coverage(drops)
+	s.templates.ExecuteTemplate(w, "pickit_coverage.gohtml", params)
+}
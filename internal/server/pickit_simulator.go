@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hectorgimenez/d2go/pkg/data"
+	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/pickit"
+)
+
+// pickitTest evaluates pickit rules against item fixtures, the supervisor rules unless some are entered in the form
+func (s *HttpServer) pickitTest(w http.ResponseWriter, r *http.Request) {
+	params := PickitTestData{
+		Supervisors: s.manager.AvailableSupervisors(),
+		Supervisor:  r.FormValue("supervisor"),
+		Rules:       r.FormValue("rules"),
+		Items:       r.FormValue("items"),
+		Stash:       r.FormValue("stash"),
+	}
+	if r.Method != http.MethodPost {
+		s.templates.ExecuteTemplate(w, "pickit_test.gohtml", params)
+		return
+	}
+
+	results, err := evaluateFixtures(params)
+	if err != nil {
+		params.ErrorMessage = err.Error()
+	}
+	params.Results = results
+
+	s.templates.ExecuteTemplate(w, "pickit_test.gohtml", params)
+}
+
+func evaluateFixtures(params PickitTestData) ([]pickit.Result, error) {
+	ruleSet := pickit.NewRuleSet()
+	if strings.TrimSpace(params.Rules) != "" {
+		if err := ruleSet.AddRules(pickit.LayerMemory, "rules", strings.NewReader(params.Rules)); err != nil {
+			return nil, err
+		}
+	} else {
+		cfg, found := config.GetCharacter(params.Supervisor)
+		if !found {
+			return nil, fmt.Errorf("supervisor %s not found, select one or enter the rules to test", params.Supervisor)
+		}
+		ruleSet = cfg.Runtime.Pickit
+	}
+
+	items, err := pickit.ReadItems(strings.NewReader(params.Items))
+	if err != nil {
+		return nil, err
+	}
+	var stash []data.Item
+	if strings.TrimSpace(params.Stash) != "" {
+		if stash, err = pickit.ReadItems(strings.NewReader(params.Stash)); err != nil {
+			return nil, fmt.Errorf("stash: %w", err)
+		}
+	}
+
+	results := make([]pickit.Result, 0, len(items))
+	for _, it := range items {
+		results = append(results, ruleSet.Evaluate(it, stash))
+	}
+
+	return results, nil
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/hectorgimenez/koolo/internal/config"
+)
+
+// Vault is the secrets vault state shown in the settings page
+func (d ConfigData) Vault() *config.Vault {
+	return config.DefaultVault
+}
+
+// unlockVault unlocks the secrets vault with the master passphrase, it's kept unlocked until Koolo is closed
+func (s *HttpServer) unlockVault(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := config.DefaultVault.Unlock(r.FormValue("passphrase")); err != nil {
+		message := "Error unlocking the secrets vault: " + err.Error()
+		if errors.Is(err, config.ErrWrongPassphrase) {
+			message = "Wrong vault passphrase"
+		}
+		s.logger.Warn("Secrets vault could not be unlocked", slog.Any("error", err))
+		s.templates.ExecuteTemplate(w, "config.gohtml", ConfigData{KooloCfg: config.Koolo, ErrorMessage: message})
+		return
+	}
+
+	s.logger.Info("Secrets vault unlocked")
+	http.Redirect(w, r, "/config", http.StatusSeeOther)
+}
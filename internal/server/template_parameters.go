@@ -1,9 +1,13 @@
 package server
 
 import (
+	"time"
+
 	"github.com/hectorgimenez/d2go/pkg/data"
 	"github.com/hectorgimenez/koolo/internal/bot"
 	"github.com/hectorgimenez/koolo/internal/config"
+	"github.com/hectorgimenez/koolo/internal/event"
+	"github.com/hectorgimenez/koolo/internal/pickit"
 )
 
 type IndexData struct {
@@ -11,6 +15,7 @@ type IndexData struct {
 	Version      string
 	Status       map[string]bot.Stats
 	DropCount    map[string]int
+	AuthEnabled  bool
 }
 
 type DropData struct {
@@ -35,6 +40,62 @@ type AllDropRecord struct {
 	Drop       data.Drop
 }
 
+// LoginData is used by the login page, Next is the page the user is sent to after logging in.
+type LoginData struct {
+	ErrorMessage string
+	Next         string
+}
+
+// AnalyticsData is used by the run analytics view, numbers are fetched from /api/analytics.
+type AnalyticsData struct {
+	Supervisors []string
+	Supervisor  string
+}
+
+// PickitTestData is used by the pickit simulator, Items and Stash are the JSON fixtures as entered.
+type PickitTestData struct {
+	ErrorMessage string
+	Supervisors  []string
+	Supervisor   string
+	Rules        string
+	Items        string
+	Stash        string
+	Results      []pickit.Result
+}
+
+// PickitCoverageData is used by the pickit coverage report, Days is the period covered, 0 for every drop.
+type PickitCoverageData struct {
+	ErrorMessage string
+	Supervisors  []string
+	Supervisor   string
+	Days         int
+	Coverage     pickit.Coverage
+}
+
+// ItemDecisionsData is used by the item decisions page, Game is the selected one among Games.
+type ItemDecisionsData struct {
+	ErrorMessage string
+	Supervisors  []string
+	Supervisor   string
+	Stage        event.DecisionStage
+	Stages       []event.DecisionStage
+	Games        []DecisionGame
+	Game         *DecisionGame
+}
+
+// DecisionGame is a game and the item decisions taken during it, ID is the game start as unix time
+type DecisionGame struct {
+	ID        int64
+	Name      string
+	Started   time.Time
+	Decisions []DecisionRecord
+}
+
+type DecisionRecord struct {
+	Time time.Time
+	event.ItemDecision
+}
+
 type CharacterSettings struct {
 	ErrorMessage       string
 	Supervisor         string
@@ -45,6 +106,7 @@ type CharacterSettings struct {
 	AvailableTZs       map[int]string
 	RecipeList         []string
 	RunewordRecipeList []string
+	Profiles           []string
 }
 
 type ConfigData struct {
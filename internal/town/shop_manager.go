@@ -3,6 +3,7 @@ package town
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"time"
 
 	"github.com/hectorgimenez/d2go/pkg/data"
@@ -10,6 +11,7 @@ import (
 	"github.com/hectorgimenez/d2go/pkg/data/stat"
 	"github.com/hectorgimenez/d2go/pkg/nip"
 	"github.com/hectorgimenez/koolo/internal/context"
+	"github.com/hectorgimenez/koolo/internal/event"
 	"github.com/hectorgimenez/koolo/internal/game"
 	"github.com/hectorgimenez/koolo/internal/ui"
 )
@@ -217,6 +219,7 @@ func SellJunk(lockConfig ...[][]int) {
 			// Or if this stack exactly equals the remaining excess to sell
 			if (totalKeys-qtyInStack.Value >= 12) || (qtyInStack.Value == excessCount-keysSold) {
 				ctx.Logger.Debug(fmt.Sprintf("Selling full stack of %d keys from %v", qtyInStack.Value, keyStack.Position))
+				ctx.RecordItemDecision(excessKeysDecision(keyStack))
 				SellItemFullStack(keyStack)
 				keysSold += qtyInStack.Value
 				totalKeys -= qtyInStack.Value      // Update total keys count
@@ -253,6 +256,7 @@ func SellJunk(lockConfig ...[][]int) {
 			}
 
 			if remainingKeyStack.Name != "" { // Check if a stack was found
+				ctx.RecordItemDecision(excessKeysDecision(remainingKeyStack))
 				for i := 0; i < excessCount; i++ {
 					SellItem(remainingKeyStack)
 					keysSold++
@@ -271,11 +275,18 @@ func SellJunk(lockConfig ...[][]int) {
 	// --- END OPTIMIZED LOGIC ---
 
 	// Existing logic to sell other junk items, now with lockConfig support
-	for _, i := range ItemsToBeSold(lockConfig...) {
-		SellItem(i)
+	for _, decision := range SellDecisions(lockConfig...) {
+		ctx.RecordItemDecision(decision)
+		if decision.Action == event.ActionSell {
+			SellItem(decision.Item)
+		}
 	}
 }
 
+func excessKeysDecision(keyStack data.Item) event.ItemDecision {
+	return event.ItemDecision{Stage: event.StageSell, Action: event.ActionSell, Reason: event.ReasonExcessKeys, Item: keyStack}
+}
+
 // SellItem sells a single item by Control-Clicking it.
 func SellItem(i data.Item) {
 	ctx := context.Get()
@@ -347,6 +358,17 @@ func buyFullStack(i data.Item, currentKeysInInventory int) {
 }
 
 func ItemsToBeSold(lockConfig ...[][]int) (items []data.Item) {
+	for _, decision := range SellDecisions(lockConfig...) {
+		if decision.Action == event.ActionSell {
+			items = append(items, decision.Item)
+		}
+	}
+
+	return
+}
+
+// SellDecisions evaluates every inventory item outside the locked slots, deciding if it's sold or kept
+func SellDecisions(lockConfig ...[][]int) (decisions []event.ItemDecision) {
 	ctx := context.Get()
 	healingPotionCountToKeep := ctx.Data.ConfiguredInventoryPotionCount(data.HealingPotion)
 	manaPotionCountToKeep := ctx.Data.ConfiguredInventoryPotionCount(data.ManaPotion)
@@ -367,26 +389,42 @@ func ItemsToBeSold(lockConfig ...[][]int) (items []data.Item) {
 			}
 		}
 
+		keep := func(reason event.DecisionReason) {
+			decisions = append(decisions, event.ItemDecision{Stage: event.StageSell, Action: event.ActionKeep, Reason: reason, Item: itm})
+		}
+
 		isQuestItem := slices.Contains(questItems, itm.Name)
 		if itm.IsFromQuest() || isQuestItem {
+			keep(event.ReasonQuestItem)
 			continue
 		}
 
 		if itm.Name == item.TomeOfTownPortal || itm.Name == item.TomeOfIdentify || itm.Name == item.Key || itm.Name == "WirtsLeg" {
+			keep(event.ReasonProtectedItem)
 			continue
 		}
 
 		if itm.IsRuneword {
+			keep(event.ReasonRuneword)
 			continue
 		}
 
-		if _, result := ctx.Data.CharacterCfg.Runtime.Rules.EvaluateAll(itm); result == nip.RuleResultFullMatch && !itm.IsPotion() {
+		if rule, result := ctx.Data.CharacterCfg.Runtime.Rules.EvaluateAll(itm); result == nip.RuleResultFullMatch && !itm.IsPotion() {
+			decisions = append(decisions, event.ItemDecision{
+				Stage:    event.StageSell,
+				Action:   event.ActionKeep,
+				Reason:   event.ReasonRuleMatch,
+				Rule:     rule.RawLine,
+				RuleFile: rule.Filename + ":" + strconv.Itoa(rule.LineNumber),
+				Item:     itm,
+			})
 			continue
 		}
 
 		if itm.IsHealingPotion() {
 			if healingPotionCountToKeep > 0 {
 				healingPotionCountToKeep--
+				keep(event.ReasonPotion)
 				continue
 			}
 		}
@@ -394,6 +432,7 @@ func ItemsToBeSold(lockConfig ...[][]int) (items []data.Item) {
 		if itm.IsManaPotion() {
 			if manaPotionCountToKeep > 0 {
 				manaPotionCountToKeep--
+				keep(event.ReasonPotion)
 				continue
 			}
 		}
@@ -401,11 +440,16 @@ func ItemsToBeSold(lockConfig ...[][]int) (items []data.Item) {
 		if itm.IsRejuvPotion() {
 			if rejuvPotionCountToKeep > 0 {
 				rejuvPotionCountToKeep--
+				keep(event.ReasonPotion)
 				continue
 			}
 		}
 
-		items = append(items, itm)
+		reason := event.ReasonNoRuleMatch
+		if itm.IsPotion() {
+			reason = event.ReasonPotion
+		}
+		decisions = append(decisions, event.ItemDecision{Stage: event.StageSell, Action: event.ActionSell, Reason: reason, Item: itm})
 	}
 
 	return
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// ShowDialog writes the message to stderr, dialogs are only shown on Windows
+func ShowDialog(title, message string) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", title, message)
+}